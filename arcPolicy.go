@@ -0,0 +1,154 @@
+package spectre
+
+// arcPolicy is the Adaptive Replacement Cache policy measured in bytes.
+// T1 keeps the keys seen once recently and T2 the keys seen at least twice.
+// B1 and B2 remember the keys recently evicted from T1 and T2 ; a set of a
+// key remembered in B1 grows the byte target p of T1 while a set of a key
+// remembered in B2 shrinks it, so the recency/frequency split follows the
+// workload and a scan only flushes T1.
+type arcPolicy[K comparable] struct {
+	maxSize int
+	p       int
+	t1      *sizedList[K]
+	t2      *sizedList[K]
+	b1      *sizedList[K]
+	b2      *sizedList[K]
+	entries map[K]*sizedEntry[K]
+}
+
+// NewARCPolicy returns an Adaptive Replacement Cache policy for a cache of
+// maxSize bytes. It is used in place of the lru policy of VolatileLRUCache
+// with WithEvictionPolicy(NewARCPolicy[string]).
+func NewARCPolicy[K comparable](maxSize int) EvictionPolicy[K] {
+	return &arcPolicy[K]{
+		maxSize: maxSize,
+		t1:      newSizedList[K](),
+		t2:      newSizedList[K](),
+		b1:      newSizedList[K](),
+		b2:      newSizedList[K](),
+		entries: make(map[K]*sizedEntry[K]),
+	}
+}
+
+// isResident tells if the entry is in the cache, as opposed to a ghost.
+func (ap *arcPolicy[K]) isResident(entry *sizedEntry[K]) bool {
+	return entry.owner == ap.t1 || entry.owner == ap.t2
+}
+
+func (ap *arcPolicy[K]) OnAccess(key K) {
+	entry, ok := ap.entries[key]
+	if !ok || !ap.isResident(entry) {
+		return
+	}
+	// a second hit makes the key frequent
+	entry.owner.remove(entry)
+	ap.t2.pushBack(entry)
+}
+
+func (ap *arcPolicy[K]) OnInsert(key K, size int) {
+	entry, ok := ap.entries[key]
+	if !ok {
+		entry = &sizedEntry[K]{key: key, size: size}
+		ap.entries[key] = entry
+		ap.t1.pushBack(entry)
+		ap.trimGhosts()
+		return
+	}
+	switch entry.owner {
+	case ap.b1:
+		// recency was evicted too early, favour T1
+		ap.p = minInt(ap.maxSize, ap.p+ap.adaptation(size, ap.b2.bytes, ap.b1.bytes))
+	case ap.b2:
+		// frequency was evicted too early, favour T2
+		ap.p = maxInt(0, ap.p-ap.adaptation(size, ap.b1.bytes, ap.b2.bytes))
+	}
+	entry.owner.remove(entry)
+	entry.size = size
+	ap.t2.pushBack(entry)
+	ap.trimGhosts()
+}
+
+// adaptation returns the number of bytes p moves for a ghost hit of the
+// given size, larger when the ghost list hit is the smaller one.
+func (ap *arcPolicy[K]) adaptation(size int, otherBytes int, hitBytes int) int {
+	if hitBytes <= 0 || otherBytes <= hitBytes {
+		return size
+	}
+	return size * otherBytes / hitBytes
+}
+
+func (ap *arcPolicy[K]) OnRemove(key K) {
+	entry, ok := ap.entries[key]
+	if !ok {
+		return
+	}
+	// a deleted key was not evicted, it is not remembered as a ghost
+	entry.owner.remove(entry)
+	delete(ap.entries, key)
+}
+
+func (ap *arcPolicy[K]) Victim() (K, bool) {
+	var entry *sizedEntry[K]
+	var ghosts *sizedList[K]
+	if ap.t1.bytes > 0 && (ap.t1.bytes > ap.p || ap.t2.bytes == 0) {
+		entry, ghosts = ap.t1.front(), ap.b1
+	} else if ap.t2.bytes > 0 || ap.t2.entries.Len() > 0 {
+		entry, ghosts = ap.t2.front(), ap.b2
+	} else {
+		entry, ghosts = ap.t1.front(), ap.b1
+	}
+	if entry == nil {
+		var zero K
+		return zero, false
+	}
+	entry.owner.remove(entry)
+	ghosts.pushBack(entry)
+	ap.trimGhosts()
+	return entry.key, true
+}
+
+// trimGhosts bounds the ghost lists so T1+B1 stays within the max size and
+// all four lists within twice the max size.
+func (ap *arcPolicy[K]) trimGhosts() {
+	for ap.b1.entries.Len() > 0 && ap.t1.bytes+ap.b1.bytes > ap.maxSize {
+		ap.dropGhost(ap.b1)
+	}
+	for ap.b2.entries.Len() > 0 && ap.t1.bytes+ap.t2.bytes+ap.b1.bytes+ap.b2.bytes > 2*ap.maxSize {
+		ap.dropGhost(ap.b2)
+	}
+}
+
+func (ap *arcPolicy[K]) dropGhost(ghosts *sizedList[K]) {
+	entry := ghosts.front()
+	ghosts.remove(entry)
+	delete(ap.entries, entry.key)
+}
+
+func (ap *arcPolicy[K]) Reset() {
+	ap.p = 0
+	ap.t1 = newSizedList[K]()
+	ap.t2 = newSizedList[K]()
+	ap.b1 = newSizedList[K]()
+	ap.b2 = newSizedList[K]()
+	ap.entries = make(map[K]*sizedEntry[K])
+}
+
+// Keys lists the resident keys, the recent ones of T1 first then the
+// frequent ones of T2, each from least to most recently used.
+func (ap *arcPolicy[K]) Keys() []K {
+	return append(ap.t1.keys(), ap.t2.keys()...)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
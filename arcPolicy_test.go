@@ -0,0 +1,73 @@
+package spectre
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestARCPolicyScanResistance(t *testing.T) {
+	cache, _ := New(WithMaxSize(10), WithTTL(time.Hour), WithEvictionPolicy(NewARCPolicy[string]))
+	for _, key := range []string{"hot1", "hot2"} {
+		cache.VolatileLRUCacheSet(key, key, 1, time.Duration(0))
+		cache.VolatileLRUCacheGet(key)
+	}
+	for i := 0; i < 50; i++ {
+		key := "scan" + strconv.Itoa(i)
+		if success, err := cache.VolatileLRUCacheSet(key, key, 1, time.Duration(0)); !success {
+			t.Fatalf("scan set failed %v", err)
+		}
+	}
+	for _, key := range []string{"hot1", "hot2"} {
+		if _, ok := cache.VolatileLRUCacheGet(key); !ok {
+			t.Fatalf("scan flushed the frequent key %v", key)
+		}
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 10 {
+		t.Fatalf("cache size calculation is incorrect")
+	}
+}
+
+func TestARCPolicyGhostAdaptation(t *testing.T) {
+	policy := NewARCPolicy[string](4).(*arcPolicy[string])
+	policy.OnInsert("a", 2)
+	policy.OnInsert("b", 2)
+	if victim, _ := policy.Victim(); victim != "a" {
+		t.Fatalf("expected a as victim got %v", victim)
+	}
+	if policy.b1.entries.Len() != 1 {
+		t.Fatalf("evicted key is not remembered as a ghost")
+	}
+	// a comes back, recency was evicted too early
+	policy.OnInsert("a", 2)
+	if policy.p != 2 {
+		t.Fatalf("expected target of T1 to grow to 2 got %v", policy.p)
+	}
+	if policy.entries["a"].owner != policy.t2 {
+		t.Fatalf("ghost hit should land in T2")
+	}
+	policy.OnRemove("b")
+	if _, ok := policy.entries["b"]; ok {
+		t.Fatalf("deleted key is still tracked")
+	}
+	policy.Reset()
+	if _, ok := policy.Victim(); ok {
+		t.Fatalf("reset policy returned a victim")
+	}
+}
+
+func TestARCPolicyGhostBound(t *testing.T) {
+	policy := NewARCPolicy[string](4).(*arcPolicy[string])
+	for i := 0; i < 100; i++ {
+		policy.OnInsert(strconv.Itoa(i), 1)
+		if policy.t1.bytes+policy.t2.bytes > 4 {
+			policy.Victim()
+		}
+	}
+	if policy.t1.bytes+policy.b1.bytes > 4 {
+		t.Fatalf("ghost list B1 is not bounded")
+	}
+	if len(policy.entries) > 8 {
+		t.Fatalf("policy tracks %v keys for a cache of 4 bytes", len(policy.entries))
+	}
+}
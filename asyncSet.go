@@ -0,0 +1,267 @@
+package spectre
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// QueueFullPolicy tells SetAsync what to do when the async write queue
+// already holds its maximum number of keys.
+type QueueFullPolicy int
+
+const (
+	// QueueBlock makes SetAsync wait until the queue has room.
+	QueueBlock QueueFullPolicy = iota
+	// QueueDrop discards the write ; its AsyncResult reports DroppedWriteError.
+	QueueDrop
+	// QueueError makes SetAsync return QueueFullError without queueing.
+	QueueError
+)
+
+// asyncError is the error which is thrown when an async write could not
+// be queued.
+type asyncError struct {
+	errorNumber int
+	problem     string
+}
+
+func (ae *asyncError) Error() string {
+	return fmt.Sprintf("%d---%s", ae.errorNumber, ae.problem)
+}
+
+var (
+	// QueueFullError returns from SetAsync when the queue is full and the
+	// QueueError policy is used
+	QueueFullError = &asyncError{problem: "async write queue is full", errorNumber: 7}
+	// DroppedWriteError is the outcome of a write discarded by the QueueDrop policy
+	DroppedWriteError = &asyncError{problem: "async write dropped, queue is full", errorNumber: 8}
+)
+
+// AsyncResult is the future of a write started by SetAsync. The result
+// becomes available once the write has been applied on the cache.
+type AsyncResult struct {
+	done    chan struct{}
+	success bool
+	err     error
+}
+
+// newAsyncResult returns an unresolved AsyncResult.
+func newAsyncResult() *AsyncResult {
+	return &AsyncResult{done: make(chan struct{})}
+}
+
+// resolve stores the outcome of the write and wakes up the waiters.
+func (ar *AsyncResult) resolve(success bool, err error) {
+	ar.success = success
+	ar.err = err
+	close(ar.done)
+}
+
+// Done returns a channel which is closed when the write has been applied.
+func (ar *AsyncResult) Done() <-chan struct{} {
+	return ar.done
+}
+
+// Wait blocks until the write has been applied and returns its outcome,
+// the same values Set would have returned.
+func (ar *AsyncResult) Wait() (bool, error) {
+	<-ar.done
+	return ar.success, ar.err
+}
+
+// asyncWrite is a queued SetAsync call. Writes to the same key coalesce
+// into one asyncWrite holding the last value and every waiting result.
+type asyncWrite[K comparable, V any] struct {
+	key       K
+	value     V
+	size      int
+	keyExpire time.Duration
+	opts      []SetOption
+	results   []*AsyncResult
+}
+
+// asyncWriter applies the SetAsync calls of a VolatileLRU from a single
+// goroutine. The queue is bounded on the number of distinct keys waiting,
+// a write to a key already waiting just replaces its value.
+type asyncWriter[K comparable, V any] struct {
+	vlruCache *VolatileLRU[K, V]
+	depth     int
+	policy    QueueFullPolicy
+	pending   map[K]*asyncWrite[K, V]
+	order     []K
+	inFlight  *asyncWrite[K, V]
+	closed    bool
+	notEmpty  *sync.Cond
+	notFull   *sync.Cond
+	startOnce sync.Once
+	sync.Mutex
+}
+
+// newAsyncWriter returns an asyncWriter for the cache. The worker goroutine
+// is started on the first write.
+func newAsyncWriter[K comparable, V any](vlruCache *VolatileLRU[K, V], depth int, policy QueueFullPolicy) *asyncWriter[K, V] {
+	writer := &asyncWriter[K, V]{
+		vlruCache: vlruCache,
+		depth:     depth,
+		policy:    policy,
+		pending:   make(map[K]*asyncWrite[K, V]),
+	}
+	writer.notEmpty = sync.NewCond(writer)
+	writer.notFull = sync.NewCond(writer)
+	return writer
+}
+
+// enqueue queues a write following the queue full policy.
+// return values :
+//		result: the future of the write
+//		error: QueueFullError when the QueueError policy rejects the write,
+//			   ErrClosed once the writer is closed
+func (aw *asyncWriter[K, V]) enqueue(key K, value V, size int, keyExpire time.Duration, opts []SetOption) (*AsyncResult, error) {
+	aw.startOnce.Do(func() { go aw.run() })
+	result := newAsyncResult()
+	aw.Lock()
+	defer aw.Unlock()
+	for {
+		if aw.closed {
+			return nil, ErrClosed
+		}
+		if write, ok := aw.pending[key]; ok {
+			// coalesce, only the last value of the key lands
+			write.value = value
+			write.size = size
+			write.keyExpire = keyExpire
+			write.opts = opts
+			write.results = append(write.results, result)
+			return result, nil
+		}
+		if len(aw.order) < aw.depth {
+			break
+		}
+		switch aw.policy {
+		case QueueDrop:
+			result.resolve(false, DroppedWriteError)
+			return result, nil
+		case QueueError:
+			return nil, QueueFullError
+		}
+		aw.notFull.Wait()
+	}
+	aw.pending[key] = &asyncWrite[K, V]{
+		key:       key,
+		value:     value,
+		size:      size,
+		keyExpire: keyExpire,
+		opts:      opts,
+		results:   []*AsyncResult{result},
+	}
+	aw.order = append(aw.order, key)
+	aw.notEmpty.Signal()
+	return result, nil
+}
+
+// run applies the queued writes in their arrival order, until the writer
+// is closed and the queue is empty.
+func (aw *asyncWriter[K, V]) run() {
+	for {
+		aw.Lock()
+		for len(aw.order) == 0 && !aw.closed {
+			aw.notEmpty.Wait()
+		}
+		if len(aw.order) == 0 {
+			aw.Unlock()
+			return
+		}
+		key := aw.order[0]
+		aw.order = aw.order[1:]
+		write := aw.pending[key]
+		delete(aw.pending, key)
+		aw.inFlight = write
+		aw.notFull.Broadcast()
+		aw.Unlock()
+
+		// the closed check of Set is skipped, queued writes
+		// still land while Close drains the queue
+		aw.vlruCache.Lock()
+		success, err := aw.vlruCache.set(write.key, write.value, write.size, write.keyExpire, write.opts...)
+		aw.vlruCache.Unlock()
+		aw.vlruCache.cache.stats.recordSet(success, err)
+		if success {
+			aw.vlruCache.invalidate(write.key)
+		}
+
+		aw.Lock()
+		aw.inFlight = nil
+		aw.Unlock()
+		for _, result := range write.results {
+			result.resolve(success, err)
+		}
+	}
+}
+
+// flush waits until every write queued before the call has been applied.
+func (aw *asyncWriter[K, V]) flush(ctx context.Context) error {
+	aw.Lock()
+	var waiting []*AsyncResult
+	if aw.inFlight != nil {
+		waiting = append(waiting, aw.inFlight.results...)
+	}
+	for _, write := range aw.pending {
+		waiting = append(waiting, write.results...)
+	}
+	aw.Unlock()
+	for _, result := range waiting {
+		select {
+		case <-result.Done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// close rejects any new write and waits for the queued ones to be applied
+// until the context is done ; the writes still queued then are resolved
+// with ErrClosed. The worker goroutine exits once the queue is empty.
+func (aw *asyncWriter[K, V]) close(ctx context.Context) error {
+	aw.Lock()
+	aw.closed = true
+	aw.notFull.Broadcast()
+	aw.notEmpty.Broadcast()
+	aw.Unlock()
+	err := aw.flush(ctx)
+	aw.Lock()
+	for _, write := range aw.pending {
+		for _, result := range write.results {
+			result.resolve(false, ErrClosed)
+		}
+	}
+	aw.pending = make(map[K]*asyncWrite[K, V])
+	aw.order = nil
+	aw.Unlock()
+	return err
+}
+
+// SetAsync queues the value corresponding to a key to be set in the
+// background and returns without waiting for the write. The returned
+// AsyncResult reports the real outcome of the write ; callers not interested
+// in it can simply drop it.
+// Writes to a key that is still waiting in the queue replace the waiting
+// value, so only the last one lands and every result of the key reports it.
+// When the queue is full the policy given with WithAsyncQueue decides
+// between blocking, dropping the write or returning QueueFullError.
+// After Close it returns ErrClosed.
+func (vlruCache *VolatileLRU[K, V]) SetAsync(key K, value V, size int, keyExpire time.Duration, opts ...SetOption) (*AsyncResult, error) {
+	return vlruCache.writer.enqueue(key, value, size, keyExpire, opts)
+}
+
+// Flush blocks until every write queued by SetAsync before the call has
+// been applied on the cache, or until the context is done. After Close it
+// returns ErrClosed.
+func (vlruCache *VolatileLRU[K, V]) Flush(ctx context.Context) error {
+	if vlruCache.isClosed() {
+		return ErrClosed
+	}
+	return vlruCache.writer.flush(ctx)
+}
@@ -0,0 +1,113 @@
+package spectre
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// holdAsyncWriter locks the cache and queues a write, returning once the
+// worker has picked it up and is blocked on the cache lock.
+func holdAsyncWriter(t *testing.T, cache *VolatileLRUCache) {
+	cache.Lock()
+	if _, err := cache.SetAsync("held", "held", 1, time.Duration(0)); err != nil {
+		t.Fatalf("could not queue the held write %v", err)
+	}
+	for {
+		cache.writer.Lock()
+		inFlight := cache.writer.inFlight != nil
+		cache.writer.Unlock()
+		if inFlight {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSetAsync(t *testing.T) {
+	cache := GetVolatileLRUCache(10, 4, time.Duration(3600))
+	result, _ := cache.SetAsync("vivek", "vivek", 5, time.Duration(0))
+	success, err := result.Wait()
+	if !success {
+		t.Fatalf("async set failed %v", err)
+	}
+	if val, ok := cache.VolatileLRUCacheGet("vivek"); !ok || val != "vivek" {
+		t.Fatalf("async set value is not readable after Wait")
+	}
+	result, _ = cache.SetAsync("ibibo", "ibibo", 11, time.Duration(0))
+	<-result.Done()
+	if _, err := result.Wait(); err != SizeLimitError {
+		t.Fatalf("expected SizeLimitError got %v", err)
+	}
+}
+
+func TestSetAsyncCoalesce(t *testing.T) {
+	cache := GetVolatileLRUCache(10, 4, time.Duration(3600))
+	holdAsyncWriter(t, cache)
+	first, _ := cache.SetAsync("vivek", "first", 1, time.Duration(0))
+	last, _ := cache.SetAsync("vivek", "last", 1, time.Duration(0))
+	if len(cache.writer.order) != 1 {
+		t.Fatalf("writes to the same key were not coalesced")
+	}
+	cache.Unlock()
+	if err := cache.Flush(context.Background()); err != nil {
+		t.Fatalf("flush failed %v", err)
+	}
+	if val, _ := cache.VolatileLRUCacheGet("vivek"); val != "last" {
+		t.Fatalf("expected the last write to land got %v", val)
+	}
+	if success, _ := first.Wait(); !success {
+		t.Fatalf("coalesced write did not report the landed write")
+	}
+	if success, _ := last.Wait(); !success {
+		t.Fatalf("last write failed")
+	}
+}
+
+func TestSetAsyncQueueFull(t *testing.T) {
+	cache, _ := New(WithMaxSize(10), WithTTL(time.Hour), WithAsyncQueue(1, QueueError))
+	holdAsyncWriter(t, cache)
+	if _, err := cache.SetAsync("a", "a", 1, time.Duration(0)); err != nil {
+		t.Fatalf("queue should have room for one key %v", err)
+	}
+	if _, err := cache.SetAsync("b", "b", 1, time.Duration(0)); err != QueueFullError {
+		t.Fatalf("expected QueueFullError got %v", err)
+	}
+	cache.Unlock()
+
+	cache, _ = New(WithMaxSize(10), WithTTL(time.Hour), WithAsyncQueue(1, QueueDrop))
+	holdAsyncWriter(t, cache)
+	cache.SetAsync("a", "a", 1, time.Duration(0))
+	result, err := cache.SetAsync("b", "b", 1, time.Duration(0))
+	if err != nil {
+		t.Fatalf("drop policy should not return an error %v", err)
+	}
+	if _, err := result.Wait(); err != DroppedWriteError {
+		t.Fatalf("expected DroppedWriteError got %v", err)
+	}
+	cache.Unlock()
+}
+
+func TestFlush(t *testing.T) {
+	cache := GetVolatileLRUCache(100, 4, time.Duration(3600))
+	keys := []string{"vivek", "ibibo", "spectre"}
+	for _, key := range keys {
+		cache.SetAsync(key, key, 1, time.Duration(0))
+	}
+	if err := cache.Flush(context.Background()); err != nil {
+		t.Fatalf("flush failed %v", err)
+	}
+	for _, key := range keys {
+		if _, ok := cache.VolatileLRUCacheGet(key); !ok {
+			t.Fatalf("queued write of %v not applied after flush", key)
+		}
+	}
+
+	holdAsyncWriter(t, cache)
+	defer cache.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := cache.Flush(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected flush to give up with the context got %v", err)
+	}
+}
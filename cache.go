@@ -2,14 +2,15 @@ package spectre
 
 import (
 	"fmt"
-	"hash/fnv"
-	"math/rand"
-	"strconv"
+	"hash/maphash"
 	"sync"
 )
 
 // SHARD_COUNT is the number of golang maps that are going to participate in caching
 // internally.
+//
+// Deprecated: every Cache now owns its partition count, see WithPartitions.
+// This variable is no longer read or written by the package.
 var SHARD_COUNT int
 
 // lowSpaceError is the error that is thrown when the cache object is capable of
@@ -41,33 +42,64 @@ var (
 	LowSpaceError = &lowSpaceError{problem: "space not available", errorNumber: 1}
 )
 
-// threadSafeMap is a thread string to interface{} map.
-type threadSafeMap struct {
-	Items        map[string]interface{}
+// threadSafeMap is a thread safe map of the keys to their values.
+type threadSafeMap[K comparable, V any] struct {
+	Items        map[K]V
 	sync.RWMutex // Read Write mutex, guards access to internal map.
 }
 
-func (tsm *threadSafeMap) String() string {
+func (tsm *threadSafeMap[K, V]) String() string {
 	tsm.RLocker().Lock()
 	defer tsm.RLocker().Unlock()
 	return fmt.Sprintf("{currentsize:%v, data:%v}", len(tsm.Items), tsm.Items)
 }
 
+// HashFunc maps a string key to the 32 bit hash used to pick its partition.
+type HashFunc func(key string) uint32
+
+// Hasher maps a key to the 64 bit hash used to pick its partition.
+type Hasher[K comparable] func(key K) uint64
+
+// newMaphashHasher returns the default Hasher, hash/maphash over the key
+// with a seed of its own.
+func newMaphashHasher[K comparable]() Hasher[K] {
+	seed := maphash.MakeSeed()
+	return func(key K) uint64 {
+		return maphash.Comparable(seed, key)
+	}
+}
+
 // cacheData is list of threadsafe maps to participate in cache partition.
-type cacheData struct {
-	MapList []*threadSafeMap
+// Each cacheData owns its partition count and hash function so several
+// caches with different layouts can live in the same process.
+type cacheData[K comparable, V any] struct {
+	MapList    []*threadSafeMap[K, V]
+	shardCount int
+	hash       Hasher[K]
+}
+
+// newCacheData returns a cacheData having shardCount empty maps.
+func newCacheData[K comparable, V any](shardCount int, hash Hasher[K]) *cacheData[K, V] {
+	data := &cacheData[K, V]{
+		MapList:    make([]*threadSafeMap[K, V], shardCount),
+		shardCount: shardCount,
+		hash:       hash,
+	}
+	for i := 0; i < shardCount; i++ {
+		data.MapList[i] = &threadSafeMap[K, V]{Items: make(map[K]V)}
+	}
+	return data
 }
 
 // getShardMap returns the internal map which is supposed to keep the value
 // for this key. This method internally uses hashing on the key and finds out
 // the internal cache map.
-func (c *cacheData) getShardMap(key string) *threadSafeMap {
-	hasher := fnv.New32()
-	hasher.Write([]byte(key))
-	return c.MapList[uint(hasher.Sum32())%uint(SHARD_COUNT)]
+func (c *cacheData[K, V]) getShardMap(key K) *threadSafeMap[K, V] {
+	return c.MapList[c.hash(key)%uint64(c.shardCount)]
 }
 
-// Cache is the stucture resposible to handle the cache key and value.
+// TypedCache is the stucture resposible to handle the cache key and value,
+// for any comparable key type K and value type V.
 // This structure having the following parameters in it :
 //			MaxSize: maximum size of the cache object
 //			CurrentSize: current size of the cache object
@@ -76,17 +108,30 @@ func (c *cacheData) getShardMap(key string) *threadSafeMap {
 // 				  many time
 //			Data: a pointer to the cacheData in which threadsafe maps are
 // 				  storing the values
+//			policy: the EvictionPolicy choosing the keys to evict when
+//				  space is not available
 //			sync.RWMutex: to ensure the thread safety for this structure
-type Cache struct {
-	MaxSize      int
-	CurrentSize  int
-	Size         map[string]int
-	Data         *cacheData
+type TypedCache[K comparable, V any] struct {
+	MaxSize     int
+	CurrentSize int
+	Size        map[K]int
+	Data        *cacheData[K, V]
+	policy      EvictionPolicy[K]
+	// heapAccounting charges the key and the entry overhead, see
+	// WithHeapAccounting
+	heapAccounting bool
+	removals       *removalNotifier[K, V]
+	stats          *cacheStats
+	tags           *tagIndex[K]
+	namespaces     *namespaceIndex[K]
+	// policyLock guards the policy, which is also touched by readers
+	// holding only the read lock
+	policyLock   sync.Mutex
 	sync.RWMutex // for atomic CurrentSize modification
 }
 
 // GetCurrentSize return the current size of the cache.
-func (c *Cache) GetCurrentSize() int {
+func (c *TypedCache[K, V]) GetCurrentSize() int {
 	c.RLocker().Lock()
 	defer c.RLocker().Unlock()
 	var totalSize int
@@ -97,27 +142,27 @@ func (c *Cache) GetCurrentSize() int {
 	return totalSize
 }
 
-func (c *Cache) String() string {
+func (c *TypedCache[K, V]) String() string {
 	c.RLocker().Lock()
 	defer c.RLocker().Unlock()
 	return fmt.Sprintf("{currentsize:%v, data:%v}", c.CurrentSize, c.Data)
 }
 
-// CacheRow is a entry in cache having structure like (key: value)
-type CacheRow struct {
-	Key   string
-	Value interface{}
+// Row is a entry in cache having structure like (key: value)
+type Row[K comparable, V any] struct {
+	Key   K
+	Value V
 }
 
-func (c CacheRow) String() string {
-	return fmt.Sprintf("cache row {key:%v, value:%v}", c.Key, c.Value)
+func (r Row[K, V]) String() string {
+	return fmt.Sprintf("cache row {key:%v, value:%v}", r.Key, r.Value)
 }
 
-// CacheIterator returns all the key and value pair  one by one in the
-// input CacheRow channel. Calling goroutine  returns immediately after
-// spawning a gourtine. Spawned goroutine fills the channel with CacheRow.
-// When there is not any CacheRow left, spawned goroutine closes the channel.
-func (c *Cache) CacheIterator(outputChannel chan CacheRow) {
+// Iterator returns all the key and value pair  one by one in the
+// input Row channel. Calling goroutine  returns immediately after
+// spawning a gourtine. Spawned goroutine fills the channel with Row.
+// When there is not any Row left, spawned goroutine closes the channel.
+func (c *TypedCache[K, V]) Iterator(outputChannel chan Row[K, V]) {
 	go func() {
 		//panic handlling at goroutine level
 		defer func() {
@@ -128,9 +173,9 @@ func (c *Cache) CacheIterator(outputChannel chan CacheRow) {
 
 		c.RLocker().Lock()
 		defer c.RLocker().Unlock()
-		for i := 0; i < SHARD_COUNT; i++ {
+		for i := 0; i < c.Data.shardCount; i++ {
 			for key, value := range c.Data.MapList[i].Items {
-				temp := CacheRow{key, value}
+				temp := Row[K, V]{key, value}
 				outputChannel <- temp
 			}
 		}
@@ -138,14 +183,12 @@ func (c *Cache) CacheIterator(outputChannel chan CacheRow) {
 	}()
 }
 
-//CacheGetAllKeys returns a list of keys which are strings
-//It will give a list of all the keys from spectre
-
-func (c *Cache) CacheGetAllKeys() []string {
+// Keys returns a list of all the keys from spectre
+func (c *TypedCache[K, V]) Keys() []K {
 	c.RLocker().Lock()
 	defer c.RLocker().Unlock()
-	var keySet []string
-	for i := 0; i < SHARD_COUNT; i++ {
+	var keySet []K
+	for i := 0; i < c.Data.shardCount; i++ {
 		for key, _ := range c.Data.MapList[i].Items {
 			keySet = append(keySet, key)
 		}
@@ -153,12 +196,31 @@ func (c *Cache) CacheGetAllKeys() []string {
 	return keySet
 }
 
-// CacheGet returns the value in the cache pointed by the
+// Get returns the value in the cache pointed by the
 // input key parameter.
 // return values :
+//		val: value corresponding to the key, the zero value on a miss
 //		ok: true if success else false
-//		val: value corresponding to the key
-func (c *Cache) CacheGet(key string) (interface{}, bool) {
+func (c *TypedCache[K, V]) Get(key K) (V, bool) {
+	c.RLocker().Lock()
+	defer c.RLocker().Unlock()
+	sharedMap := c.Data.getShardMap(key)
+	sharedMap.RLocker().Lock()
+	defer sharedMap.RLocker().Unlock()
+	val, ok := sharedMap.Items[key]
+	if ok {
+		c.policyLock.Lock()
+		c.policy.OnAccess(key)
+		c.namespaces.access(key)
+		c.policyLock.Unlock()
+	}
+	c.stats.recordGet(ok)
+	return val, ok
+}
+
+// peek returns the value of the key like Get without reporting the
+// read to the eviction policy.
+func (c *TypedCache[K, V]) peek(key K) (V, bool) {
 	c.RLocker().Lock()
 	defer c.RLocker().Unlock()
 	sharedMap := c.Data.getShardMap(key)
@@ -168,72 +230,126 @@ func (c *Cache) CacheGet(key string) (interface{}, bool) {
 	return val, ok
 }
 
-// CacheSet sets the key with its corresponding value in the cache.
-// In case of memory unavailability, It frees some space with the defined algorithm
-// and sets the key.size of the key is in bytes.
+// Set sets the key with its corresponding value in the cache.
+// In case of memory unavailability, It frees some space with the eviction
+// policy of the cache and sets the key.size of the key is in bytes,
+// AutoSize to have it estimated.
 // return values :
 //		success: true if success else false
 //		error: error in the operation
-func (c *Cache) CacheSet(key string, value interface{}, size int) (bool, error) {
-	success, error := c.SetData(key, value, size)
+func (c *TypedCache[K, V]) Set(key K, value V, size int, opts ...SetOption) (bool, error) {
+	size = c.chargedSize(key, value, size)
+	success, error := c.setData(key, value, size, opts...)
 	for error == LowSpaceError {
-		c.makeSpace(key, size)
-		success, error = c.SetData(key, value, size)
+		if _, error = c.makeSpace(key, size, opts...); error != nil {
+			break
+		}
+		success, error = c.setData(key, value, size, opts...)
 	}
+	c.stats.recordSet(success, error)
 	return success, error
 }
 
+// chargedSize returns the number of bytes the key is accounted for : the
+// given size of the value, estimated for a negative size, plus the key and
+// the entry overhead with heap accounting.
+func (c *TypedCache[K, V]) chargedSize(key K, value V, size int) int {
+	if size < 0 {
+		size = EstimateSize(value)
+	}
+	if c.heapAccounting {
+		size = size + EstimateSize(key) + EntryOverhead
+	}
+	return size
+}
+
 // makeSpace frees the memory to accommodate new key as given in the input
-// params with its size.
+// params with its size. Keys are evicted in the order chosen by the
+// eviction policy ; the key itself may be evicted when it is the victim.
+// With namespaces the quotas of the namespaces are kept, see
+// makeNamespaceSpace.
 // return values :
-//		success: true if success else false
-//		error: error in the operation
-func (c *Cache) makeSpace(key string, size int) (bool, error) {
-	sharedMap := c.Data.getShardMap(key)
-	sharedMap.RLocker().Lock()
-	_, ok := sharedMap.Items[key]
-	// remove the lock from current shared map
-	// to avoid deadloack condition
-	sharedMap.RLocker().Unlock()
-	if !ok || c.Size[key] < size {
-		for c.CurrentSize+size > c.MaxSize {
-			c.deleteRandomKey()
+//		evicted: the keys removed to make the space
+//		error: LowSpaceError if the policy has no key left to evict else nil
+func (c *TypedCache[K, V]) makeSpace(key K, size int, opts ...SetOption) ([]K, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.namespaces.active() {
+		ns, _ := newSetConfig(opts).namespace.(*namespace[K])
+		return c.makeNamespaceSpace(key, size, ns)
+	}
+	var evicted []K
+	for !c.isSpaceAvaible(key, size) {
+		c.policyLock.Lock()
+		victim, ok := c.policy.Victim()
+		c.policyLock.Unlock()
+		if !ok {
+			return evicted, LowSpaceError
 		}
+		c.evict(victim)
+		evicted = append(evicted, victim)
 	}
-	return true, nil
+	return evicted, nil
 }
 
-// deleteRandomKey deletes a random key present in the cache.
-func (c *Cache) deleteRandomKey() {
-	c.Lock()
-	defer c.Unlock()
-	//fmt.Printf("clearing space\n")
-	randomNumber := strconv.Itoa(rand.Int())
-	selectedMap := c.Data.getShardMap(randomNumber)
-	selectedMap.Lock()
-	defer selectedMap.Unlock()
-	var deletedkey string
-	for key, _ := range selectedMap.Items {
-		delete(selectedMap.Items, key)
-		deletedkey = key
-		break
+// evict removes a victim the eviction policy stopped tracking. The caller
+// holds the cache lock.
+func (c *TypedCache[K, V]) evict(victim K) {
+	size := c.Size[victim]
+	ns := c.namespaces.of(victim)
+	value, _ := c.removeKey(victim)
+	c.removed(victim, value, size, Evicted)
+	ns.recordRemoval(size, Evicted)
+}
+
+// EstimateFrequency returns how often the key has been used according to
+// the eviction policy, when the policy implements FrequencyEstimator like
+// the W-TinyLFU policy.
+// return values :
+//		estimate: estimated number of uses of the key
+//		ok: false if the policy does not estimate frequencies
+func (c *TypedCache[K, V]) EstimateFrequency(key K) (int, bool) {
+	c.policyLock.Lock()
+	defer c.policyLock.Unlock()
+	estimator, ok := c.policy.(FrequencyEstimator[K])
+	if !ok {
+		return 0, false
 	}
-	c.CurrentSize = c.CurrentSize - c.Size[deletedkey]
-	delete(c.Size, deletedkey)
+	return estimator.Estimate(key), true
+}
+
+// removeKey removes the key from its internal map and from the size
+// accounting. The caller must hold the cache lock and keep the policy and
+// the removal listeners informed.
+// return values :
+//		value: the value the key had
+//		ok: false if the key was not in the cache
+func (c *TypedCache[K, V]) removeKey(key K) (V, bool) {
+	sharedMap := c.Data.getShardMap(key)
+	sharedMap.Lock()
+	defer sharedMap.Unlock()
+	value, ok := sharedMap.Items[key]
+	delete(sharedMap.Items, key)
+	c.CurrentSize = c.CurrentSize - c.Size[key]
+	c.namespaces.remove(key, c.Size[key])
+	delete(c.Size, key)
+	c.tags.remove(key)
+	return value, ok
 }
 
 // isSpaceAvaible returns an boolean identifier that tells, if
 // space is available for the given key.
 // returns :
 //		retFlag: true if space is available else false
-func (c *Cache) isSpaceAvaible(key string, size int) bool {
+func (c *TypedCache[K, V]) isSpaceAvaible(key K, size int) bool {
 	sharedMap := c.Data.getShardMap(key)
 	sharedMap.RLocker().Lock()
 	defer sharedMap.RLocker().Unlock()
 	_, ok := sharedMap.Items[key]
 	var retFlag bool
 	if ok {
-		if size <= c.Size[key] {
+		// the old value of the key gives its space back on replacement
+		if size-c.Size[key] <= c.MaxSize-c.CurrentSize {
 			retFlag = true
 		} else {
 			retFlag = false
@@ -256,60 +372,232 @@ func (c *Cache) isSpaceAvaible(key string, size int) bool {
 // returns :
 //		retFlag: true if space is available else false
 //		error: if any error in the operation else nil
-func (c *Cache) SetData(key string, value interface{}, size int) (bool, error) {
+func (c *TypedCache[K, V]) SetData(key K, value V, size int, opts ...SetOption) (bool, error) {
+	success, err := c.setData(key, value, c.chargedSize(key, value, size), opts...)
+	c.stats.recordSet(success, err)
+	return success, err
+}
+
+// setData is SetData for a size already charged, see chargedSize.
+func (c *TypedCache[K, V]) setData(key K, value V, size int, opts ...SetOption) (bool, error) {
 	// locking currentSize atomic lock
 	c.Lock()
 	defer c.Unlock()
+	sc := newSetConfig(opts)
+	if _, present := c.Size[key]; !sc.allows(present) {
+		return false, nil
+	}
+	ns, _ := sc.namespace.(*namespace[K])
 	if size > c.MaxSize {
 		return false, SizeLimitError
-	} else if !c.isSpaceAvaible(key, size) {
+	} else if ns != nil && size > ns.maxSize {
+		return false, NamespaceLimitError
+	} else if !c.isSpaceAvaible(key, size) || !c.fitsNamespace(key, size, ns) {
 		return false, LowSpaceError
 	}
 	sharedMap := c.Data.getShardMap(key)
 	sharedMap.Lock()
 	defer sharedMap.Unlock()
+	oldSize := c.Size[key]
+	if oldValue, ok := sharedMap.Items[key]; ok {
+		c.removed(key, oldValue, oldSize, Replaced)
+		c.namespaces.of(key).recordRemoval(oldSize, Replaced)
+	}
 	sharedMap.Items[key] = value
+	c.CurrentSize = c.CurrentSize - oldSize + size
 	c.Size[key] = size
-	c.CurrentSize = c.CurrentSize + size
+	c.tags.set(key, sc.tags)
+	c.namespaces.set(key, oldSize, size, ns)
+	c.policyLock.Lock()
+	if costAwarePolicy, ok := c.policy.(CostAwarePolicy[K]); ok {
+		costAwarePolicy.OnInsertWithCost(key, size, sc.cost)
+	} else {
+		c.policy.OnInsert(key, size)
+	}
+	c.policyLock.Unlock()
 	return true, nil
 }
 
-// CacheDelete deletes the key in the cache.
-func (c *Cache) CacheDelete(key string) {
+// Delete deletes the key in the cache.
+func (c *TypedCache[K, V]) Delete(key K) {
+	c.remove(key, Deleted)
+}
+
+// remove deletes the key in the cache, telling the removal listeners the
+// given reason.
+func (c *TypedCache[K, V]) remove(key K, reason RemovalReason) {
 	c.Lock()
 	defer c.Unlock()
-	sharedMap := c.Data.getShardMap(key)
-	sharedMap.Lock()
-	defer sharedMap.Unlock()
-	delete(sharedMap.Items, key)
-	c.CurrentSize = c.CurrentSize - int(c.Size[key])
-	delete(c.Size, key)
+	c.removeLocked(key, reason)
 }
 
-// ClearCache clears all the keys in the cache.
-func (c *Cache) ClearCache() {
+// removeLocked is remove for a caller holding the cache lock.
+func (c *TypedCache[K, V]) removeLocked(key K, reason RemovalReason) {
+	size := c.Size[key]
+	ns := c.namespaces.of(key)
+	value, ok := c.removeKey(key)
+	c.policyLock.Lock()
+	c.policy.OnRemove(key)
+	c.policyLock.Unlock()
+	if ok {
+		c.removed(key, value, size, reason)
+		ns.recordRemoval(size, reason)
+	}
+}
+
+// removed accounts a key of the given size leaving the cache for the reason
+// in the statistics and tells the removal listeners. The caller holds the
+// cache lock.
+func (c *TypedCache[K, V]) removed(key K, value V, size int, reason RemovalReason) {
+	c.stats.recordRemovals(1, size, reason)
+	c.removals.notify(key, value, reason)
+}
+
+// Clear clears all the keys in the cache.
+func (c *TypedCache[K, V]) Clear() {
 	c.Lock()
 	defer c.Unlock()
+	c.stats.recordRemovals(len(c.Size), c.CurrentSize, Cleared)
+	if c.removals.active() {
+		for i := 0; i < c.Data.shardCount; i++ {
+			for key, value := range c.Data.MapList[i].Items {
+				c.removals.notify(key, value, Cleared)
+			}
+		}
+	}
 	c.CurrentSize = 0
-	c.Size = make(map[string]int)
-	for i := 0; i < SHARD_COUNT; i++ {
-		c.Data.MapList[i] = &threadSafeMap{Items: make(map[string]interface{})}
+	c.Size = make(map[K]int)
+	c.tags.reset()
+	c.namespaces.reset()
+	for i := 0; i < c.Data.shardCount; i++ {
+		c.Data.MapList[i] = &threadSafeMap[K, V]{Items: make(map[K]V)}
+	}
+	c.policyLock.Lock()
+	c.policy.Reset()
+	c.policyLock.Unlock()
+}
+
+// NewTypedCache returns a TypedCache configured by the given options.
+// WithMaxSize is mandatory ; the partition count defaults to
+// DefaultPartitions, the hash function to hash/maphash and the eviction
+// policy to random eviction.
+// return values :
+//		cache: the configured cache
+//		error: a configuration error like InvalidSizeError, KeyTypeError
+//			   for a hasher or a policy of another key type
+func NewTypedCache[K comparable, V any](opts ...Option) (*TypedCache[K, V], error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return newTypedCache[K, V](cfg, NewRandomPolicy[K])
+}
+
+// newTypedCache builds a TypedCache from an already validated config. The
+// defaultPolicy is used when no WithEvictionPolicy option was given.
+func newTypedCache[K comparable, V any](cfg *config, defaultPolicy PolicyFactory[K]) (*TypedCache[K, V], error) {
+	factory := defaultPolicy
+	if cfg.policy != nil {
+		typedFactory, ok := cfg.policy.(PolicyFactory[K])
+		if !ok {
+			return nil, KeyTypeError
+		}
+		factory = typedFactory
+	}
+	hash := newMaphashHasher[K]()
+	if cfg.hasher != nil {
+		typedHasher, ok := cfg.hasher.(Hasher[K])
+		if !ok {
+			return nil, KeyTypeError
+		}
+		hash = typedHasher
 	}
+	return &TypedCache[K, V]{
+		Data:           newCacheData[K, V](cfg.partitions, hash),
+		Size:           make(map[K]int),
+		MaxSize:        cfg.maxSize,
+		policy:         factory(cfg.maxSize),
+		heapAccounting: cfg.heapAccounting,
+		removals:       newRemovalNotifier[K, V](),
+		stats:          &cacheStats{},
+		tags:           newTagIndex[K](),
+		namespaces:     newNamespaceIndex[K](),
+	}, nil
+}
+
+// Cache is the string keyed cache of the package, a thin wrapper on
+// TypedCache keeping the original method names.
+type Cache struct {
+	*TypedCache[string, interface{}]
+}
+
+// CacheRow is a entry in a Cache having structure like (key: value)
+type CacheRow = Row[string, interface{}]
+
+// CacheIterator returns all the key and value pair one by one in the
+// input CacheRow channel, see TypedCache Iterator.
+func (c *Cache) CacheIterator(outputChannel chan CacheRow) {
+	c.Iterator(outputChannel)
+}
+
+//CacheGetAllKeys returns a list of keys which are strings
+//It will give a list of all the keys from spectre
+
+func (c *Cache) CacheGetAllKeys() []string {
+	return c.Keys()
+}
+
+// CacheGet returns the value in the cache pointed by the
+// input key parameter.
+// return values :
+//		ok: true if success else false
+//		val: value corresponding to the key
+func (c *Cache) CacheGet(key string) (interface{}, bool) {
+	return c.Get(key)
+}
+
+// CacheSet sets the key with its corresponding value in the cache,
+// see TypedCache Set.
+// return values :
+//		success: true if success else false
+//		error: error in the operation
+func (c *Cache) CacheSet(key string, value interface{}, size int) (bool, error) {
+	return c.Set(key, value, size)
+}
+
+// CacheDelete deletes the key in the cache.
+func (c *Cache) CacheDelete(key string) {
+	c.Delete(key)
+}
+
+// ClearCache clears all the keys in the cache.
+func (c *Cache) ClearCache() {
+	c.Clear()
 }
 
 // GetDefaultCache returns the most abstract cache just using the
 // cap in memory limit . Cache is having algorithm to evict key when
-// space is not available in random selection.
+// space is not available in random selection, see NewRandomPolicy.
+// GetDefaultCache does not validate its input, use NewCache to get an
+// error back for a bad size or partition count.
 func GetDefaultCache(cacheSize int, cachePartitions int) *Cache {
-	SHARD_COUNT = cachePartitions
-	newCache := &Cache{
-		Data: &cacheData{MapList: make([]*threadSafeMap, SHARD_COUNT)},
-		Size: make(map[string]int),
-	}
+	cfg := defaultConfig()
+	cfg.maxSize = cacheSize
+	cfg.partitions = cachePartitions
+	// the default config has no typed option, it can not fail
+	typedCache, _ := newTypedCache[string, interface{}](cfg, NewRandomPolicy[string])
+	return &Cache{typedCache}
+}
 
-	for i := 0; i < SHARD_COUNT; i++ {
-		newCache.Data.MapList[i] = &threadSafeMap{Items: make(map[string]interface{})}
+// NewCache returns a Cache configured by the given options, see
+// NewTypedCache for the defaults.
+// return values :
+//		cache: the configured cache
+//		error: InvalidSizeError or InvalidPartitionsError for a bad config
+func NewCache(opts ...Option) (*Cache, error) {
+	typedCache, err := NewTypedCache[string, interface{}](opts...)
+	if err != nil {
+		return nil, err
 	}
-	newCache.MaxSize = cacheSize
-	return newCache
+	return &Cache{typedCache}, nil
 }
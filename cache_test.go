@@ -180,3 +180,45 @@ func TestGetDefaultCache(t *testing.T) {
 		t.Fatalf("type of the cache initiated is not spectre.Cache")
 	}
 }
+
+type testPoint struct {
+	X, Y int
+}
+
+func TestTypedCache(t *testing.T) {
+	cache, err := NewTypedCache[int, testPoint](WithMaxSize(20), WithPartitions(4))
+	if err != nil {
+		t.Fatalf("typed cache creation failed %v", err)
+	}
+	cache.Set(1, testPoint{1, 2}, 10)
+	cache.Set(2, testPoint{3, 4}, 10)
+	if val, ok := cache.Get(1); !ok || val != (testPoint{1, 2}) {
+		t.Fatalf("expected {1 2} got %v", val)
+	}
+	cache.Delete(1)
+	if val, ok := cache.Get(1); ok || val != (testPoint{}) {
+		t.Fatalf("deleted key returned %v", val)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{2}) {
+		t.Fatalf("expected keys [2] got %v", keys)
+	}
+}
+
+func TestTypedCacheStructKey(t *testing.T) {
+	cache, _ := NewTypedCache[testPoint, string](WithMaxSize(100))
+	cache.Set(testPoint{1, 2}, "vivek", 5)
+	if val, ok := cache.Get(testPoint{1, 2}); !ok || val != "vivek" {
+		t.Fatalf("struct key lookup failed got %v", val)
+	}
+}
+
+func BenchmarkTypedCacheGet(b *testing.B) {
+	cache, _ := NewTypedCache[int, int](WithMaxSize(1 << 20))
+	for i := 0; i < 1000; i++ {
+		cache.Set(i, i, 1)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(i % 1000)
+	}
+}
@@ -0,0 +1,131 @@
+// Command spectre-server serves a spectre cache over HTTP, see the server
+// package for the API. The metrics of the cache are served on /metrics.
+// With -resp-addr or -resp-socket the cache is also served to Redis clients,
+// see the resp package, and with -memcache-addr or -memcache-socket to
+// memcached clients, see the memcache package.
+//
+//	spectre-server -addr :8080 -max-size 268435456 -ttl 1h -snapshot /var/lib/spectre/cache.snapshot
+//	spectre-server -addr :8080 -resp-addr :6379 -resp-socket /run/spectre.sock
+//	spectre-server -addr :8080 -memcache-addr :11211
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/vivek07672/spectre"
+	"github.com/vivek07672/spectre/memcache"
+	"github.com/vivek07672/spectre/metrics"
+	"github.com/vivek07672/spectre/resp"
+	"github.com/vivek07672/spectre/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	maxSize := flag.Int("max-size", 64<<20, "maximum size of the cache in bytes")
+	partitions := flag.Int("partitions", spectre.DefaultPartitions, "number of internal maps of the cache")
+	ttl := flag.Duration("ttl", time.Hour, "time to live of the keys set without one")
+	snapshot := flag.String("snapshot", "", "file the cache is saved to every minute and on shutdown, and loaded from on start")
+	respAddr := flag.String("resp-addr", "", "tcp address to serve the redis protocol on, none by default")
+	respSocket := flag.String("resp-socket", "", "unix socket to serve the redis protocol on, none by default")
+	memcacheAddr := flag.String("memcache-addr", "", "tcp address to serve the memcached protocol on, none by default")
+	memcacheSocket := flag.String("memcache-socket", "", "unix socket to serve the memcached protocol on, none by default")
+	flag.Parse()
+
+	opts := []spectre.Option{
+		spectre.WithMaxSize(*maxSize),
+		spectre.WithPartitions(*partitions),
+		spectre.WithTTL(*ttl),
+		spectre.WithJanitor(time.Second, 1000),
+	}
+	if *snapshot != "" {
+		opts = append(opts, spectre.WithSnapshotFile(*snapshot, time.Minute))
+	}
+	cache, err := spectre.New(opts...)
+	if err != nil {
+		log.Fatalf("spectre-server: %v", err)
+	}
+	if *snapshot != "" {
+		err := cache.LoadSnapshotFile(*snapshot)
+		switch {
+		case errors.Is(err, spectre.PartialSnapshotError):
+			// a smaller -max-size refuses the keys which do not fit anymore
+			log.Printf("spectre-server: loading snapshot: %v", err)
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			log.Fatalf("spectre-server: loading snapshot: %v", err)
+		}
+	}
+	metrics.Register("spectre-server", cache)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/", server.New(cache))
+	httpServer := &http.Server{Addr: *addr, Handler: mux}
+
+	respServer := resp.New(cache)
+	serve("resp", respServer, resp.ErrServerClosed, *respAddr, *respSocket)
+	memcacheServer := memcache.New(cache)
+	serve("memcache", memcacheServer, memcache.ErrServerClosed, *memcacheAddr, *memcacheSocket)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+		respServer.Close()
+		memcacheServer.Close()
+	}()
+
+	log.Printf("spectre-server: listening on %s", *addr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("spectre-server: %v", err)
+	}
+	// the requests in flight are served before the cache is closed
+	<-drained
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cache.Close(ctx); err != nil {
+		log.Fatalf("spectre-server: closing cache: %v", err)
+	}
+}
+
+// protocolServer is a server of the resp or memcache package.
+type protocolServer interface {
+	Serve(listener net.Listener) error
+}
+
+// serve serves the protocol on the tcp address and the unix socket which
+// are given, until the server is closed.
+func serve(protocol string, srv protocolServer, closed error, tcpAddr string, socket string) {
+	for _, listen := range []struct{ network, address string }{{"tcp", tcpAddr}, {"unix", socket}} {
+		if listen.address == "" {
+			continue
+		}
+		if listen.network == "unix" {
+			// a socket left by a previous run would fail the listen
+			os.Remove(listen.address)
+		}
+		listener, err := net.Listen(listen.network, listen.address)
+		if err != nil {
+			log.Fatalf("spectre-server: %v", err)
+		}
+		log.Printf("spectre-server: serving %s on %s %s", protocol, listen.network, listen.address)
+		go func() {
+			if err := srv.Serve(listener); err != closed {
+				log.Fatalf("spectre-server: %v", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivek07672/spectre"
+	"github.com/vivek07672/spectre/memcache"
+	"github.com/vivek07672/spectre/resp"
+	"github.com/vivek07672/spectre/server"
+)
+
+// listen serves the protocol on a local tcp port and returns a connection
+// to it.
+func listen(t *testing.T, srv protocolServer, closed error, close func() error) (net.Conn, *bufio.Reader) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(listener)
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial failed %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		close()
+		if err := <-done; err != closed {
+			t.Errorf("expected %v got %v", closed, err)
+		}
+	})
+	return conn, bufio.NewReader(conn)
+}
+
+// exchange sends the raw request and reads the raw replies expected.
+func exchange(t *testing.T, conn net.Conn, in *bufio.Reader, request string, expected string) {
+	t.Helper()
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatalf("write failed %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	reply := make([]byte, len(expected))
+	if _, err := io.ReadFull(in, reply); err != nil {
+		t.Fatalf("expected %q, read %q then %v", expected, reply, err)
+	}
+	if string(reply) != expected {
+		t.Fatalf("expected %q got %q", expected, reply)
+	}
+}
+
+// httpGet returns the body of the value of the key.
+func httpGet(t *testing.T, base string, key string) string {
+	t.Helper()
+	response, err := http.Get(base + "/keys/" + key)
+	if err != nil {
+		t.Fatalf("get failed %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Fatalf("bad reply %v %q", response.Status, body)
+	}
+	return string(body)
+}
+
+func TestFrontEndsShareValues(t *testing.T) {
+	cache, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour))
+	memcacheServer := memcache.New(cache)
+	mc, mcIn := listen(t, memcacheServer, memcache.ErrServerClosed, memcacheServer.Close)
+	respServer := resp.New(cache)
+	redis, redisIn := listen(t, respServer, resp.ErrServerClosed, respServer.Close)
+	httpServer := httptest.NewServer(server.New(cache))
+	defer httpServer.Close()
+
+	// an item of a memcached client is read as its data
+	exchange(t, mc, mcIn, "set vivek 42 0 5\r\nvivek\r\n", "STORED\r\n")
+	exchange(t, redis, redisIn, "GET vivek\r\n", "$5\r\nvivek\r\n")
+	exchange(t, redis, redisIn, "MGET vivek\r\n", "*1\r\n$5\r\nvivek\r\n")
+	if body := httpGet(t, httpServer.URL, "vivek"); body != "vivek" {
+		t.Fatalf("expected the raw data got %q", body)
+	}
+	exchange(t, mc, mcIn, "append vivek 0 0 6\r\n:ibibo\r\n", "STORED\r\n")
+	exchange(t, redis, redisIn, "GET vivek\r\n", "$11\r\nvivek:ibibo\r\n")
+
+	// the values of the other clients are read by the memcached clients
+	// without flags
+	exchange(t, redis, redisIn, "SET spectre cache\r\n", "+OK\r\n")
+	exchange(t, mc, mcIn, "get spectre\r\n", "VALUE spectre 0 5\r\ncache\r\nEND\r\n")
+	request, _ := http.NewRequest(http.MethodPut, httpServer.URL+"/keys/counter", strings.NewReader("41"))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil || response.StatusCode != http.StatusNoContent {
+		t.Fatalf("put failed %v %v", err, response)
+	}
+	response.Body.Close()
+	exchange(t, mc, mcIn, "incr counter 1\r\n", "42\r\n")
+	if body := httpGet(t, httpServer.URL, "counter"); body != "42" {
+		t.Fatalf("expected the raw data got %q", body)
+	}
+}
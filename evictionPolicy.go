@@ -0,0 +1,231 @@
+package spectre
+
+import (
+	"container/list"
+	"math/rand"
+)
+
+// EvictionPolicy decides which key leaves the cache when space is not
+// available. The cache informs the policy about every key it holds through
+// the hooks and asks it for a victim until the new value fits.
+// The cache calls the hooks under its own lock, so implementations don't
+// need to be safe for concurrent use.
+type EvictionPolicy[K comparable] interface {
+	// OnAccess is called when a key present in the cache is read.
+	OnAccess(key K)
+	// OnInsert is called when a key is set, either a new key or a key
+	// replaced with a value of the given size.
+	OnInsert(key K, size int)
+	// OnRemove is called when a key is deleted from the cache.
+	OnRemove(key K)
+	// Victim returns the next key to evict and stops tracking it ; the
+	// cache removes it without calling OnRemove. ok is false when the
+	// policy has no key left.
+	Victim() (key K, ok bool)
+	// Reset forgets every key, it is called when the cache is cleared.
+	Reset()
+}
+
+// OrderedPolicy is implemented by the policies able to list their keys
+// in eviction order, the next victim first.
+type OrderedPolicy[K comparable] interface {
+	Keys() []K
+}
+
+// CostAwarePolicy is implemented by the policies weighting the keys with
+// the cost of recomputing their value. The cache calls OnInsertWithCost in
+// place of OnInsert with the cost given by WithCost, 1 by default.
+type CostAwarePolicy[K comparable] interface {
+	EvictionPolicy[K]
+	OnInsertWithCost(key K, size int, cost float64)
+}
+
+// PolicyFactory builds an EvictionPolicy for a cache of maxSize bytes.
+// The string keyed caches take the string instance of the policies, like
+// WithEvictionPolicy(NewFIFOPolicy[string]).
+type PolicyFactory[K comparable] func(maxSize int) EvictionPolicy[K]
+
+// listPolicy keeps the keys in a doubly linked list, the next victim at
+// the front. It is the base of the LRU and FIFO policies which only differ
+// on what a read does.
+type listPolicy[K comparable] struct {
+	order        *list.List
+	elements     map[K]*list.Element
+	moveOnAccess bool
+}
+
+func newListPolicy[K comparable](moveOnAccess bool) *listPolicy[K] {
+	return &listPolicy[K]{
+		order:        list.New(),
+		elements:     make(map[K]*list.Element),
+		moveOnAccess: moveOnAccess,
+	}
+}
+
+func (lp *listPolicy[K]) OnAccess(key K) {
+	if element, ok := lp.elements[key]; ok && lp.moveOnAccess {
+		lp.order.MoveToBack(element)
+	}
+}
+
+func (lp *listPolicy[K]) OnInsert(key K, size int) {
+	if element, ok := lp.elements[key]; ok {
+		lp.order.MoveToBack(element)
+		return
+	}
+	lp.elements[key] = lp.order.PushBack(key)
+}
+
+func (lp *listPolicy[K]) OnRemove(key K) {
+	if element, ok := lp.elements[key]; ok {
+		lp.order.Remove(element)
+		delete(lp.elements, key)
+	}
+}
+
+func (lp *listPolicy[K]) Victim() (K, bool) {
+	element := lp.order.Front()
+	if element == nil {
+		var zero K
+		return zero, false
+	}
+	key := lp.order.Remove(element).(K)
+	delete(lp.elements, key)
+	return key, true
+}
+
+// front returns the next victim without evicting it.
+func (lp *listPolicy[K]) front() (K, bool) {
+	element := lp.order.Front()
+	if element == nil {
+		var zero K
+		return zero, false
+	}
+	return element.Value.(K), true
+}
+
+func (lp *listPolicy[K]) Reset() {
+	lp.order.Init()
+	lp.elements = make(map[K]*list.Element)
+}
+
+func (lp *listPolicy[K]) Keys() []K {
+	keys := make([]K, 0, lp.order.Len())
+	for element := lp.order.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(K))
+	}
+	return keys
+}
+
+// sizedEntry is a key tracked in a sizedList with the size of its value.
+type sizedEntry[K comparable] struct {
+	key     K
+	size    int
+	owner   *sizedList[K]
+	element *list.Element
+}
+
+// sizedList is a list of keys, the least recent entry at the front. It
+// keeps the total size of its entries in bytes so size aware policies can
+// split the cache between several lists.
+type sizedList[K comparable] struct {
+	entries *list.List
+	bytes   int
+}
+
+func newSizedList[K comparable]() *sizedList[K] {
+	return &sizedList[K]{entries: list.New()}
+}
+
+func (sl *sizedList[K]) pushBack(entry *sizedEntry[K]) {
+	entry.owner = sl
+	entry.element = sl.entries.PushBack(entry)
+	sl.bytes = sl.bytes + entry.size
+}
+
+func (sl *sizedList[K]) remove(entry *sizedEntry[K]) {
+	sl.entries.Remove(entry.element)
+	sl.bytes = sl.bytes - entry.size
+	entry.owner = nil
+	entry.element = nil
+}
+
+func (sl *sizedList[K]) front() *sizedEntry[K] {
+	element := sl.entries.Front()
+	if element == nil {
+		return nil
+	}
+	return element.Value.(*sizedEntry[K])
+}
+
+// keys lists the keys of the list from front to back.
+func (sl *sizedList[K]) keys() []K {
+	keys := make([]K, 0, sl.entries.Len())
+	for element := sl.entries.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(*sizedEntry[K]).key)
+	}
+	return keys
+}
+
+// NewLRUPolicy returns a policy evicting the least recently used key first.
+// It is the default policy of VolatileLRUCache.
+func NewLRUPolicy[K comparable](maxSize int) EvictionPolicy[K] {
+	return newListPolicy[K](true)
+}
+
+// NewFIFOPolicy returns a policy evicting the oldest set key first, reads
+// don't change the order.
+func NewFIFOPolicy[K comparable](maxSize int) EvictionPolicy[K] {
+	return newListPolicy[K](false)
+}
+
+// randomPolicy keeps the keys in a slice to pick a random victim in
+// constant time.
+type randomPolicy[K comparable] struct {
+	keys  []K
+	index map[K]int
+}
+
+// NewRandomPolicy returns a policy evicting a random key. It is the default
+// policy of Cache.
+func NewRandomPolicy[K comparable](maxSize int) EvictionPolicy[K] {
+	return &randomPolicy[K]{index: make(map[K]int)}
+}
+
+func (rp *randomPolicy[K]) OnAccess(key K) {}
+
+func (rp *randomPolicy[K]) OnInsert(key K, size int) {
+	if _, ok := rp.index[key]; ok {
+		return
+	}
+	rp.index[key] = len(rp.keys)
+	rp.keys = append(rp.keys, key)
+}
+
+func (rp *randomPolicy[K]) OnRemove(key K) {
+	position, ok := rp.index[key]
+	if !ok {
+		return
+	}
+	// move the last key in the hole to keep the slice dense
+	last := len(rp.keys) - 1
+	rp.keys[position] = rp.keys[last]
+	rp.index[rp.keys[position]] = position
+	rp.keys = rp.keys[:last]
+	delete(rp.index, key)
+}
+
+func (rp *randomPolicy[K]) Victim() (K, bool) {
+	if len(rp.keys) == 0 {
+		var zero K
+		return zero, false
+	}
+	key := rp.keys[rand.Intn(len(rp.keys))]
+	rp.OnRemove(key)
+	return key, true
+}
+
+func (rp *randomPolicy[K]) Reset() {
+	rp.keys = nil
+	rp.index = make(map[K]int)
+}
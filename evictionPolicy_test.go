@@ -0,0 +1,105 @@
+package spectre
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRUPolicy(t *testing.T) {
+	policy := NewLRUPolicy[string](0)
+	policy.OnInsert("a", 1)
+	policy.OnInsert("b", 1)
+	policy.OnInsert("c", 1)
+	policy.OnAccess("a")
+	policy.OnRemove("b")
+	if keys := policy.(OrderedPolicy[string]).Keys(); !reflect.DeepEqual(keys, []string{"c", "a"}) {
+		t.Fatalf("unexpected lru order %v", keys)
+	}
+	if victim, _ := policy.Victim(); victim != "c" {
+		t.Fatalf("expected c as lru victim got %v", victim)
+	}
+	if victim, _ := policy.Victim(); victim != "a" {
+		t.Fatalf("expected a as lru victim got %v", victim)
+	}
+	if _, ok := policy.Victim(); ok {
+		t.Fatalf("empty policy returned a victim")
+	}
+}
+
+func TestFIFOPolicy(t *testing.T) {
+	policy := NewFIFOPolicy[string](0)
+	policy.OnInsert("a", 1)
+	policy.OnInsert("b", 1)
+	policy.OnAccess("a")
+	if victim, _ := policy.Victim(); victim != "a" {
+		t.Fatalf("expected a as fifo victim got %v", victim)
+	}
+	policy.Reset()
+	if _, ok := policy.Victim(); ok {
+		t.Fatalf("reset policy returned a victim")
+	}
+}
+
+func TestRandomPolicy(t *testing.T) {
+	policy := NewRandomPolicy[string](0)
+	expected := map[string]bool{"a": true, "b": true, "c": true}
+	for key := range expected {
+		policy.OnInsert(key, 1)
+	}
+	policy.OnRemove("b")
+	delete(expected, "b")
+	for i := 0; i < 2; i++ {
+		victim, ok := policy.Victim()
+		if !ok || !expected[victim] {
+			t.Fatalf("unexpected random victim %v", victim)
+		}
+		delete(expected, victim)
+	}
+	if _, ok := policy.Victim(); ok {
+		t.Fatalf("empty policy returned a victim")
+	}
+}
+
+func TestCacheEvictionPolicy(t *testing.T) {
+	cache, _ := NewCache(WithMaxSize(10), WithEvictionPolicy(NewFIFOPolicy[string]))
+	cache.CacheSet("a", "a", 4)
+	cache.CacheSet("b", "b", 4)
+	cache.CacheGet("a")
+	if success, err := cache.CacheSet("c", "c", 4); !success {
+		t.Fatalf("set with eviction failed %v", err)
+	}
+	if _, ok := cache.CacheGet("a"); ok {
+		t.Fatalf("fifo policy did not evict the oldest key")
+	}
+	if cache.GetCurrentSize() != 8 || cache.CurrentSize != 8 {
+		t.Fatalf("cache size calculation is incorrect")
+	}
+}
+
+func TestVolatileLRUCacheEvictionPolicy(t *testing.T) {
+	cache, _ := New(WithMaxSize(10), WithTTL(time.Hour), WithEvictionPolicy(NewFIFOPolicy[string]))
+	cache.VolatileLRUCacheSet("a", "a", 4, time.Duration(0))
+	cache.VolatileLRUCacheSet("b", "b", 4, time.Duration(0))
+	cache.VolatileLRUCacheGet("a")
+	cache.VolatileLRUCacheSet("c", "c", 4, time.Duration(0))
+	if _, ok := cache.VolatileLRUCacheGet("a"); ok {
+		t.Fatalf("fifo policy did not evict the oldest key")
+	}
+	if _, ok := cache.linkMap["a"]; ok {
+		t.Fatalf("ttl link of the evicted key is still present")
+	}
+}
+
+func TestEvictionMakesEnoughSpace(t *testing.T) {
+	cache := GetDefaultCache(100, 4)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		cache.CacheSet(key, key, 20)
+	}
+	if success, err := cache.CacheSet("big", "big", 70); !success {
+		t.Fatalf("set with eviction failed %v", err)
+	}
+	if cache.CurrentSize > 100 {
+		t.Fatalf("cache grew over its max size %v", cache.CurrentSize)
+	}
+}
@@ -0,0 +1,146 @@
+package spectre
+
+import (
+	"hash/maphash"
+)
+
+const (
+	// sketchDepth is the number of counter rows of the count-min sketch.
+	sketchDepth = 4
+	// sketchMaxCount is the value at which a counter stops growing.
+	sketchMaxCount = 15
+	// sketchSampleFactor times the width is the number of additions after
+	// which the counters are halved.
+	sketchSampleFactor = 10
+)
+
+// frequencySketch estimates how often a key has been seen with a count-min
+// sketch of small saturating counters. A doorkeeper bloom filter absorbs the
+// first occurrence of every key so one-hit wonders never reach the counters.
+// After a sample of additions every counter is halved and the doorkeeper is
+// cleared, so old popularity fades away.
+type frequencySketch[K comparable] struct {
+	seed       maphash.Seed
+	rows       [sketchDepth][]uint8
+	doorkeeper []uint64
+	mask       uint32
+	additions  int
+	sampleSize int
+}
+
+// newFrequencySketch returns a sketch sized for about expectedEntries keys.
+func newFrequencySketch[K comparable](expectedEntries int) *frequencySketch[K] {
+	width := 16
+	for width < expectedEntries {
+		width = width * 2
+	}
+	fs := &frequencySketch[K]{
+		seed:       maphash.MakeSeed(),
+		doorkeeper: make([]uint64, width/8),
+		mask:       uint32(width - 1),
+		sampleSize: sketchSampleFactor * width,
+	}
+	for i := range fs.rows {
+		fs.rows[i] = make([]uint8, width)
+	}
+	return fs
+}
+
+// hashes returns the two halves of the 64 bit hash of the key, combined to
+// derive the index of the key in every row.
+func (fs *frequencySketch[K]) hashes(key K) (uint32, uint32) {
+	sum := maphash.Comparable(fs.seed, key)
+	return uint32(sum), uint32(sum>>32) | 1
+}
+
+// index returns the position of the key in the given row.
+func (fs *frequencySketch[K]) index(h1 uint32, h2 uint32, row int) uint32 {
+	return (h1 + uint32(row)*h2) & fs.mask
+}
+
+// inDoorkeeper tells if the key has already been seen since the last aging.
+// With add it also records the key.
+func (fs *frequencySketch[K]) inDoorkeeper(h1 uint32, h2 uint32, add bool) bool {
+	bits := uint32(len(fs.doorkeeper) * 64)
+	found := true
+	for i := uint32(0); i < 2; i++ {
+		bit := (h1 + i*h2) % bits
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if fs.doorkeeper[word]&mask == 0 {
+			found = false
+			if add {
+				fs.doorkeeper[word] |= mask
+			}
+		}
+	}
+	return found
+}
+
+// Increment records an occurrence of the key.
+func (fs *frequencySketch[K]) Increment(key K) {
+	h1, h2 := fs.hashes(key)
+	if fs.inDoorkeeper(h1, h2, true) {
+		// conservative update, only the smallest counters grow
+		smallest := fs.count(h1, h2)
+		if smallest < sketchMaxCount {
+			for row := range fs.rows {
+				position := fs.index(h1, h2, row)
+				if fs.rows[row][position] == smallest {
+					fs.rows[row][position]++
+				}
+			}
+		}
+	}
+	fs.additions++
+	if fs.additions >= fs.sampleSize {
+		fs.age()
+	}
+}
+
+// count returns the smallest counter of the key.
+func (fs *frequencySketch[K]) count(h1 uint32, h2 uint32) uint8 {
+	smallest := uint8(sketchMaxCount)
+	for row := range fs.rows {
+		if value := fs.rows[row][fs.index(h1, h2, row)]; value < smallest {
+			smallest = value
+		}
+	}
+	return smallest
+}
+
+// Estimate returns the estimated number of occurrences of the key since
+// it started to be tracked, faded by the periodic aging.
+func (fs *frequencySketch[K]) Estimate(key K) int {
+	h1, h2 := fs.hashes(key)
+	estimate := int(fs.count(h1, h2))
+	if fs.inDoorkeeper(h1, h2, false) {
+		estimate++
+	}
+	return estimate
+}
+
+// age halves every counter and clears the doorkeeper.
+func (fs *frequencySketch[K]) age() {
+	for row := range fs.rows {
+		for i := range fs.rows[row] {
+			fs.rows[row][i] = fs.rows[row][i] / 2
+		}
+	}
+	for i := range fs.doorkeeper {
+		fs.doorkeeper[i] = 0
+	}
+	fs.additions = fs.additions / 2
+}
+
+// Reset forgets every occurrence.
+func (fs *frequencySketch[K]) Reset() {
+	for row := range fs.rows {
+		for i := range fs.rows[row] {
+			fs.rows[row][i] = 0
+		}
+	}
+	for i := range fs.doorkeeper {
+		fs.doorkeeper[i] = 0
+	}
+	fs.additions = 0
+}
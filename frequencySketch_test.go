@@ -0,0 +1,45 @@
+package spectre
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFrequencySketchEstimate(t *testing.T) {
+	sketch := newFrequencySketch[string](64)
+	if estimate := sketch.Estimate("vivek"); estimate != 0 {
+		t.Fatalf("unseen key estimated at %v", estimate)
+	}
+	sketch.Increment("vivek")
+	if estimate := sketch.Estimate("vivek"); estimate != 1 {
+		t.Fatalf("doorkeeper should count the first occurrence got %v", estimate)
+	}
+	for i := 0; i < 4; i++ {
+		sketch.Increment("vivek")
+	}
+	if estimate := sketch.Estimate("vivek"); estimate != 5 {
+		t.Fatalf("expected estimate of 5 got %v", estimate)
+	}
+	for i := 0; i < 100; i++ {
+		sketch.Increment("vivek")
+	}
+	if estimate := sketch.Estimate("vivek"); estimate > sketchMaxCount+1 {
+		t.Fatalf("counters did not saturate %v", estimate)
+	}
+}
+
+func TestFrequencySketchAging(t *testing.T) {
+	sketch := newFrequencySketch[string](16)
+	for i := 0; i < 9; i++ {
+		sketch.Increment("vivek")
+	}
+	before := sketch.Estimate("vivek")
+	// reaching the sample size halves every counter
+	remaining := sketch.sampleSize - sketch.additions
+	for i := 0; i < remaining; i++ {
+		sketch.Increment("other" + strconv.Itoa(i))
+	}
+	if after := sketch.Estimate("vivek"); after >= before {
+		t.Fatalf("aging did not fade the estimate %v >= %v", after, before)
+	}
+}
@@ -0,0 +1,140 @@
+package spectre
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// gdsfEntry is a key tracked by the GDSF policy with its priority.
+type gdsfEntry[K comparable] struct {
+	key       K
+	size      int
+	cost      float64
+	frequency int
+	priority  float64
+	index     int
+}
+
+// gdsfQueue is a min heap of entries on their priority, the next victim
+// at the top.
+type gdsfQueue[K comparable] []*gdsfEntry[K]
+
+func (gq gdsfQueue[K]) Len() int { return len(gq) }
+
+func (gq gdsfQueue[K]) Less(i, j int) bool { return gq[i].priority < gq[j].priority }
+
+func (gq gdsfQueue[K]) Swap(i, j int) {
+	gq[i], gq[j] = gq[j], gq[i]
+	gq[i].index = i
+	gq[j].index = j
+}
+
+func (gq *gdsfQueue[K]) Push(x interface{}) {
+	entry := x.(*gdsfEntry[K])
+	entry.index = len(*gq)
+	*gq = append(*gq, entry)
+}
+
+func (gq *gdsfQueue[K]) Pop() interface{} {
+	old := *gq
+	last := len(old) - 1
+	entry := old[last]
+	old[last] = nil
+	*gq = old[:last]
+	entry.index = -1
+	return entry
+}
+
+// gdsfPolicy is the GreedyDual-Size-Frequency policy. Every key gets the
+// priority
+//		clock + frequency * cost / size
+// and the key of lowest priority is evicted first, so large cheap values go
+// before small expensive ones. The clock inflates to the priority of every
+// victim, which ages the keys not used since.
+type gdsfPolicy[K comparable] struct {
+	clock   float64
+	queue   gdsfQueue[K]
+	entries map[K]*gdsfEntry[K]
+}
+
+// NewGDSFPolicy returns a GreedyDual-Size-Frequency policy. The cost of a
+// key is given with the WithCost set option and defaults to 1.
+func NewGDSFPolicy[K comparable](maxSize int) EvictionPolicy[K] {
+	return &gdsfPolicy[K]{entries: make(map[K]*gdsfEntry[K])}
+}
+
+// prioritize computes the priority of the entry against the current clock
+// and restores its place in the heap.
+func (gp *gdsfPolicy[K]) prioritize(entry *gdsfEntry[K]) {
+	size := entry.size
+	if size <= 0 {
+		size = 1
+	}
+	entry.priority = gp.clock + float64(entry.frequency)*entry.cost/float64(size)
+	if entry.index >= 0 {
+		heap.Fix(&gp.queue, entry.index)
+	}
+}
+
+func (gp *gdsfPolicy[K]) OnAccess(key K) {
+	if entry, ok := gp.entries[key]; ok {
+		entry.frequency++
+		gp.prioritize(entry)
+	}
+}
+
+func (gp *gdsfPolicy[K]) OnInsert(key K, size int) {
+	gp.OnInsertWithCost(key, size, 1)
+}
+
+func (gp *gdsfPolicy[K]) OnInsertWithCost(key K, size int, cost float64) {
+	entry, ok := gp.entries[key]
+	if !ok {
+		entry = &gdsfEntry[K]{key: key, index: -1}
+		gp.entries[key] = entry
+	}
+	entry.size = size
+	entry.cost = cost
+	entry.frequency++
+	gp.prioritize(entry)
+	if !ok {
+		heap.Push(&gp.queue, entry)
+	}
+}
+
+func (gp *gdsfPolicy[K]) OnRemove(key K) {
+	if entry, ok := gp.entries[key]; ok {
+		heap.Remove(&gp.queue, entry.index)
+		delete(gp.entries, key)
+	}
+}
+
+func (gp *gdsfPolicy[K]) Victim() (K, bool) {
+	if len(gp.queue) == 0 {
+		var zero K
+		return zero, false
+	}
+	entry := heap.Pop(&gp.queue).(*gdsfEntry[K])
+	delete(gp.entries, entry.key)
+	// inflation, the next keys compete with what the victim was worth
+	gp.clock = entry.priority
+	return entry.key, true
+}
+
+func (gp *gdsfPolicy[K]) Reset() {
+	gp.clock = 0
+	gp.queue = nil
+	gp.entries = make(map[K]*gdsfEntry[K])
+}
+
+// Keys lists the keys from the lowest to the highest priority.
+func (gp *gdsfPolicy[K]) Keys() []K {
+	entries := make([]*gdsfEntry[K], len(gp.queue))
+	copy(entries, gp.queue)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].priority < entries[j].priority })
+	keys := make([]K, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.key
+	}
+	return keys
+}
@@ -0,0 +1,55 @@
+package spectre
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGDSFPolicyVictim(t *testing.T) {
+	policy := NewGDSFPolicy[string](0).(CostAwarePolicy[string])
+	policy.OnInsertWithCost("large-cheap", 100, 1)
+	policy.OnInsertWithCost("small-expensive", 10, 50)
+	policy.OnInsertWithCost("small-cheap", 10, 1)
+	if victim, _ := policy.Victim(); victim != "large-cheap" {
+		t.Fatalf("expected large cheap value to go first got %v", victim)
+	}
+	policy.OnAccess("small-cheap")
+	if victim, _ := policy.Victim(); victim != "small-cheap" {
+		t.Fatalf("expected small cheap value to go next got %v", victim)
+	}
+	if keys := policy.(OrderedPolicy[string]).Keys(); len(keys) != 1 || keys[0] != "small-expensive" {
+		t.Fatalf("unexpected keys left %v", keys)
+	}
+}
+
+func TestGDSFPolicyInflation(t *testing.T) {
+	policy := NewGDSFPolicy[string](0).(*gdsfPolicy[string])
+	policy.OnInsert("old", 1)
+	policy.OnInsert("victim", 2)
+	policy.Victim()
+	if policy.clock != 0.5 {
+		t.Fatalf("clock did not inflate to the victim priority got %v", policy.clock)
+	}
+	// a new key with the same worth as old now outranks it
+	policy.OnInsert("new", 1)
+	if victim, _ := policy.Victim(); victim != "old" {
+		t.Fatalf("expected the aged key to go first got %v", victim)
+	}
+	policy.OnRemove("new")
+	if _, ok := policy.Victim(); ok {
+		t.Fatalf("empty policy returned a victim")
+	}
+}
+
+func TestVolatileLRUCacheWithCost(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithEvictionPolicy(NewGDSFPolicy[string]))
+	cache.VolatileLRUCacheSet("expensive", "expensive", 40, time.Duration(0), WithCost(1000))
+	cache.VolatileLRUCacheSet("cheap", "cheap", 40, time.Duration(0))
+	cache.VolatileLRUCacheSet("new", "new", 40, time.Duration(0), WithCost(10))
+	if _, ok := cache.VolatileLRUCacheGet("cheap"); ok {
+		t.Fatalf("cheap value should have been evicted first")
+	}
+	if _, ok := cache.VolatileLRUCacheGet("expensive"); !ok {
+		t.Fatalf("expensive value got evicted")
+	}
+}
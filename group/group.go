@@ -0,0 +1,243 @@
+// Package group shares a cache between the peers of a service the way
+// groupcache does. Every peer owns a slice of the keys on a consistent hash
+// ring : a key missing on a peer is asked over HTTP to its owner, and only
+// the owner runs the getter and caches the value, so a key is loaded once
+// for the whole service instead of once per peer. The hot keys of the other
+// peers are mirrored into a small local cache.
+//
+// A Group is an http.Handler serving its keys to the other peers under
+// DefaultBasePath, or the path of WithBasePath, followed by its name :
+//
+//	users, err := group.New("users", getter)
+//	http.Handle(group.DefaultBasePath, users)
+//	users.SetPeers("http://10.0.0.1:8080", "http://10.0.0.1:8080", "http://10.0.0.2:8080")
+//	value, err := users.Get(ctx, "vivek")
+package group
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+// TTLHeader carries the time left before an owned key expires, with the
+// format of time.Duration, to the peer mirroring it.
+const TTLHeader = "X-Spectre-TTL"
+
+// Group is a cache of byte values shared by the peers of a service.
+type Group struct {
+	name     string
+	getter   spectre.Loader[string, []byte]
+	main     *spectre.VolatileLRU[string, []byte]
+	hot      *spectre.VolatileLRU[string, []byte]
+	hotTTL   time.Duration
+	replicas int
+	basePath string
+	client   *http.Client
+
+	// self and ring are replaced together by SetPeers
+	self string
+	ring *Ring
+	sync.RWMutex
+}
+
+// New returns a Group loading its keys with the getter. Until SetPeers is
+// called the group owns every key.
+// input params :
+//				name: name of the group, the same on every peer.
+//				getter: loads a key owned by the peer, with its size and ttl.
+//				opts: options like WithCacheOptions or WithHotCache.
+// return values :
+//		group: the configured group
+//		error: InvalidGroupError for a bad input, or the error of the
+//			   options of the caches
+func New(name string, getter spectre.Loader[string, []byte], opts ...Option) (*Group, error) {
+	if name == "" || strings.Contains(name, "/") || getter == nil {
+		return nil, InvalidGroupError
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	main, err := spectre.NewVolatileLRU[string, []byte](cfg.cacheOptions...)
+	if err != nil {
+		return nil, err
+	}
+	g := &Group{
+		name:     name,
+		getter:   getter,
+		main:     main,
+		hotTTL:   cfg.hotTTL,
+		replicas: cfg.replicas,
+		basePath: cfg.basePath,
+		client:   cfg.client,
+	}
+	if cfg.hotSize > 0 {
+		g.hot, err = spectre.NewVolatileLRU[string, []byte](
+			spectre.WithMaxSize(cfg.hotSize),
+			spectre.WithTTL(cfg.hotTTL),
+			spectre.WithEvictionPolicy(spectre.NewTinyLFUPolicy[string]),
+		)
+		if err != nil {
+			main.Close(context.Background())
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Name returns the name of the group.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// SetPeers sets the base URLs of the peers sharing the group, like
+// "http://10.0.0.1:8080", self being the one of this peer. Every peer must
+// be given the same list, self is added to it when missing.
+func (g *Group) SetPeers(self string, peers ...string) {
+	self = strings.TrimSuffix(self, "/")
+	all := []string{self}
+	for _, peer := range peers {
+		if peer = strings.TrimSuffix(peer, "/"); peer != self {
+			all = append(all, peer)
+		}
+	}
+	ring := NewRing(g.replicas, all...)
+	g.Lock()
+	defer g.Unlock()
+	g.self = self
+	g.ring = ring
+}
+
+// owner returns the base URL of the peer owning the key, an empty string
+// when it is this peer.
+func (g *Group) owner(key string) string {
+	g.RLocker().Lock()
+	defer g.RLocker().Unlock()
+	if g.ring == nil {
+		return ""
+	}
+	if peer := g.ring.Get(key); peer != g.self {
+		return peer
+	}
+	return ""
+}
+
+// Get returns the value of the key : from the cache of the owned keys or
+// the hot keys when it is there, else from the getter when this peer owns
+// the key or from the owner. Concurrent misses of a key share one load.
+// When the owner can not be reached the key is loaded here, so a peer
+// going down does not fail the reads of its keys.
+// return values :
+//		value: value corresponding to the key, it must not be modified
+//		error: the error of the getter, PeerError wrapping the error of the
+//			   owner, the context error, spectre.ErrClosed after Close
+func (g *Group) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, ok := g.main.Get(key); ok {
+		return value, nil
+	}
+	peer := g.owner(key)
+	if peer == "" {
+		return g.main.GetOrLoad(ctx, key, g.getter)
+	}
+	fetch := func(ctx context.Context, key string) ([]byte, int, time.Duration, error) {
+		return g.fetch(ctx, peer, key)
+	}
+	if g.hot == nil {
+		value, _, _, err := fetch(ctx, key)
+		return value, err
+	}
+	return g.hot.GetOrLoad(ctx, key, fetch)
+}
+
+// fetch gets the key from its owner, with the time the value may be
+// mirrored : the ttl of the hot cache at most.
+func (g *Group) fetch(ctx context.Context, peer string, key string) ([]byte, int, time.Duration, error) {
+	target := peer + g.basePath + url.PathEscape(g.name) + "/" + url.PathEscape(key)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	response, err := g.client.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, 0, ctx.Err()
+		}
+		// the owner is unreachable, the key is loaded here
+		value, size, ttl, err := g.getter(ctx, key)
+		return value, size, g.mirrorTTL(ttl), err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, 0, fmt.Errorf("%w: %s %s", PeerError, response.Status, strings.TrimSpace(string(body)))
+	}
+	ttl, _ := time.ParseDuration(response.Header.Get(TTLHeader))
+	return body, len(body), g.mirrorTTL(ttl), nil
+}
+
+// mirrorTTL returns the ttl of a mirrored key whose owner keeps it ttl,
+// 0 for the global ttl or NoExpiry.
+func (g *Group) mirrorTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > g.hotTTL {
+		return g.hotTTL
+	}
+	return ttl
+}
+
+// ServeHTTP serves the keys of the group to the other peers, loading them
+// with the getter on a miss. A peer asked for a key it does not own, while
+// the peers are changing, serves it all the same.
+func (g *Group) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	prefix := g.basePath + g.name + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+	value, err := g.main.GetOrLoad(r.Context(), key, g.getter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ttl, ok := g.main.TTL(key); ok && ttl != spectre.NoExpiry {
+		w.Header().Set(TTLHeader, ttl.String())
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+// Stats returns the statistics of the cache of the owned keys and of the
+// cache of the hot keys, the zero Stats without hot cache.
+func (g *Group) Stats() (main spectre.Stats, hot spectre.Stats) {
+	main = g.main.Stats()
+	if g.hot != nil {
+		hot = g.hot.Stats()
+	}
+	return main, hot
+}
+
+// Close closes the caches of the group, see VolatileLRU Close.
+func (g *Group) Close(ctx context.Context) error {
+	if g.hot != nil {
+		if err := g.hot.Close(ctx); err != nil {
+			return err
+		}
+	}
+	return g.main.Close(ctx)
+}
@@ -0,0 +1,193 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+// peer is a member of a test group served by httptest.
+type peer struct {
+	group  *Group
+	server *httptest.Server
+	loads  atomic.Int64
+}
+
+// startPeers starts n peers of the group "test", a key "fail..." fails
+// to load and the others load as "value of <key>".
+func startPeers(t *testing.T, n int, opts ...Option) []*peer {
+	peers := make([]*peer, n)
+	var urls []string
+	for i := range peers {
+		p := &peer{}
+		getter := func(ctx context.Context, key string) ([]byte, int, time.Duration, error) {
+			p.loads.Add(1)
+			if len(key) >= 4 && key[:4] == "fail" {
+				return nil, 0, 0, errors.New("no such key")
+			}
+			value := []byte("value of " + key)
+			return value, len(value), time.Minute, nil
+		}
+		group, err := New("test", getter, opts...)
+		if err != nil {
+			t.Fatalf("new group failed %v", err)
+		}
+		p.group = group
+		p.server = httptest.NewServer(group)
+		urls = append(urls, p.server.URL)
+		peers[i] = p
+		t.Cleanup(func() {
+			p.server.Close()
+			group.Close(context.Background())
+		})
+	}
+	for i, p := range peers {
+		p.group.SetPeers(urls[i], urls...)
+	}
+	return peers
+}
+
+func TestGroupLoadsOnOwner(t *testing.T) {
+	peers := startPeers(t, 3)
+	ctx := context.Background()
+	for i := 0; i < 30; i++ {
+		key := "key" + strconv.Itoa(i)
+		for _, p := range peers {
+			value, err := p.group.Get(ctx, key)
+			if err != nil || string(value) != "value of "+key {
+				t.Fatalf("get of %s returned %q %v", key, value, err)
+			}
+		}
+	}
+	total := int64(0)
+	for _, p := range peers {
+		if p.loads.Load() == 0 {
+			t.Fatalf("a peer owns no key")
+		}
+		total += p.loads.Load()
+	}
+	if total != 30 {
+		t.Fatalf("expected every key to load once on its owner, %d loads", total)
+	}
+	_, hot := peers[0].group.Stats()
+	if hot.Entries == 0 {
+		t.Fatalf("remote keys were not mirrored")
+	}
+	// the mirror keeps the ttl left on the owner
+	for i := 0; i < 30; i++ {
+		key := "key" + strconv.Itoa(i)
+		if ttl, ok := peers[0].group.hot.TTL(key); ok && ttl > time.Minute {
+			t.Fatalf("mirrored key outlives its owner copy %v", ttl)
+		}
+	}
+}
+
+func TestGroupSharesConcurrentMisses(t *testing.T) {
+	peers := startPeers(t, 2)
+	// find a key owned by the second peer
+	key := ""
+	for i := 0; key == ""; i++ {
+		if candidate := "key" + strconv.Itoa(i); peers[0].group.owner(candidate) != "" {
+			key = candidate
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := peers[0].group.Get(context.Background(), key); err != nil {
+				t.Errorf("get failed %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peers[0].loads.Load() != 0 || peers[1].loads.Load() != 1 {
+		t.Fatalf("expected a single load on the owner, got %d and %d", peers[0].loads.Load(), peers[1].loads.Load())
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	peers := startPeers(t, 2, WithHotCache(0, 0))
+	ctx := context.Background()
+	owned := int64(0)
+	for i := 0; i < 20; i++ {
+		key := "fail" + strconv.Itoa(i)
+		_, err := peers[0].group.Get(ctx, key)
+		if peers[0].group.owner(key) == "" {
+			owned++
+			if err == nil || errors.Is(err, PeerError) {
+				t.Fatalf("expected the getter error got %v", err)
+			}
+		} else if !errors.Is(err, PeerError) {
+			t.Fatalf("expected a PeerError got %v", err)
+		}
+	}
+
+	// an owner going down does not fail its keys
+	peers[1].server.Close()
+	for i := 0; i < 20; i++ {
+		key := "key" + strconv.Itoa(i)
+		if value, err := peers[0].group.Get(ctx, key); err != nil || string(value) != "value of "+key {
+			t.Fatalf("get of %s with an owner down returned %q %v", key, value, err)
+		}
+	}
+	if peers[0].loads.Load() != owned+20 {
+		t.Fatalf("keys of the peer down were not loaded locally")
+	}
+}
+
+func TestGroupOptions(t *testing.T) {
+	getter := func(ctx context.Context, key string) ([]byte, int, time.Duration, error) {
+		return nil, 0, 0, nil
+	}
+	for _, test := range []struct {
+		name   string
+		getter spectre.Loader[string, []byte]
+		opts   []Option
+		err    error
+	}{
+		{"", getter, nil, InvalidGroupError},
+		{"a/b", getter, nil, InvalidGroupError},
+		{"test", nil, nil, InvalidGroupError},
+		{"test", getter, []Option{WithReplicas(0)}, InvalidGroupError},
+		{"test", getter, []Option{WithBasePath("peers")}, InvalidGroupError},
+		{"test", getter, []Option{WithHotCache(-1, time.Minute)}, InvalidGroupError},
+		{"test", getter, []Option{WithHTTPClient(nil)}, InvalidGroupError},
+		{"test", getter, []Option{WithCacheOptions(spectre.WithMaxSize(0))}, spectre.InvalidSizeError},
+	} {
+		if _, err := New(test.name, test.getter, test.opts...); err != test.err {
+			t.Fatalf("expected %v got %v", test.err, err)
+		}
+	}
+	group, err := New("test", getter, WithBasePath("/peers/"), WithReplicas(10), WithCacheOptions(spectre.WithMaxSize(10)))
+	if err != nil || group.Name() != "test" {
+		t.Fatalf("new group failed %v", err)
+	}
+	group.Close(context.Background())
+}
+
+func BenchmarkGroupGetRemote(b *testing.B) {
+	getter := func(ctx context.Context, key string) ([]byte, int, time.Duration, error) {
+		return []byte(key), len(key), 0, nil
+	}
+	local, _ := New("bench", getter, WithHotCache(0, 0))
+	remote, _ := New("bench", getter)
+	server := httptest.NewServer(remote)
+	defer server.Close()
+	// the local peer owns no key
+	local.SetPeers("http://local.invalid")
+	local.ring = NewRing(DefaultReplicas, server.URL)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		local.Get(ctx, "vivek")
+	}
+}
@@ -0,0 +1,148 @@
+package group
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+const (
+	// DefaultReplicas is the number of virtual nodes of a peer on the ring
+	// when no WithReplicas option is given.
+	DefaultReplicas = 50
+	// DefaultBasePath is the path the groups are served under when no
+	// WithBasePath option is given.
+	DefaultBasePath = "/_spectre/"
+	// DefaultCacheSize is the size in bytes of the cache of the owned keys
+	// when WithCacheOptions does not give one.
+	DefaultCacheSize = 64 << 20
+	// DefaultTTL is the time to live of the owned keys when neither the
+	// getter nor WithCacheOptions give one.
+	DefaultTTL = time.Hour
+	// DefaultHotCacheSize and DefaultHotTTL configure the cache of the hot
+	// keys of the other peers when no WithHotCache option is given.
+	DefaultHotCacheSize = DefaultCacheSize / 8
+	DefaultHotTTL       = time.Minute
+)
+
+// groupError is the error which is thrown when a group is misconfigured or
+// its peers fail.
+type groupError struct {
+	errorNumber int
+	problem     string
+}
+
+func (ge *groupError) Error() string {
+	return fmt.Sprintf("%d---%s", ge.errorNumber, ge.problem)
+}
+
+var (
+	// PeerError returns when the peer owning a key fails to serve it, it is
+	// wrapped with the reply of the peer
+	PeerError = &groupError{problem: "peer failed to serve the key", errorNumber: 26}
+	// InvalidGroupError returns when a group option is not valid : an empty
+	// name, a nil getter or http client, replicas or a hot cache size which
+	// are not positive or a base path not starting and ending with a slash
+	InvalidGroupError = &groupError{problem: "group name, getter and options must be valid", errorNumber: 27}
+)
+
+// config keeps the settings collected from the options given to New.
+type config struct {
+	cacheOptions []spectre.Option
+	hotSize      int
+	hotTTL       time.Duration
+	replicas     int
+	basePath     string
+	client       *http.Client
+}
+
+// defaultConfig returns the settings used for everything not given as an option.
+func defaultConfig() *config {
+	return &config{
+		cacheOptions: []spectre.Option{spectre.WithMaxSize(DefaultCacheSize), spectre.WithTTL(DefaultTTL)},
+		hotSize:      DefaultHotCacheSize,
+		hotTTL:       DefaultHotTTL,
+		replicas:     DefaultReplicas,
+		basePath:     DefaultBasePath,
+		client:       http.DefaultClient,
+	}
+}
+
+// Option configures a group built by New.
+type Option func(*config) error
+
+// WithCacheOptions configures the cache of the keys owned by the peer, the
+// options are applied over a max size of DefaultCacheSize and a ttl of
+// DefaultTTL.
+func WithCacheOptions(opts ...spectre.Option) Option {
+	return func(cfg *config) error {
+		cfg.cacheOptions = append(cfg.cacheOptions, opts...)
+		return nil
+	}
+}
+
+// WithHotCache sets the size in bytes and the time to live of the cache
+// mirroring the hot keys of the other peers, a size of 0 disables it. The
+// cache admits keys with W-TinyLFU once full, so the keys read once stay
+// out of it. A mirrored key lives at most ttl, so a key changing on its
+// owner may be stale that long.
+func WithHotCache(size int, ttl time.Duration) Option {
+	return func(cfg *config) error {
+		if size < 0 || (size > 0 && ttl <= 0) {
+			return InvalidGroupError
+		}
+		cfg.hotSize = size
+		cfg.hotTTL = ttl
+		return nil
+	}
+}
+
+// WithReplicas sets the number of virtual nodes of every peer on the ring,
+// all the peers must use the same number.
+func WithReplicas(replicas int) Option {
+	return func(cfg *config) error {
+		if replicas <= 0 {
+			return InvalidGroupError
+		}
+		cfg.replicas = replicas
+		return nil
+	}
+}
+
+// WithBasePath sets the path the groups are served under, all the peers
+// must use the same path.
+func WithBasePath(path string) Option {
+	return func(cfg *config) error {
+		if !strings.HasPrefix(path, "/") || !strings.HasSuffix(path, "/") {
+			return InvalidGroupError
+		}
+		cfg.basePath = path
+		return nil
+	}
+}
+
+// WithHTTPClient sets the client used to get the keys from the other
+// peers, http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cfg *config) error {
+		if client == nil {
+			return InvalidGroupError
+		}
+		cfg.client = client
+		return nil
+	}
+}
+
+// newConfig applies the options over the defaults.
+func newConfig(opts []Option) (*config, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
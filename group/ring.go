@@ -0,0 +1,53 @@
+package group
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// Ring spreads the keys over peers with consistent hashing : every peer is
+// placed at several points of a circle of hashes, its virtual nodes, and a
+// key belongs to the peer of the first virtual node at or after its hash.
+// Adding or removing a peer only moves the keys of its virtual nodes. A
+// Ring is not changed once built, so it is safe for concurrent use.
+type Ring struct {
+	hashes []uint32
+	peers  map[uint32]string
+}
+
+// NewRing returns a Ring of the peers, each with replicas virtual nodes.
+func NewRing(replicas int, peers ...string) *Ring {
+	ring := &Ring{peers: make(map[uint32]string)}
+	for _, peer := range peers {
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + peer))
+			if _, taken := ring.peers[hash]; taken {
+				// a collision keeps the smallest peer so every ring of
+				// the same peers agrees whatever their order
+				if ring.peers[hash] < peer {
+					continue
+				}
+			} else {
+				ring.hashes = append(ring.hashes, hash)
+			}
+			ring.peers[hash] = peer
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+// Get returns the peer owning the key, an empty string for an empty ring.
+func (ring *Ring) Get(key string) string {
+	if len(ring.hashes) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(ring.hashes), func(i int) bool { return ring.hashes[i] >= hash })
+	if i == len(ring.hashes) {
+		// past the last virtual node the circle starts again
+		i = 0
+	}
+	return ring.peers[ring.hashes[i]]
+}
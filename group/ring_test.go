@@ -0,0 +1,52 @@
+package group
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	if NewRing(DefaultReplicas).Get("vivek") != "" {
+		t.Fatalf("empty ring returned a peer")
+	}
+	peers := []string{"http://a", "http://b", "http://c"}
+	ring := NewRing(DefaultReplicas, peers...)
+	reversed := NewRing(DefaultReplicas, peers[2], peers[1], peers[0])
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		key := "key" + strconv.Itoa(i)
+		owner := ring.Get(key)
+		if owner != reversed.Get(key) {
+			t.Fatalf("rings of the same peers disagree on %s", key)
+		}
+		counts[owner]++
+	}
+	for _, peer := range peers {
+		if counts[peer] < 500 {
+			t.Fatalf("keys are badly spread %v", counts)
+		}
+	}
+
+	// a new peer only takes keys, the others keep their owner
+	grown := NewRing(DefaultReplicas, append(peers, "http://d")...)
+	moved := 0
+	for i := 0; i < 3000; i++ {
+		key := "key" + strconv.Itoa(i)
+		if owner := grown.Get(key); owner != ring.Get(key) {
+			if owner != "http://d" {
+				t.Fatalf("%s moved from %s to %s", key, ring.Get(key), owner)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 1500 {
+		t.Fatalf("expected about a quarter of the keys to move, %d did", moved)
+	}
+}
+
+func BenchmarkRingGet(b *testing.B) {
+	ring := NewRing(DefaultReplicas, "http://a", "http://b", "http://c")
+	for i := 0; i < b.N; i++ {
+		ring.Get("vivek")
+	}
+}
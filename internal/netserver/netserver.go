@@ -0,0 +1,148 @@
+// Package netserver runs the connections of the resp and memcache
+// front-ends. It accepts them on TCP or Unix listeners, serves each one from
+// a goroutine of its own and closes the listeners and the connections on
+// shutdown.
+package netserver
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// serverError is the error which is thrown when a closed server is used.
+type serverError struct {
+	errorNumber int
+	problem     string
+}
+
+func (se *serverError) Error() string {
+	return fmt.Sprintf("%d---%s", se.errorNumber, se.problem)
+}
+
+// NewError returns the error a front-end returns once closed, with its
+// number and problem.
+func NewError(errorNumber int, problem string) error {
+	return &serverError{problem: problem, errorNumber: errorNumber}
+}
+
+// Server serves the connections of its listeners with a handler.
+type Server struct {
+	handle    func(conn net.Conn)
+	closedErr error
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+	sync.Mutex
+}
+
+// New returns a Server running handle for every connection. The connection
+// is closed once handle returns. closedErr is returned by Serve after
+// Close.
+func New(handle func(conn net.Conn), closedErr error) *Server {
+	return &Server{
+		handle:    handle,
+		closedErr: closedErr,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the address of the network, "tcp" or "unix",
+// and serves the connections, see Serve.
+func (s *Server) ListenAndServe(network string, address string) error {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts the connections of the listener until it fails or the
+// server is closed. The listener is closed on return.
+// return values :
+//		error: the closed error after Close else the error of the listener
+func (s *Server) Serve(listener net.Listener) error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		listener.Close()
+		return s.closedErr
+	}
+	s.listeners[listener] = struct{}{}
+	s.Unlock()
+	defer func() {
+		s.Lock()
+		delete(s.listeners, listener)
+		s.Unlock()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return s.closedErr
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return s.closedErr
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close closes the listeners and the connections of the server, and waits
+// for the handlers in flight.
+func (s *Server) Close() {
+	s.Lock()
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) isClosed() bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.closed
+}
+
+// track registers a connection, it returns false once the server is closed.
+func (s *Server) track(conn net.Conn) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// Clients returns the number of open connections.
+func (s *Server) Clients() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.conns)
+}
+
+// serveConn runs the handler of a connection and closes it.
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.Lock()
+		delete(s.conns, conn)
+		s.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+	s.handle(conn)
+}
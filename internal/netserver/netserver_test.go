@@ -0,0 +1,73 @@
+package netserver
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var errClosed = NewError(99, "test server closed")
+
+func TestServeAndClose(t *testing.T) {
+	started := make(chan struct{}, 2)
+	server := New(func(conn net.Conn) {
+		started <- struct{}{}
+		io.Copy(conn, conn)
+	}, errClosed)
+	done := make(chan error, 2)
+	var addresses []string
+	for _, listen := range []struct{ network, address string }{{"tcp", "127.0.0.1:0"}, {"unix", filepath.Join(t.TempDir(), "netserver.sock")}} {
+		listener, err := net.Listen(listen.network, listen.address)
+		if err != nil {
+			t.Fatalf("listen failed %v", err)
+		}
+		addresses = append(addresses, listener.Addr().String())
+		go func() {
+			done <- server.Serve(listener)
+		}()
+	}
+	var conns []net.Conn
+	for i, network := range []string{"tcp", "unix"} {
+		conn, err := net.Dial(network, addresses[i])
+		if err != nil {
+			t.Fatalf("dial failed %v", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+		<-started
+	}
+	io.WriteString(conns[0], "vivek")
+	reply := make([]byte, 5)
+	conns[0].SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(conns[0], reply); err != nil || string(reply) != "vivek" {
+		t.Fatalf("expected the echo got %q %v", reply, err)
+	}
+	if clients := server.Clients(); clients != 2 {
+		t.Fatalf("expected 2 clients got %d", clients)
+	}
+
+	server.Close()
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != errClosed {
+			t.Fatalf("expected the closed error got %v", err)
+		}
+	}
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Read(reply); err != io.EOF {
+			t.Fatalf("connection was not closed %v", err)
+		}
+	}
+	if clients := server.Clients(); clients != 0 {
+		t.Fatalf("expected no client left got %d", clients)
+	}
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	if err := server.Serve(listener); err != errClosed {
+		t.Fatalf("expected the closed error after Close got %v", err)
+	}
+	if errClosed.Error() != "99---test server closed" {
+		t.Fatalf("bad error %v", errClosed)
+	}
+}
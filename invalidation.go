@@ -0,0 +1,217 @@
+package spectre
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+)
+
+// Invalidation is a change of a cache which the other caches holding the
+// same keys must apply : the key was set or deleted, the keys of a tag were
+// invalidated, or the cache cleared.
+type Invalidation struct {
+	// Source identifies the cache which published the invalidation.
+	Source string
+	// Seq numbers the invalidations of the source from 1.
+	Seq uint64
+	// Key is the key written by the Codec of the cache, nil with Clear or
+	// a Tag.
+	Key []byte
+	// Clear drops every key instead of a single one.
+	Clear bool
+	// Tag drops the keys of the tag instead of a single one, see WithTags.
+	Tag string
+}
+
+// InvalidationTransport carries the invalidations between the caches given
+// it with WithInvalidation. A transport may deliver an invalidation more
+// than once, or back to its source, the caches drop them.
+type InvalidationTransport interface {
+	// Publish sends the invalidation to the other caches. It is called
+	// outside the lock of the cache, once the change is applied.
+	Publish(invalidation Invalidation) error
+	// Subscribe registers the handler receiving the invalidations of the
+	// other caches, the returned function unregisters it.
+	Subscribe(handler func(Invalidation)) (unsubscribe func())
+}
+
+// maxInvalidationSources bounds the sources whose sequence numbers are
+// remembered, the sources of the caches which went away are forgotten
+// once it is reached.
+const maxInvalidationSources = 1024
+
+// invalidator publishes the invalidations of a cache and drops the ones
+// already applied.
+type invalidator struct {
+	transport   InvalidationTransport
+	source      string
+	seq         atomic.Uint64
+	seen        map[string]*seqWindow
+	unsubscribe func()
+	sync.Mutex
+}
+
+// newInvalidator returns an invalidator with a random source identifier.
+func newInvalidator(transport InvalidationTransport) *invalidator {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &invalidator{
+		transport: transport,
+		source:    hex.EncodeToString(id),
+		seen:      make(map[string]*seqWindow),
+	}
+}
+
+// publish sends the invalidation numbered with the next sequence number.
+// The transport errors are dropped, the invalidations are best effort.
+func (inv *invalidator) publish(invalidation Invalidation) {
+	invalidation.Source = inv.source
+	invalidation.Seq = inv.seq.Add(1)
+	inv.transport.Publish(invalidation)
+}
+
+// accepts tells if an invalidation received from the transport must be
+// applied : it comes from another cache and was not seen before.
+func (inv *invalidator) accepts(invalidation Invalidation) bool {
+	if invalidation.Source == inv.source {
+		return false
+	}
+	inv.Lock()
+	defer inv.Unlock()
+	window, ok := inv.seen[invalidation.Source]
+	if !ok {
+		if len(inv.seen) >= maxInvalidationSources {
+			inv.seen = make(map[string]*seqWindow)
+		}
+		window = &seqWindow{}
+		inv.seen[invalidation.Source] = window
+	}
+	return !window.seen(invalidation.Seq)
+}
+
+// seqWindow remembers the last 64 sequence numbers of a source, like the
+// replay window of IPsec, so duplicates are dropped even out of order.
+type seqWindow struct {
+	max  uint64
+	bits uint64 // bit i is set when max-i was seen
+}
+
+// seen records the sequence number and tells if it was seen before ; the
+// ones older than the window are taken as seen.
+func (w *seqWindow) seen(seq uint64) bool {
+	if seq > w.max {
+		if shift := seq - w.max; shift >= 64 {
+			w.bits = 0
+		} else {
+			w.bits <<= shift
+		}
+		w.bits |= 1
+		w.max = seq
+		return false
+	}
+	if w.max-seq >= 64 {
+		return true
+	}
+	bit := uint64(1) << (w.max - seq)
+	if w.bits&bit != 0 {
+		return true
+	}
+	w.bits |= bit
+	return false
+}
+
+// invalidate publishes the invalidation of a key set or deleted.
+func (vlruCache *VolatileLRU[K, V]) invalidate(key K) {
+	if vlruCache.invalidator == nil {
+		return
+	}
+	data, err := vlruCache.codec.EncodeKey(key)
+	if err != nil {
+		return
+	}
+	vlruCache.invalidator.publish(Invalidation{Key: data})
+}
+
+// invalidateAll publishes the invalidation of a clear.
+func (vlruCache *VolatileLRU[K, V]) invalidateAll() {
+	if vlruCache.invalidator != nil {
+		vlruCache.invalidator.publish(Invalidation{Clear: true})
+	}
+}
+
+// invalidateTag publishes the invalidation of the keys of a tag.
+func (vlruCache *VolatileLRU[K, V]) invalidateTag(tag string) {
+	if vlruCache.invalidator != nil {
+		vlruCache.invalidator.publish(Invalidation{Tag: tag})
+	}
+}
+
+// applyInvalidation drops the key of an invalidation received from another
+// cache, the keys of its tag, or every key. It is not published again.
+func (vlruCache *VolatileLRU[K, V]) applyInvalidation(invalidation Invalidation) {
+	if !vlruCache.invalidator.accepts(invalidation) {
+		return
+	}
+	var key K
+	if !invalidation.Clear && invalidation.Tag == "" {
+		var err error
+		if key, err = vlruCache.codec.DecodeKey(invalidation.Key); err != nil {
+			return
+		}
+	}
+	vlruCache.Lock()
+	defer vlruCache.Unlock()
+	if vlruCache.isClosed() {
+		return
+	}
+	if invalidation.Clear {
+		vlruCache.clear()
+	} else if invalidation.Tag != "" {
+		vlruCache.removeTag(invalidation.Tag)
+	} else {
+		vlruCache.delete(key)
+	}
+}
+
+// MemoryTransport is an InvalidationTransport between the caches of one
+// process, mostly for tests. Publish delivers the invalidation to every
+// subscriber, its source included, before returning.
+type MemoryTransport struct {
+	handlers map[int]func(Invalidation)
+	next     int
+	sync.RWMutex
+}
+
+// NewMemoryTransport returns an empty MemoryTransport.
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{handlers: make(map[int]func(Invalidation))}
+}
+
+// Publish delivers the invalidation to the subscribers.
+func (mt *MemoryTransport) Publish(invalidation Invalidation) error {
+	mt.RLocker().Lock()
+	handlers := make([]func(Invalidation), 0, len(mt.handlers))
+	for _, handler := range mt.handlers {
+		handlers = append(handlers, handler)
+	}
+	mt.RLocker().Unlock()
+	for _, handler := range handlers {
+		handler(invalidation)
+	}
+	return nil
+}
+
+// Subscribe registers the handler until the returned function is called.
+func (mt *MemoryTransport) Subscribe(handler func(Invalidation)) func() {
+	mt.Lock()
+	defer mt.Unlock()
+	id := mt.next
+	mt.next++
+	mt.handlers[id] = handler
+	return func() {
+		mt.Lock()
+		defer mt.Unlock()
+		delete(mt.handlers, id)
+	}
+}
@@ -0,0 +1,125 @@
+package spectre
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// recordingTransport is a MemoryTransport keeping what it publishes.
+type recordingTransport struct {
+	*MemoryTransport
+	published []Invalidation
+}
+
+func (rt *recordingTransport) Publish(invalidation Invalidation) error {
+	rt.published = append(rt.published, invalidation)
+	return rt.MemoryTransport.Publish(invalidation)
+}
+
+func TestInvalidation(t *testing.T) {
+	transport := &recordingTransport{MemoryTransport: NewMemoryTransport()}
+	first, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(transport))
+	second, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(transport))
+	defer first.Close(context.Background())
+	defer second.Close(context.Background())
+	for _, cache := range []*VolatileLRUCache{first, second} {
+		cache.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+		cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, 0)
+	}
+	// the set of each cache dropped the key from the other, set again
+	second.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+	if _, ok := first.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("overwritten key is still served by the other cache")
+	}
+	if _, ok := second.VolatileLRUCacheGet("vivek"); !ok {
+		t.Fatalf("invalidation echoed back to its source")
+	}
+	first.VolatileLRUCacheSet("ibibo", "ibibo", 5, 0)
+	first.VolatileLRUCacheDelete("ibibo")
+	if _, ok := second.VolatileLRUCacheGet("ibibo"); ok {
+		t.Fatalf("deleted key is still served by the other cache")
+	}
+
+	// loads are not published
+	published := len(transport.published)
+	first.GetOrLoad(context.Background(), "loaded", func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		return "loaded", 6, 0, nil
+	})
+	if len(transport.published) != published {
+		t.Fatalf("a loaded value was published")
+	}
+
+	first.VolatileLRUCacheSet("fresh", "fresh", 5, 0)
+	second.VolatileLRUCacheSet("fresh", "fresh", 5, 0)
+	second.VolatileLRUCacheClear()
+	if first.VolatileLRUCacheCurrentSize() != 0 {
+		t.Fatalf("clear was not applied to the other cache")
+	}
+	last := transport.published[len(transport.published)-1]
+	if !last.Clear || last.Key != nil {
+		t.Fatalf("bad clear invalidation %+v", last)
+	}
+
+	// a closed cache does not receive any more
+	second.Close(context.Background())
+	first.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+	if len(transport.handlers) != 1 {
+		t.Fatalf("closed cache is still subscribed")
+	}
+}
+
+func TestInvalidationDuplicates(t *testing.T) {
+	transport := NewMemoryTransport()
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(transport))
+	defer cache.Close(context.Background())
+	key, _ := cache.codec.EncodeKey("vivek")
+	invalidation := Invalidation{Source: "other", Seq: 1, Key: key}
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+	transport.Publish(invalidation)
+	if _, ok := cache.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("invalidation was not applied")
+	}
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+	transport.Publish(invalidation)
+	if _, ok := cache.VolatileLRUCacheGet("vivek"); !ok {
+		t.Fatalf("duplicate invalidation was applied")
+	}
+	invalidation.Source = "another"
+	transport.Publish(invalidation)
+	if _, ok := cache.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("invalidation of another source was dropped")
+	}
+}
+
+func TestSeqWindow(t *testing.T) {
+	var window seqWindow
+	for _, test := range []struct {
+		seq  uint64
+		seen bool
+	}{
+		{1, false}, {1, true}, {3, false}, {2, false}, {2, true}, {3, true},
+		{100, false}, {40, false}, {40, true}, {36, true}, {37, false}, {200, false}, {100, true},
+	} {
+		if window.seen(test.seq) != test.seen {
+			t.Fatalf("expected seen %v for %d", test.seen, test.seq)
+		}
+	}
+}
+
+func TestInvalidationOptions(t *testing.T) {
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(nil)); err != InvalidTransportError {
+		t.Fatalf("expected InvalidTransportError got %v", err)
+	}
+}
+
+func BenchmarkInvalidatedSet(b *testing.B) {
+	transport := NewMemoryTransport()
+	first, _ := New(WithMaxSize(1000), WithTTL(time.Hour), WithInvalidation(transport))
+	second, _ := New(WithMaxSize(1000), WithTTL(time.Hour), WithInvalidation(transport))
+	defer first.Close(context.Background())
+	defer second.Close(context.Background())
+	for i := 0; i < b.N; i++ {
+		first.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+	}
+}
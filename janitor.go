@@ -0,0 +1,119 @@
+package spectre
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// closedError is the error which is thrown when a closed cache is used.
+type closedError struct {
+	errorNumber int
+	problem     string
+}
+
+func (ce *closedError) Error() string {
+	return fmt.Sprintf("%d---%s", ce.errorNumber, ce.problem)
+}
+
+// ErrClosed returns when a VolatileLRU is used after Close
+var ErrClosed = &closedError{problem: "cache is closed", errorNumber: 11}
+
+// janitor removes the expired keys of a VolatileLRU in the background
+// so a read mostly cache does not keep dead values in memory.
+type janitor struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startJanitor starts a janitor calling sweep every interval, the sweep
+// removes a bounded number of expired keys under the cache lock.
+func startJanitor(interval time.Duration, sweep func()) *janitor {
+	j := &janitor{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go j.run(interval, sweep)
+	return j
+}
+
+func (j *janitor) run(interval time.Duration, sweep func()) {
+	defer close(j.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sweep()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// shutdown stops the janitor and waits for its goroutine to exit, or until
+// the context is done.
+func (j *janitor) shutdown(ctx context.Context) error {
+	close(j.stop)
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isClosed tells if Close has been called on the cache.
+func (vlruCache *VolatileLRU[K, V]) isClosed() bool {
+	return atomic.LoadInt32(&vlruCache.closed) == 1
+}
+
+// Close stops the janitor and the async writer of the cache. The writes
+// already queued by SetAsync are applied until the context is done, the
+// ones left then report ErrClosed. The removals waiting for the listeners
+// given to OnRemove are delivered within the same context. With
+// WithSnapshotFile a last snapshot is saved once the queued writes are
+// applied, and the op log of WithOpLog is synced and closed. The cache stops
+// receiving the invalidations of WithInvalidation at once. Once closed,
+// sets and Flush return ErrClosed, reads miss and deletes do nothing.
+// return values :
+//		error: the context error if it is done before the shutdown ends,
+//			   the error of the last snapshot or of the op log, ErrClosed
+//			   if the cache is already closed else nil
+func (vlruCache *VolatileLRU[K, V]) Close(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&vlruCache.closed, 0, 1) {
+		return ErrClosed
+	}
+	if vlruCache.invalidator != nil {
+		vlruCache.invalidator.unsubscribe()
+	}
+
+	err := vlruCache.writer.close(ctx)
+	if vlruCache.janitor != nil {
+		if janitorErr := vlruCache.janitor.shutdown(ctx); err == nil {
+			err = janitorErr
+		}
+	}
+	if vlruCache.snapshotter != nil {
+		if snapshotErr := vlruCache.snapshotter.shutdown(ctx); err == nil {
+			err = snapshotErr
+		}
+		if snapshotErr := vlruCache.SaveSnapshotFile(vlruCache.snapshotFile); err == nil {
+			err = snapshotErr
+		}
+	}
+	if oplog := vlruCache.oplog; oplog != nil {
+		if tickerErr := oplog.ticker.shutdown(ctx); err == nil {
+			err = tickerErr
+		}
+		oplog.rewrites.Wait()
+		if opLogErr := oplog.close(); err == nil {
+			err = opLogErr
+		}
+	}
+	if removalsErr := vlruCache.cache.removals.close(ctx); err == nil {
+		err = removalsErr
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package spectre
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestJanitor(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithJanitor(5*time.Millisecond, 2))
+	defer cache.Close(context.Background())
+	for i := 0; i < 5; i++ {
+		key := strconv.Itoa(i)
+		cache.VolatileLRUCacheSet(key, key, 1, time.Millisecond)
+	}
+	cache.VolatileLRUCacheSet("alive", "alive", 1, time.Duration(0))
+	deadline := time.Now().Add(time.Second)
+	for cache.VolatileLRUCacheCurrentSize() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if size := cache.VolatileLRUCacheCurrentSize(); size != 1 {
+		t.Fatalf("janitor did not remove the expired keys, size %v", size)
+	}
+	if _, ok := cache.VolatileLRUCacheGet("alive"); !ok {
+		t.Fatalf("janitor removed a live key")
+	}
+}
+
+func TestJanitorMaxPerTick(t *testing.T) {
+	cache := GetVolatileLRUCache(100, 4, time.Duration(3600))
+	for i := 0; i < 5; i++ {
+		key := strconv.Itoa(i)
+		cache.VolatileLRUCacheSet(key, key, 1, time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	cache.Lock()
+	removed := cache.removeExpired(2)
+	cache.Unlock()
+	if removed != 2 || cache.VolatileLRUCacheCurrentSize() != 3 {
+		t.Fatalf("expected 2 keys removed got %v", removed)
+	}
+}
+
+func TestInvalidJanitor(t *testing.T) {
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithJanitor(0, 1)); err != InvalidJanitorError {
+		t.Fatalf("expected InvalidJanitorError got %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithJanitor(time.Millisecond, 10))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	result, _ := cache.SetAsync("ibibo", "ibibo", 5, time.Duration(0))
+	if err := cache.Close(context.Background()); err != nil {
+		t.Fatalf("close failed %v", err)
+	}
+	if success, err := result.Wait(); !success {
+		t.Fatalf("queued write was not applied on close %v", err)
+	}
+	if _, err := cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0)); err != ErrClosed {
+		t.Fatalf("expected ErrClosed on set got %v", err)
+	}
+	if _, err := cache.SetAsync("vivek", "vivek", 5, time.Duration(0)); err != ErrClosed {
+		t.Fatalf("expected ErrClosed on async set got %v", err)
+	}
+	if err := cache.Flush(context.Background()); err != ErrClosed {
+		t.Fatalf("expected ErrClosed on flush got %v", err)
+	}
+	if _, ok := cache.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("closed cache returned a value")
+	}
+	if err := cache.Close(context.Background()); err != ErrClosed {
+		t.Fatalf("expected ErrClosed on second close got %v", err)
+	}
+}
+
+func TestCloseContext(t *testing.T) {
+	cache := GetVolatileLRUCache(100, 4, time.Duration(3600))
+	holdAsyncWriter(t, cache)
+	result, _ := cache.SetAsync("vivek", "vivek", 5, time.Duration(0))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- cache.Close(ctx)
+	}()
+	if _, err := result.Wait(); err != ErrClosed {
+		t.Fatalf("expected ErrClosed for the write left in the queue got %v", err)
+	}
+	cache.Unlock()
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Fatalf("expected close to give up with the context got %v", err)
+	}
+}
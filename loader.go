@@ -0,0 +1,118 @@
+package spectre
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// loaderError is the error which is thrown when a value can not be loaded.
+type loaderError struct {
+	errorNumber int
+	problem     string
+}
+
+func (le *loaderError) Error() string {
+	return fmt.Sprintf("%d---%s", le.errorNumber, le.problem)
+}
+
+// NoLoaderError returns from GetOrLoad when neither the call nor the cache
+// gives a Loader
+var NoLoaderError = &loaderError{problem: "no loader given for the missing key", errorNumber: 13}
+
+// Loader computes the value of a key missing from the cache for GetOrLoad.
+// Besides the value it returns its size in bytes, AutoSize to have it
+// estimated, and its time to live, 0 for the global ttl of the cache.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (value V, size int, ttl time.Duration, err error)
+
+// loadCall is a load in flight, shared by every caller missing the key
+// while it runs.
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// loadGroup deduplicates the concurrent loads of a key, so a popular key
+// expiring triggers a single call to the backend.
+type loadGroup[K comparable, V any] struct {
+	calls map[K]*loadCall[V]
+	sync.Mutex
+}
+
+func newLoadGroup[K comparable, V any]() *loadGroup[K, V] {
+	return &loadGroup[K, V]{calls: make(map[K]*loadCall[V])}
+}
+
+// do returns the load in flight for the key, or starts load in its own
+// goroutine when there is none. The call is forgotten once load returns,
+// so a failed load is retried by the next caller.
+func (lg *loadGroup[K, V]) do(key K, load func() (V, error)) *loadCall[V] {
+	lg.Lock()
+	defer lg.Unlock()
+	if call, ok := lg.calls[key]; ok {
+		return call
+	}
+	call := &loadCall[V]{done: make(chan struct{})}
+	lg.calls[key] = call
+	go func() {
+		call.value, call.err = load()
+		lg.Lock()
+		delete(lg.calls, key)
+		lg.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+// GetOrLoad returns the value of the key, loading it on a miss with the
+// given loader, or the one of WithLoader for a nil loader. The loaded value
+// is stored with the size and the ttl given by the loader before being
+// returned ; a value the cache can not hold is returned without being
+// stored. Concurrent misses of a key share a single load.
+// The load is not cancelled with the context of the caller, other callers
+// may be waiting for it ; the caller just stops waiting.
+// return values :
+//		value: value corresponding to the key
+//		error: the error of the loader, which is not cached, the context
+//			   error, NoLoaderError without loader, ErrClosed after Close
+func (vlruCache *VolatileLRU[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	var zero V
+	if vlruCache.isClosed() {
+		return zero, ErrClosed
+	}
+	if value, ok := vlruCache.Get(key); ok {
+		return value, nil
+	}
+	if loader == nil {
+		loader = vlruCache.loader
+	}
+	if loader == nil {
+		return zero, NoLoaderError
+	}
+	call := vlruCache.load(context.WithoutCancel(ctx), key, loader)
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// load starts a load of the key with the loader, or joins the one in
+// flight. The loaded value is stored before the load completes.
+func (vlruCache *VolatileLRU[K, V]) load(ctx context.Context, key K, loader Loader[K, V]) *loadCall[V] {
+	return vlruCache.loads.do(key, func() (V, error) {
+		start := time.Now()
+		value, size, ttl, err := loader(ctx, key)
+		vlruCache.cache.stats.recordLoad(time.Since(start), err)
+		if err != nil {
+			var zero V
+			return zero, err
+		}
+		// a loaded value is no change the other caches must know about
+		vlruCache.localSet(key, value, size, ttl)
+		return value, nil
+	})
+}
@@ -0,0 +1,122 @@
+package spectre
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	var calls int32
+	loader := func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		return "loaded " + key, 7, time.Minute, nil
+	}
+	value, err := cache.GetOrLoad(context.Background(), "vivek", loader)
+	if err != nil || value != "loaded vivek" {
+		t.Fatalf("expected loaded vivek got %v %v", value, err)
+	}
+	if size := cache.VolatileLRUCacheCurrentSize(); size != 7 {
+		t.Fatalf("expected the loader size 7 got %v", size)
+	}
+	if ttl := time.Until(cache.linkMap["vivek"].ExpireTime); ttl > time.Minute {
+		t.Fatalf("expected the loader ttl got %v", ttl)
+	}
+	cache.GetOrLoad(context.Background(), "vivek", loader)
+	if calls != 1 {
+		t.Fatalf("expected the second call to hit got %v loads", calls)
+	}
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	var calls int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return key, 1, 0, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := cache.GetOrLoad(context.Background(), "vivek", loader); value != "vivek" {
+				t.Errorf("expected vivek got %v %v", value, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("expected a single load got %v", calls)
+	}
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	backendDown := errors.New("backend down")
+	var calls int32
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithLoader(func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, 0, 0, backendDown
+	}))
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetOrLoad(context.Background(), "vivek", nil); err != backendDown {
+			t.Fatalf("expected the loader error got %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("the loader error was cached, %v loads", calls)
+	}
+	if _, ok := cache.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("failed load stored a value")
+	}
+}
+
+func TestGetOrLoadContext(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		<-release
+		return key, 1, 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := cache.GetOrLoad(ctx, "vivek", loader); err != context.DeadlineExceeded {
+		t.Fatalf("expected the context error got %v", err)
+	}
+	close(release)
+	// the load was not cancelled with the caller
+	if value, err := cache.GetOrLoad(context.Background(), "vivek", loader); value != "vivek" {
+		t.Fatalf("expected vivek got %v %v", value, err)
+	}
+}
+
+func TestGetOrLoadNoLoader(t *testing.T) {
+	cache := GetVolatileLRUCache(100, 4, time.Duration(3600))
+	if _, err := cache.GetOrLoad(context.Background(), "vivek", nil); err != NoLoaderError {
+		t.Fatalf("expected NoLoaderError got %v", err)
+	}
+	if _, err := NewVolatileLRU[string, int](WithMaxSize(100), WithTTL(time.Hour), WithLoader(func(ctx context.Context, key string) (string, int, time.Duration, error) {
+		return key, 1, 0, nil
+	})); err != KeyTypeError {
+		t.Fatalf("expected KeyTypeError for a loader of another value type got %v", err)
+	}
+}
+
+func BenchmarkGetOrLoadHit(b *testing.B) {
+	cache, _ := NewVolatileLRU[int, int](WithMaxSize(1<<20), WithTTL(time.Hour))
+	loader := func(ctx context.Context, key int) (int, int, time.Duration, error) {
+		return key, 1, 0, nil
+	}
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		cache.GetOrLoad(ctx, i%1000, loader)
+	}
+}
@@ -0,0 +1,406 @@
+package memcache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+const (
+	// maxKey is the longest key memcached accepts.
+	maxKey = 250
+	// maxItem bounds the data of an item, like the default item size limit
+	// of memcached.
+	maxItem = 1024 * 1024
+	// maxRelative is the longest exptime taken as relative, later ones are
+	// unix times.
+	maxRelative = 60 * 60 * 24 * 30
+	// version is the version reported to the clients.
+	version = "spectre"
+)
+
+const (
+	errFormat     = "CLIENT_ERROR bad command line format"
+	errTooLarge   = "SERVER_ERROR object too large for cache"
+	errNonNumeric = "CLIENT_ERROR cannot increment or decrement non-numeric value"
+)
+
+// execute runs the command of a line and writes its reply, it tells if the
+// connection asked to be closed. The error is the one of the connection
+// when the data of a storage command can not be read.
+func (s *Server) execute(r *bufio.Reader, w *bufio.Writer, line []byte) (bool, error) {
+	// the line is only valid until the next read, the fields are copies
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		w.WriteString("ERROR\r\n")
+		return false, nil
+	}
+	noreply := fields[len(fields)-1] == "noreply"
+	if noreply {
+		fields = fields[:len(fields)-1]
+	}
+	reply := func(message string) {
+		if !noreply {
+			w.WriteString(message)
+			w.WriteString("\r\n")
+		}
+	}
+	switch fields[0] {
+	case "get", "gets":
+		s.get(w, fields)
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.store(r, fields, reply)
+	case "delete":
+		s.delete(fields, reply)
+	case "incr", "decr":
+		s.incr(fields, reply)
+	case "touch":
+		s.touch(fields, reply)
+	case "flush_all":
+		s.flushAll(fields, reply)
+	case "stats":
+		s.stats(w, fields)
+	case "version":
+		w.WriteString("VERSION " + version + "\r\n")
+	case "verbosity":
+		reply("OK")
+	case "quit":
+		return true, nil
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return false, nil
+}
+
+// validKey tells if a key is accepted by memcached : at most 250 bytes
+// without control characters.
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKey {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// keyExpire maps the exptime of a command onto the keyExpire of the cache.
+func keyExpire(exptime int64) time.Duration {
+	switch {
+	case exptime == 0:
+		return spectre.NoExpiry
+	case exptime < 0:
+		// expired at once
+		return time.Nanosecond
+	case exptime <= maxRelative:
+		return time.Duration(exptime) * time.Second
+	}
+	if left := time.Until(time.Unix(exptime, 0)); left > 0 {
+		return left
+	}
+	return time.Nanosecond
+}
+
+// asItem returns the item of a value, the values set by the Go side of the
+// cache which are not items become items without flags : bytes and strings
+// as they are, others in JSON.
+func asItem(value interface{}) Item {
+	switch value := value.(type) {
+	case Item:
+		return value
+	case []byte:
+		return Item{Value: value}
+	case string:
+		return Item{Value: []byte(value)}
+	}
+	data, _ := json.Marshal(value)
+	return Item{Value: data}
+}
+
+// storeError returns the reply of a failed set.
+func storeError(err error) string {
+	switch err {
+	case spectre.SizeLimitError:
+		return errTooLarge
+	case spectre.LowSpaceError:
+		return "SERVER_ERROR out of memory storing object"
+	}
+	return "SERVER_ERROR " + err.Error()
+}
+
+// get handles get and gets <key>*.
+func (s *Server) get(w *bufio.Writer, fields []string) {
+	if len(fields) < 2 {
+		w.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range fields[1:] {
+		if !validKey(key) {
+			w.WriteString(errFormat + "\r\n")
+			return
+		}
+	}
+	for _, key := range fields[1:] {
+		s.counters.gets.Add(1)
+		value, ok := s.cache.VolatileLRUCacheGet(key)
+		if !ok {
+			continue
+		}
+		item := asItem(value)
+		if fields[0] == "gets" {
+			fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, item.Flags, len(item.Value), item.CAS)
+		} else {
+			fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, item.Flags, len(item.Value))
+		}
+		w.Write(item.Value)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("END\r\n")
+}
+
+// store handles <command> <key> <flags> <exptime> <bytes> [noreply] for
+// set, add, replace, append and prepend, and cas with the cas unique after
+// the bytes.
+func (s *Server) store(r *bufio.Reader, fields []string, reply func(string)) (bool, error) {
+	command := fields[0]
+	arity := 5
+	if command == "cas" {
+		arity = 6
+	}
+	if len(fields) != arity {
+		reply("ERROR")
+		return false, nil
+	}
+	length, err := strconv.Atoi(fields[4])
+	if err != nil || length < 0 {
+		reply(errFormat)
+		return false, nil
+	}
+	if length > maxItem {
+		// the data is skipped to keep reading the commands
+		if _, err := io.CopyN(io.Discard, r, int64(length)+2); err != nil {
+			return false, err
+		}
+		reply(errTooLarge)
+		return false, nil
+	}
+	data := make([]byte, length+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return false, err
+	}
+	if data[length] != '\r' || data[length+1] != '\n' {
+		reply("CLIENT_ERROR bad data chunk")
+		return true, nil
+	}
+	data = data[:length]
+
+	key := fields[1]
+	flags, flagsErr := strconv.ParseUint(fields[2], 10, 32)
+	exptime, exptimeErr := strconv.ParseInt(fields[3], 10, 64)
+	var unique uint64
+	var uniqueErr error
+	if command == "cas" {
+		unique, uniqueErr = strconv.ParseUint(fields[5], 10, 64)
+	}
+	if !validKey(key) || flagsErr != nil || exptimeErr != nil || uniqueErr != nil {
+		reply(errFormat)
+		return false, nil
+	}
+
+	s.counters.sets.Add(1)
+	item := Item{Value: data, Flags: uint32(flags), CAS: s.nextCAS()}
+	var ok, found bool
+	switch command {
+	case "set":
+		ok, err = s.cache.VolatileLRUCacheSet(key, item, length, keyExpire(exptime))
+	case "add":
+		ok, err = s.cache.VolatileLRUCacheSet(key, item, length, keyExpire(exptime), spectre.IfAbsent())
+	case "replace":
+		ok, err = s.cache.VolatileLRUCacheSet(key, item, length, keyExpire(exptime), spectre.IfPresent())
+	case "append", "prepend":
+		// the flags and the exptime of the command are ignored
+		ok, err = s.cache.Update(key, spectre.KeepTTL, func(value interface{}, present bool) (interface{}, int, bool) {
+			if !present {
+				return nil, 0, false
+			}
+			current := asItem(value)
+			joined := make([]byte, 0, len(current.Value)+len(data))
+			if command == "append" {
+				joined = append(append(joined, current.Value...), data...)
+			} else {
+				joined = append(append(joined, data...), current.Value...)
+			}
+			return Item{Value: joined, Flags: current.Flags, CAS: item.CAS}, len(joined), true
+		})
+	case "cas":
+		ok, err = s.cache.Update(key, keyExpire(exptime), func(value interface{}, present bool) (interface{}, int, bool) {
+			found = present
+			if !present || asItem(value).CAS != unique {
+				return nil, 0, false
+			}
+			return item, length, true
+		})
+	}
+	switch {
+	case err != nil:
+		reply(storeError(err))
+	case ok:
+		reply("STORED")
+	case command != "cas":
+		reply("NOT_STORED")
+	case found:
+		// the item changed since it was read
+		reply("EXISTS")
+	default:
+		reply("NOT_FOUND")
+	}
+	return false, nil
+}
+
+// nextCAS returns a new cas unique.
+func (s *Server) nextCAS() uint64 {
+	return s.cas.Add(1)
+}
+
+// delete handles delete <key> [0] [noreply], the 0 is sent by old clients.
+func (s *Server) delete(fields []string, reply func(string)) {
+	if len(fields) < 2 || len(fields) > 3 || (len(fields) == 3 && fields[2] != "0") {
+		reply("CLIENT_ERROR bad command line format.  Usage: delete <key> [noreply]")
+		return
+	}
+	if s.cache.Remove(fields[1]) {
+		reply("DELETED")
+	} else {
+		reply("NOT_FOUND")
+	}
+}
+
+// incr handles incr and decr <key> <delta> [noreply]. The values are
+// unsigned 64 bit integers, incr wraps around and decr stops at 0.
+func (s *Server) incr(fields []string, reply func(string)) {
+	if len(fields) != 3 {
+		reply("ERROR")
+		return
+	}
+	delta, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		reply("CLIENT_ERROR invalid numeric delta argument")
+		return
+	}
+	var result uint64
+	var found, numeric bool
+	cas := s.nextCAS()
+	ok, err := s.cache.Update(fields[1], spectre.KeepTTL, func(value interface{}, present bool) (interface{}, int, bool) {
+		if found = present; !present {
+			return nil, 0, false
+		}
+		current := asItem(value)
+		n, err := strconv.ParseUint(string(current.Value), 10, 64)
+		if numeric = err == nil; !numeric {
+			return nil, 0, false
+		}
+		if fields[0] == "incr" {
+			result = n + delta
+		} else if delta > n {
+			result = 0
+		} else {
+			result = n - delta
+		}
+		data := []byte(strconv.FormatUint(result, 10))
+		return Item{Value: data, Flags: current.Flags, CAS: cas}, len(data), true
+	})
+	switch {
+	case err != nil:
+		reply(storeError(err))
+	case ok:
+		reply(strconv.FormatUint(result, 10))
+	case !found:
+		reply("NOT_FOUND")
+	default:
+		reply(errNonNumeric)
+	}
+}
+
+// touch handles touch <key> <exptime> [noreply].
+func (s *Server) touch(fields []string, reply func(string)) {
+	if len(fields) != 3 {
+		reply("ERROR")
+		return
+	}
+	exptime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		reply("CLIENT_ERROR invalid exptime argument")
+		return
+	}
+	s.counters.touches.Add(1)
+	if s.cache.Expire(fields[1], keyExpire(exptime)) {
+		reply("TOUCHED")
+	} else {
+		reply("NOT_FOUND")
+	}
+}
+
+// flushAll handles flush_all [delay] [noreply], with a delay the cache is
+// cleared once the delay in seconds is over. Like with memcached a later
+// flush_all replaces the one still waiting.
+func (s *Server) flushAll(fields []string, reply func(string)) {
+	if len(fields) > 2 {
+		reply("ERROR")
+		return
+	}
+	var delay int64
+	if len(fields) == 2 {
+		var err error
+		if delay, err = strconv.ParseInt(fields[1], 10, 64); err != nil || delay < 0 || delay > math.MaxInt64/int64(time.Second) {
+			reply(errFormat)
+			return
+		}
+	}
+	s.counters.flushes.Add(1)
+	s.scheduleFlush(time.Duration(delay) * time.Second)
+	reply("OK")
+}
+
+// stats handles stats, only the general statistics are reported.
+func (s *Server) stats(w *bufio.Writer, fields []string) {
+	if len(fields) == 1 {
+		stats := s.cache.Stats()
+		now := time.Now()
+		for _, stat := range []struct {
+			name  string
+			value interface{}
+		}{
+			{"pid", os.Getpid()},
+			{"uptime", int64(now.Sub(s.started) / time.Second)},
+			{"time", now.Unix()},
+			{"version", version},
+			{"curr_connections", s.conns.Clients()},
+			{"total_connections", s.counters.connections.Load()},
+			{"cmd_get", s.counters.gets.Load()},
+			{"cmd_set", s.counters.sets.Load()},
+			{"cmd_flush", s.counters.flushes.Load()},
+			{"cmd_touch", s.counters.touches.Load()},
+			{"get_hits", stats.Hits},
+			{"get_misses", stats.Misses},
+			{"get_expired", stats.ExpiredMisses},
+			{"curr_items", stats.Entries},
+			{"bytes", stats.Bytes},
+			{"limit_maxbytes", stats.MaxSize},
+			{"evictions", stats.Removals[spectre.Evicted]},
+			{"reclaimed", stats.Removals[spectre.Expired]},
+		} {
+			fmt.Fprintf(w, "STAT %s %v\r\n", stat.name, stat.value)
+		}
+	}
+	w.WriteString("END\r\n")
+}
@@ -0,0 +1,158 @@
+// Package memcache serves a spectre VolatileLRUCache with the memcached
+// ASCII protocol, so the existing memcached clients can use it. The server
+// supports get, gets, set, add, replace, append, prepend, cas, delete, incr,
+// decr, touch, flush_all, stats, version, verbosity and quit, over TCP or
+// Unix sockets.
+//
+// Values are stored as Item values keeping the flags of the client and the
+// cas unique of the item, the size of an item is the length of its data.
+// The other front-ends of the cache read the data of an item through its
+// Bytes method.
+// An exptime of 0 keeps the item until it is evicted, a negative one
+// expires it at once, exptimes up to 30 days are relative and later ones
+// are unix times, like with memcached.
+package memcache
+
+import (
+	"bufio"
+	"encoding/gob"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vivek07672/spectre"
+	"github.com/vivek07672/spectre/internal/netserver"
+)
+
+// Item is the value of a key set by the memcached clients.
+type Item struct {
+	Value []byte
+	Flags uint32
+	CAS   uint64
+}
+
+// Bytes returns the data of the item, the resp and server packages serve
+// it in place of the item.
+func (item Item) Bytes() []byte {
+	return item.Value
+}
+
+func init() {
+	// items are kept in interface{} caches, gob needs them to be registered
+	// to write their snapshots
+	gob.Register(Item{})
+}
+
+// ErrServerClosed returns from Serve and ListenAndServe after Close
+var ErrServerClosed = netserver.NewError(25, "memcache server closed")
+
+// maxLine bounds the length of a command line, a get of many keys included.
+const maxLine = 64 * 1024
+
+// Server serves a cache to the connections of its listeners, each
+// connection from a goroutine of its own.
+type Server struct {
+	cache      *spectre.VolatileLRUCache
+	started    time.Time
+	cas        atomic.Uint64
+	counters   counters
+	conns      *netserver.Server
+	flushTimer *time.Timer // the flush_all with a delay still to run
+	closed     bool
+	sync.Mutex
+}
+
+// counters are the command counts reported by stats.
+type counters struct {
+	connections atomic.Uint64
+	gets        atomic.Uint64
+	sets        atomic.Uint64
+	touches     atomic.Uint64
+	flushes     atomic.Uint64
+}
+
+// New returns a Server for the cache.
+func New(cache *spectre.VolatileLRUCache) *Server {
+	s := &Server{
+		cache:   cache,
+		started: time.Now(),
+	}
+	s.conns = netserver.New(s.serveConn, ErrServerClosed)
+	// the cas uniques start from the start time so the items of a previous
+	// run, loaded from a snapshot, do not share them
+	s.cas.Store(uint64(s.started.UnixNano()))
+	return s
+}
+
+// ListenAndServe listens on the address of the network, "tcp" or "unix",
+// and serves the connections, see Serve.
+func (s *Server) ListenAndServe(network string, address string) error {
+	return s.conns.ListenAndServe(network, address)
+}
+
+// Serve accepts the connections of the listener until it fails or the
+// server is closed. The listener is closed on return.
+// return values :
+//		error: ErrServerClosed after Close else the error of the listener
+func (s *Server) Serve(listener net.Listener) error {
+	return s.conns.Serve(listener)
+}
+
+// Close closes the listeners and the connections of the server, and waits
+// for the commands in flight. A flush_all waiting for its delay is
+// cancelled.
+func (s *Server) Close() error {
+	s.Lock()
+	s.closed = true
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+	}
+	s.Unlock()
+	s.conns.Close()
+	return nil
+}
+
+// scheduleFlush clears the cache once the delay is over, in place of the
+// flush_all still waiting for its delay. A delay of 0 clears it now.
+func (s *Server) scheduleFlush(delay time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if delay == 0 {
+		s.cache.VolatileLRUCacheClear()
+	} else if !s.closed {
+		s.flushTimer = time.AfterFunc(delay, s.cache.VolatileLRUCacheClear)
+	}
+}
+
+// serveConn runs the commands of a connection in their order. The replies
+// are flushed once the pipelined commands already received are run.
+func (s *Server) serveConn(conn net.Conn) {
+	s.counters.connections.Add(1)
+	r := bufio.NewReaderSize(conn, maxLine)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			w.WriteString("CLIENT_ERROR line too long\r\n")
+			w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		quit, err := s.execute(r, w, line)
+		if err != nil {
+			return
+		}
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+// startServer serves a cache of 100 bytes on the network and returns the
+// address to dial.
+func startServer(t *testing.T, network string, address string) (*spectre.VolatileLRUCache, string) {
+	cache, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour))
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("listen failed %v", err)
+	}
+	server := New(cache)
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("expected ErrServerClosed got %v", err)
+		}
+	})
+	return cache, listener.Addr().String()
+}
+
+// exchange sends the raw request and reads the raw replies expected.
+func exchange(t *testing.T, conn net.Conn, in *bufio.Reader, request string, expected string) {
+	t.Helper()
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatalf("write failed %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	reply := make([]byte, len(expected))
+	if _, err := io.ReadFull(in, reply); err != nil {
+		t.Fatalf("expected %q, read %q then %v", expected, reply, err)
+	}
+	if string(reply) != expected {
+		t.Fatalf("expected %q got %q", expected, reply)
+	}
+}
+
+// casOf returns the cas unique of a key read with gets.
+func casOf(t *testing.T, conn net.Conn, in *bufio.Reader, key string) string {
+	t.Helper()
+	io.WriteString(conn, "gets "+key+"\r\n")
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	header, _ := in.ReadString('\n')
+	fields := strings.Fields(header)
+	if len(fields) != 5 || fields[0] != "VALUE" {
+		t.Fatalf("bad gets reply %q", header)
+	}
+	length, _ := strconv.Atoi(fields[3])
+	io.CopyN(io.Discard, in, int64(length)+2+int64(len("END\r\n")))
+	return fields[4]
+}
+
+func TestCommands(t *testing.T) {
+	cache, address := startServer(t, "tcp", "127.0.0.1:0")
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("dial failed %v", err)
+	}
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+
+	exchange(t, conn, in, "set vivek 42 0 5\r\nvi\r\nk\r\n", "STORED\r\n")
+	exchange(t, conn, in, "get vivek missing\r\n", "VALUE vivek 42 5\r\nvi\r\nk\r\nEND\r\n")
+	if ttl, _ := cache.TTL("vivek"); ttl != spectre.NoExpiry {
+		t.Fatalf("exptime 0 did not map to NoExpiry, got %v", ttl)
+	}
+	exchange(t, conn, in, "add vivek 0 0 1\r\nx\r\n", "NOT_STORED\r\n")
+	exchange(t, conn, in, "replace ibibo 0 0 1\r\nx\r\n", "NOT_STORED\r\n")
+	exchange(t, conn, in, "add ibibo 7 100 2\r\nib\r\n", "STORED\r\n")
+	if ttl, _ := cache.TTL("ibibo"); ttl <= 99*time.Second || ttl > 100*time.Second {
+		t.Fatalf("expected an exptime of 100 seconds got %v", ttl)
+	}
+	exchange(t, conn, in, "append ibibo 0 0 2\r\nbo\r\n", "STORED\r\n")
+	exchange(t, conn, in, "prepend ibibo 0 0 1\r\n<\r\n", "STORED\r\n")
+	exchange(t, conn, in, "get ibibo\r\n", "VALUE ibibo 7 5\r\n<ibbo\r\nEND\r\n")
+	if ttl, _ := cache.TTL("ibibo"); ttl <= 99*time.Second || ttl > 100*time.Second {
+		t.Fatalf("append changed the exptime to %v", ttl)
+	}
+	exchange(t, conn, in, "append missing 0 0 1\r\nx\r\n", "NOT_STORED\r\n")
+	exchange(t, conn, in, "replace ibibo 1 0 5\r\nibibo\r\n", "STORED\r\n")
+
+	unique := casOf(t, conn, in, "ibibo")
+	exchange(t, conn, in, "cas ibibo 3 0 3 "+unique+"\r\nnew\r\n", "STORED\r\n")
+	exchange(t, conn, in, "cas ibibo 3 0 3 "+unique+"\r\nold\r\n", "EXISTS\r\n")
+	exchange(t, conn, in, "cas missing 3 0 3 "+unique+"\r\nold\r\n", "NOT_FOUND\r\n")
+	if casOf(t, conn, in, "ibibo") == unique {
+		t.Fatalf("cas unique did not change")
+	}
+
+	exchange(t, conn, in, "set counter 0 0 2\r\n10\r\n", "STORED\r\n")
+	exchange(t, conn, in, "incr counter 5\r\n", "15\r\n")
+	exchange(t, conn, in, "decr counter 20\r\n", "0\r\n")
+	exchange(t, conn, in, "incr counter 18446744073709551615\r\n", "18446744073709551615\r\n")
+	exchange(t, conn, in, "incr counter 2\r\n", "1\r\n")
+	exchange(t, conn, in, "incr vivek 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+	exchange(t, conn, in, "incr missing 1\r\n", "NOT_FOUND\r\n")
+	exchange(t, conn, in, "incr counter x\r\n", "CLIENT_ERROR invalid numeric delta argument\r\n")
+
+	exchange(t, conn, in, "touch counter 10\r\n", "TOUCHED\r\n")
+	if ttl, _ := cache.TTL("counter"); ttl <= 9*time.Second || ttl > 10*time.Second {
+		t.Fatalf("expected an exptime of 10 seconds got %v", ttl)
+	}
+	exchange(t, conn, in, "touch missing 10\r\n", "NOT_FOUND\r\n")
+	exchange(t, conn, in, "touch counter -1\r\n", "TOUCHED\r\n")
+	exchange(t, conn, in, "get counter\r\n", "END\r\n")
+	exchange(t, conn, in, "set past 0 -1 1\r\nx\r\n", "STORED\r\n")
+	exchange(t, conn, in, "get past\r\n", "END\r\n")
+	unix := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	exchange(t, conn, in, "set future 0 "+unix+" 1\r\nx\r\n", "STORED\r\n")
+	if ttl, _ := cache.TTL("future"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("expected an hour for a unix exptime got %v", ttl)
+	}
+
+	exchange(t, conn, in, "delete vivek\r\n", "DELETED\r\n")
+	exchange(t, conn, in, "delete vivek\r\n", "NOT_FOUND\r\n")
+	exchange(t, conn, in, "set quiet 0 0 1 noreply\r\nq\r\ndelete quiet noreply\r\nget quiet\r\n", "END\r\n")
+	exchange(t, conn, in, "set big 0 0 101\r\n"+strings.Repeat("x", 101)+"\r\n", "SERVER_ERROR object too large for cache\r\n")
+	exchange(t, conn, in, "set bad 0 0 1\r\nxx\r\n", "CLIENT_ERROR bad data chunk\r\n")
+	if _, err := in.ReadByte(); err != io.EOF {
+		t.Fatalf("connection was not closed after a bad data chunk %v", err)
+	}
+}
+
+func TestFlushStatsAndVersion(t *testing.T) {
+	cache, address := startServer(t, "unix", filepath.Join(t.TempDir(), "spectre.sock"))
+	conn, err := net.Dial("unix", address)
+	if err != nil {
+		t.Fatalf("dial failed %v", err)
+	}
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	exchange(t, conn, in, "version\r\n", "VERSION "+version+"\r\n")
+	exchange(t, conn, in, "set vivek 0 0 5\r\nvivek\r\nget vivek\r\n", "STORED\r\nVALUE vivek 0 5\r\nvivek\r\nEND\r\n")
+	io.WriteString(conn, "stats\r\n")
+	stats := map[string]string{}
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stats failed %v", err)
+		}
+		if line == "END\r\n" {
+			break
+		}
+		fields := strings.Fields(line)
+		stats[fields[1]] = fields[2]
+	}
+	if stats["curr_items"] != "1" || stats["get_hits"] != "1" || stats["cmd_set"] != "1" || stats["curr_connections"] != "1" {
+		t.Fatalf("bad stats %v", stats)
+	}
+	exchange(t, conn, in, "flush_all\r\n", "OK\r\n")
+	if cache.VolatileLRUCacheCurrentSize() != 0 {
+		t.Fatalf("cache was not flushed")
+	}
+	exchange(t, conn, in, "bogus\r\n", "ERROR\r\n")
+	exchange(t, conn, in, "quit\r\n", "")
+	if _, err := in.ReadByte(); err != io.EOF {
+		t.Fatalf("connection was not closed after quit %v", err)
+	}
+}
+
+func TestValuesSetFromGo(t *testing.T) {
+	cache, address := startServer(t, "tcp", "127.0.0.1:0")
+	cache.VolatileLRUCacheSet("bytes", []byte("vivek"), 5, 0)
+	cache.VolatileLRUCacheSet("number", 42, 2, 0)
+	conn, _ := net.Dial("tcp", address)
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	exchange(t, conn, in, "get bytes number\r\n", "VALUE bytes 0 5\r\nvivek\r\nVALUE number 0 2\r\n42\r\nEND\r\n")
+	exchange(t, conn, in, "incr number 1\r\n", "43\r\n")
+}
+
+func TestDelayedFlush(t *testing.T) {
+	cache, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour))
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := New(cache)
+	go server.Serve(listener)
+	conn, _ := net.Dial("tcp", listener.Addr().String())
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	pending := func() *time.Timer {
+		server.Lock()
+		defer server.Unlock()
+		return server.flushTimer
+	}
+
+	cache.VolatileLRUCacheSet("vivek", []byte("vivek"), 5, 0)
+	exchange(t, conn, in, "flush_all 1\r\n", "OK\r\n")
+	first := pending()
+	exchange(t, conn, in, "flush_all 3600\r\n", "OK\r\n")
+	if first == nil || first.Stop() {
+		t.Fatalf("the first flush_all was not replaced")
+	}
+	exchange(t, conn, in, "flush_all 9223372037\r\n", "CLIENT_ERROR bad command line format\r\n")
+	server.Close()
+	if pending().Stop() {
+		t.Fatalf("the delayed flush_all was not cancelled by Close")
+	}
+	if _, ok := cache.VolatileLRUCacheGet("vivek"); !ok {
+		t.Fatalf("cache was flushed before the delay")
+	}
+}
+
+func TestSnapshotOfItems(t *testing.T) {
+	cache, address := startServer(t, "tcp", "127.0.0.1:0")
+	conn, _ := net.Dial("tcp", address)
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	exchange(t, conn, in, "set vivek 9 0 5\r\nvivek\r\n", "STORED\r\n")
+	var buffer bytes.Buffer
+	if err := cache.SaveSnapshot(&buffer); err != nil {
+		t.Fatalf("snapshot of items failed %v", err)
+	}
+	restored, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour))
+	if err := restored.LoadSnapshot(&buffer); err != nil {
+		t.Fatalf("load of items failed %v", err)
+	}
+	if value, _ := restored.VolatileLRUCacheGet("vivek"); value.(Item).Flags != 9 {
+		t.Fatalf("flags were not restored %v", value)
+	}
+}
+
+func BenchmarkPipelinedGet(b *testing.B) {
+	cache, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("vivek", Item{Value: []byte("vivek")}, 5, 0)
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := New(cache)
+	go server.Serve(listener)
+	defer server.Close()
+	conn, _ := net.Dial("tcp", listener.Addr().String())
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	request := strings.Repeat("get vivek\r\n", 100)
+	reply := make([]byte, 100*len("VALUE vivek 0 5\r\nvivek\r\nEND\r\n"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.WriteString(conn, request)
+		io.ReadFull(in, reply)
+	}
+}
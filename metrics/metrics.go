@@ -0,0 +1,166 @@
+// Package metrics exports the statistics of spectre caches in the
+// Prometheus text exposition format and through expvar, without any
+// dependency on the Prometheus client library.
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/vivek07672/spectre"
+)
+
+// registryError is the error which is thrown when a cache can not be
+// registered.
+type registryError struct {
+	errorNumber int
+	problem     string
+}
+
+func (re *registryError) Error() string {
+	return fmt.Sprintf("%d---%s", re.errorNumber, re.problem)
+}
+
+var (
+	// DuplicateNameError returns when a cache is registered under a name
+	// already in use
+	DuplicateNameError = &registryError{problem: "a cache is already registered under this name", errorNumber: 16}
+	// InvalidSourceError returns when a nil cache or an empty name is registered
+	InvalidSourceError = &registryError{problem: "cache and name must be given", errorNumber: 17}
+	// ExpvarNameError returns when a registry is published under an empty
+	// name or a name expvar already has
+	ExpvarNameError = &registryError{problem: "expvar name must be given and not already published", errorNumber: 32}
+)
+
+// Source is a cache the metrics are read from. Cache, VolatileLRUCache and
+// their generic forms TypedCache and VolatileLRU are all sources.
+type Source interface {
+	Stats() spectre.Stats
+	ShardOccupancy() []int
+}
+
+// Registry is a set of caches identified by their name.
+type Registry struct {
+	sources map[string]Source
+	sync.RWMutex
+}
+
+// NewRegistry returns an empty Registry, see PublishExpvar to publish it to
+// expvar.
+func NewRegistry() *Registry {
+	return &Registry{sources: make(map[string]Source)}
+}
+
+// DefaultRegistry is the registry used by the package level functions.
+var DefaultRegistry = NewRegistry()
+
+// expvarLock makes checking and publishing an expvar name one step, expvar
+// panics on a name published twice.
+var expvarLock sync.Mutex
+
+// Register adds a cache to the registry under the given name, which becomes
+// the cache label of its metrics.
+// return values :
+//		error: DuplicateNameError if the name is taken, InvalidSourceError
+//			   for an empty name or a nil cache else nil
+func (r *Registry) Register(name string, cache Source) error {
+	if name == "" || cache == nil {
+		return InvalidSourceError
+	}
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.sources[name]; ok {
+		return DuplicateNameError
+	}
+	r.sources[name] = cache
+	return nil
+}
+
+// Unregister removes the cache of the given name from the registry.
+func (r *Registry) Unregister(name string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.sources, name)
+}
+
+// namedStats is the snapshot of a registered cache.
+type namedStats struct {
+	name      string
+	stats     spectre.Stats
+	occupancy []int
+}
+
+// snapshot returns the statistics of every cache ordered on their name.
+func (r *Registry) snapshot() []namedStats {
+	r.RLock()
+	snapshots := make([]namedStats, 0, len(r.sources))
+	for name, source := range r.sources {
+		snapshots = append(snapshots, namedStats{name: name, stats: source.Stats(), occupancy: source.ShardOccupancy()})
+	}
+	r.RUnlock()
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].name < snapshots[j].name })
+	return snapshots
+}
+
+// Expvar returns an expvar variable reporting the statistics of every cache
+// of the registry, to publish it under a name of choice.
+func (r *Registry) Expvar() expvar.Var {
+	return expvar.Func(func() interface{} {
+		caches := make(map[string]interface{})
+		for _, snapshot := range r.snapshot() {
+			removals := make(map[string]uint64)
+			for reason, count := range snapshot.stats.Removals {
+				removals[reason.String()] = count
+			}
+			caches[snapshot.name] = map[string]interface{}{
+				"hits":           snapshot.stats.Hits,
+				"misses":         snapshot.stats.Misses,
+				"expired_misses": snapshot.stats.ExpiredMisses,
+				"sets":           snapshot.stats.Sets,
+				"rejected_sets":  snapshot.stats.RejectedSets,
+				"removals":       removals,
+				"evicted_bytes":  snapshot.stats.BytesEvicted,
+				"entries":        snapshot.stats.Entries,
+				"bytes":          snapshot.stats.Bytes,
+				"max_bytes":      snapshot.stats.MaxSize,
+				"load_successes": snapshot.stats.LoadSuccesses,
+				"load_failures":  snapshot.stats.LoadFailures,
+				"shard_entries":  snapshot.occupancy,
+			}
+		}
+		return caches
+	})
+}
+
+// PublishExpvar publishes the statistics of the registry to expvar under
+// the given name, see Expvar.
+// return values :
+//		error: ExpvarNameError for an empty name or a name already published
+//			   else nil
+func (r *Registry) PublishExpvar(name string) error {
+	expvarLock.Lock()
+	defer expvarLock.Unlock()
+	if name == "" || expvar.Get(name) != nil {
+		return ExpvarNameError
+	}
+	expvar.Publish(name, r.Expvar())
+	return nil
+}
+
+// Register adds a cache to DefaultRegistry, see Registry Register.
+func Register(name string, cache Source) error {
+	return DefaultRegistry.Register(name, cache)
+}
+
+// Unregister removes a cache from DefaultRegistry.
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
+
+// PublishExpvar publishes DefaultRegistry to expvar, usually as "spectre",
+// see Registry PublishExpvar.
+func PublishExpvar(name string) error {
+	return DefaultRegistry.PublishExpvar(name)
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry()
+	cache, _ := spectre.NewCache(spectre.WithMaxSize(100))
+	if err := registry.Register("users", cache); err != nil {
+		t.Fatalf("register failed %v", err)
+	}
+	if err := registry.Register("users", cache); err != DuplicateNameError {
+		t.Fatalf("expected DuplicateNameError got %v", err)
+	}
+	if err := registry.Register("", cache); err != InvalidSourceError {
+		t.Fatalf("expected InvalidSourceError got %v", err)
+	}
+	registry.Unregister("users")
+	if err := registry.Register("users", cache); err != nil {
+		t.Fatalf("register after unregister failed %v", err)
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	registry := NewRegistry()
+	cache, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour), spectre.WithPartitions(2))
+	registry.Register("sessions", cache)
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheGet("vivek")
+	cache.VolatileLRUCacheDelete("vivek")
+
+	var caches map[string]struct {
+		Hits         uint64            `json:"hits"`
+		Removals     map[string]uint64 `json:"removals"`
+		MaxBytes     int               `json:"max_bytes"`
+		ShardEntries []int             `json:"shard_entries"`
+	}
+	if err := json.Unmarshal([]byte(registry.Expvar().String()), &caches); err != nil {
+		t.Fatalf("bad expvar json %v", err)
+	}
+	sessions := caches["sessions"]
+	if sessions.Hits != 1 || sessions.Removals["deleted"] != 1 || sessions.MaxBytes != 100 || len(sessions.ShardEntries) != 2 {
+		t.Fatalf("unexpected expvar %+v", sessions)
+	}
+	if expvar.Get("spectre") != nil {
+		t.Fatalf("default registry was published on import")
+	}
+	if err := PublishExpvar("spectre"); err != nil || expvar.Get("spectre") == nil {
+		t.Fatalf("default registry is not published %v", err)
+	}
+	for _, name := range []string{"spectre", ""} {
+		if err := registry.PublishExpvar(name); err != ExpvarNameError {
+			t.Fatalf("expected ExpvarNameError for %q got %v", name, err)
+		}
+	}
+}
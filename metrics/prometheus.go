@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vivek07672/spectre"
+)
+
+// contentType is the content type of the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// removalReasons are the reasons reported by spectre_removals_total, in the
+// order of their declaration.
+var removalReasons = []spectre.RemovalReason{spectre.Expired, spectre.Evicted, spectre.Deleted, spectre.Replaced, spectre.Cleared}
+
+// counter is a metric with a single sample per cache.
+type counter struct {
+	name  string
+	kind  string
+	help  string
+	value func(stats spectre.Stats) float64
+}
+
+var counters = []counter{
+	{"spectre_hits_total", "counter", "Reads which found their key.", func(s spectre.Stats) float64 { return float64(s.Hits) }},
+	{"spectre_misses_total", "counter", "Reads which did not find their key.", func(s spectre.Stats) float64 { return float64(s.Misses) }},
+	{"spectre_expired_misses_total", "counter", "Reads which found their key expired.", func(s spectre.Stats) float64 { return float64(s.ExpiredMisses) }},
+	{"spectre_sets_total", "counter", "Values stored.", func(s spectre.Stats) float64 { return float64(s.Sets) }},
+	{"spectre_rejected_sets_total", "counter", "Values refused for their size.", func(s spectre.Stats) float64 { return float64(s.RejectedSets) }},
+	{"spectre_evicted_bytes_total", "counter", "Size of the keys evicted or expired.", func(s spectre.Stats) float64 { return float64(s.BytesEvicted) }},
+	{"spectre_entries", "gauge", "Keys in the cache.", func(s spectre.Stats) float64 { return float64(s.Entries) }},
+	{"spectre_bytes", "gauge", "Size of the keys in the cache.", func(s spectre.Stats) float64 { return float64(s.Bytes) }},
+	{"spectre_max_bytes", "gauge", "Size the cache is allowed to grow to.", func(s spectre.Stats) float64 { return float64(s.MaxSize) }},
+}
+
+// WriteTo writes the metrics of every cache of the registry to w in the
+// Prometheus text exposition format, the caches ordered on their name.
+// return values :
+//		int64: the number of bytes written
+//		error: the first error of w
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	snapshots := r.snapshot()
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	for _, metric := range counters {
+		out.header(metric.name, metric.kind, metric.help)
+		for _, snapshot := range snapshots {
+			out.sample(metric.name, metric.value(snapshot.stats), "cache", snapshot.name)
+		}
+	}
+
+	out.header("spectre_removals_total", "counter", "Keys which left the cache, by reason.")
+	for _, snapshot := range snapshots {
+		for _, reason := range removalReasons {
+			out.sample("spectre_removals_total", float64(snapshot.stats.Removals[reason]), "cache", snapshot.name, "reason", reason.String())
+		}
+	}
+
+	out.header("spectre_shard_entries", "gauge", "Keys held by every internal map of the cache.")
+	for _, snapshot := range snapshots {
+		for shard, entries := range snapshot.occupancy {
+			out.sample("spectre_shard_entries", float64(entries), "cache", snapshot.name, "shard", strconv.Itoa(shard))
+		}
+	}
+
+	out.header("spectre_loads_total", "counter", "Loads of GetOrLoad and of the refreshes, by result.")
+	for _, snapshot := range snapshots {
+		out.sample("spectre_loads_total", float64(snapshot.stats.LoadSuccesses), "cache", snapshot.name, "result", "success")
+		out.sample("spectre_loads_total", float64(snapshot.stats.LoadFailures), "cache", snapshot.name, "result", "failure")
+	}
+
+	out.header("spectre_load_duration_seconds", "histogram", "Duration of the loads.")
+	for _, snapshot := range snapshots {
+		histogram := snapshot.stats.LoadLatency
+		var cumulative uint64
+		for i, bound := range histogram.Bounds {
+			cumulative = cumulative + histogram.Counts[i]
+			le := strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)
+			out.sample("spectre_load_duration_seconds_bucket", float64(cumulative), "cache", snapshot.name, "le", le)
+		}
+		out.sample("spectre_load_duration_seconds_bucket", float64(histogram.Count), "cache", snapshot.name, "le", "+Inf")
+		out.sample("spectre_load_duration_seconds_sum", histogram.Sum.Seconds(), "cache", snapshot.name)
+		out.sample("spectre_load_duration_seconds_count", float64(histogram.Count), "cache", snapshot.name)
+	}
+
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+	return out.n, out.err
+}
+
+// ServeHTTP writes the metrics of the registry, it makes the registry an
+// http.Handler to mount on the scrape path.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.WriteTo(w)
+}
+
+// Handler returns the http.Handler serving the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return r
+}
+
+// Handler returns the http.Handler serving the metrics of DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+// countingWriter writes the lines of the exposition format, it keeps the
+// first error and the number of bytes written.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) writeString(s string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(s)
+	cw.n = cw.n + int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) header(name string, kind string, help string) {
+	cw.writeString(fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind))
+}
+
+// sample writes a sample, labels are given as name, value pairs.
+func (cw *countingWriter) sample(name string, value float64, labels ...string) {
+	var line strings.Builder
+	line.WriteString(name)
+	if len(labels) > 0 {
+		line.WriteByte('{')
+		for i := 0; i+1 < len(labels); i = i + 2 {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.WriteString(labels[i])
+			line.WriteString(`="`)
+			line.WriteString(escapeLabel(labels[i+1]))
+			line.WriteByte('"')
+		}
+		line.WriteByte('}')
+	}
+	line.WriteByte(' ')
+	line.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	line.WriteByte('\n')
+	cw.writeString(line.String())
+}
+
+// labelEscaper escapes the characters not allowed as such in label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
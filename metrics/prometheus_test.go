@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	cache, _ := spectre.New(spectre.WithMaxSize(10), spectre.WithTTL(time.Hour), spectre.WithPartitions(2))
+	registry.Register(`api "v1"`, cache)
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("spectre", "spectre", 5, time.Duration(0))
+	cache.VolatileLRUCacheGet("spectre")
+	cache.VolatileLRUCacheGet("vivek")
+	cache.GetOrLoad(context.Background(), "load", func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		return key, 1, 0, nil
+	})
+
+	server := httptest.NewServer(registry.Handler())
+	defer server.Close()
+	response, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("scrape failed %v", err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("unexpected content type %v", contentType)
+	}
+	body, _ := io.ReadAll(response.Body)
+	text := string(body)
+	for _, line := range []string{
+		"# TYPE spectre_hits_total counter",
+		`spectre_hits_total{cache="api \"v1\""} 1`,
+		`spectre_misses_total{cache="api \"v1\""} 2`,
+		`spectre_max_bytes{cache="api \"v1\""} 10`,
+		`spectre_removals_total{cache="api \"v1\"",reason="evicted"} 2`,
+		`spectre_evicted_bytes_total{cache="api \"v1\""} 10`,
+		`spectre_shard_entries{cache="api \"v1\"",shard="1"}`,
+		`spectre_loads_total{cache="api \"v1\"",result="success"} 1`,
+		"# TYPE spectre_load_duration_seconds histogram",
+		`spectre_load_duration_seconds_bucket{cache="api \"v1\"",le="+Inf"} 1`,
+		`spectre_load_duration_seconds_count{cache="api \"v1\""} 1`,
+	} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("missing %q in\n%s", line, text)
+		}
+	}
+}
+
+func TestWriteToOrder(t *testing.T) {
+	registry := NewRegistry()
+	for _, name := range []string{"zeta", "alpha"} {
+		cache, _ := spectre.NewCache(spectre.WithMaxSize(100))
+		registry.Register(name, cache)
+	}
+	var out strings.Builder
+	n, err := registry.WriteTo(&out)
+	if err != nil || n != int64(out.Len()) {
+		t.Fatalf("write failed %v %v", n, err)
+	}
+	text := out.String()
+	if strings.Index(text, `spectre_hits_total{cache="alpha"}`) > strings.Index(text, `spectre_hits_total{cache="zeta"}`) {
+		t.Fatalf("caches are not ordered on their name\n%s", text)
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	registry := NewRegistry()
+	cache, _ := spectre.NewCache(spectre.WithMaxSize(100), spectre.WithPartitions(16))
+	registry.Register("users", cache)
+	for i := 0; i < b.N; i++ {
+		registry.WriteTo(io.Discard)
+	}
+}
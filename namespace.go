@@ -0,0 +1,385 @@
+package spectre
+
+import (
+	"fmt"
+	"time"
+)
+
+// namespaceError is the error which is thrown when a namespace can not be
+// configured or can not take a value.
+type namespaceError struct {
+	errorNumber int
+	problem     string
+}
+
+func (ne *namespaceError) Error() string {
+	return fmt.Sprintf("%d---%s", ne.errorNumber, ne.problem)
+}
+
+var (
+	// InvalidNamespaceError returns when a namespace has no name, a min size
+	// above its max size or the max size of the cache, or when the min
+	// sizes of the namespaces do not fit in the cache together
+	InvalidNamespaceError = &namespaceError{problem: "namespace needs a name and min size <= max size <= cache max size, with all min sizes fitting in the cache", errorNumber: 30}
+	// NamespaceLimitError returns when size of value is greater than the max
+	// size of its namespace
+	NamespaceLimitError = &namespaceError{problem: "data size is more than the namespace max size", errorNumber: 31}
+)
+
+// namespace is the accounting of a Namespace inside the cache. Its quotas
+// and size are guarded by the lock of the cache ; its order is guarded by
+// the lock of the cache and, for the reads, by the policy lock like the
+// eviction policy.
+type namespace[K comparable] struct {
+	name    string
+	minSize int
+	maxSize int
+	ttl     time.Duration
+	size    int
+	entries int
+	order   *listPolicy[K] // the keys, least recently used first
+	stats   *cacheStats
+}
+
+// recordRemoval counts a key of the namespace leaving the cache, it does
+// nothing for the keys out of any namespace.
+func (ns *namespace[K]) recordRemoval(size int, reason RemovalReason) {
+	if ns != nil {
+		ns.stats.recordRemovals(1, size, reason)
+	}
+}
+
+// namespaceIndex maps the keys set through a Namespace to it, so the cache
+// keeps the size of every namespace while its keys are set, evicted,
+// expired or deleted. It is guarded by the lock of the cache.
+type namespaceIndex[K comparable] struct {
+	byName map[string]*namespace[K]
+	ofKey  map[K]*namespace[K]
+}
+
+func newNamespaceIndex[K comparable]() *namespaceIndex[K] {
+	return &namespaceIndex[K]{
+		byName: make(map[string]*namespace[K]),
+		ofKey:  make(map[K]*namespace[K]),
+	}
+}
+
+// active tells if the cache has namespaces.
+func (ni *namespaceIndex[K]) active() bool {
+	return len(ni.byName) > 0
+}
+
+// of returns the namespace of the key, nil for a key out of any namespace.
+func (ni *namespaceIndex[K]) of(key K) *namespace[K] {
+	return ni.ofKey[key]
+}
+
+// set moves the key of the given old and new size to the namespace, nil
+// for none.
+func (ni *namespaceIndex[K]) set(key K, oldSize int, size int, ns *namespace[K]) {
+	ni.remove(key, oldSize)
+	if ns == nil {
+		return
+	}
+	ni.ofKey[key] = ns
+	ns.size = ns.size + size
+	ns.entries++
+	ns.order.OnInsert(key, size)
+}
+
+// remove takes the key of the given size out of its namespace.
+func (ni *namespaceIndex[K]) remove(key K, size int) {
+	ns, ok := ni.ofKey[key]
+	if !ok {
+		return
+	}
+	delete(ni.ofKey, key)
+	ns.size = ns.size - size
+	ns.entries--
+	ns.order.OnRemove(key)
+}
+
+// access reports a read of the key to the order of its namespace, the
+// caller holds the policy lock.
+func (ni *namespaceIndex[K]) access(key K) {
+	if ns, ok := ni.ofKey[key]; ok {
+		ns.order.OnAccess(key)
+	}
+}
+
+// reset empties every namespace, counting their keys as Cleared.
+func (ni *namespaceIndex[K]) reset() {
+	for _, ns := range ni.byName {
+		ns.stats.recordRemovals(ns.entries, ns.size, Cleared)
+		ns.size = 0
+		ns.entries = 0
+		ns.order.Reset()
+	}
+	ni.ofKey = make(map[K]*namespace[K])
+}
+
+// fitsNamespace tells if the key can take the given size without the
+// namespace going over its max size, always for a nil namespace.
+func (c *TypedCache[K, V]) fitsNamespace(key K, size int, ns *namespace[K]) bool {
+	if ns == nil {
+		return true
+	}
+	if c.namespaces.of(key) == ns {
+		size = size - c.Size[key]
+	}
+	return size <= ns.maxSize-ns.size
+}
+
+// makeNamespaceSpace is makeSpace for a cache with namespaces. While the
+// namespace of the write is over its max size its own least recently used
+// keys are evicted. Then the namespaces over their min size give their least
+// recently used keys, the one most over its min size first. When none is
+// left the victim is the first key in the order of the eviction policy
+// which is out of any namespace, of the namespace of the write, or of a
+// namespace staying at its min size without it. The caller holds the cache
+// lock.
+func (c *TypedCache[K, V]) makeNamespaceSpace(key K, size int, ns *namespace[K]) ([]K, error) {
+	var evicted []K
+	evictKey := func(victim K) {
+		c.policyLock.Lock()
+		c.policy.OnRemove(victim)
+		c.policyLock.Unlock()
+		c.evict(victim)
+		evicted = append(evicted, victim)
+	}
+	for !c.fitsNamespace(key, size, ns) {
+		victim, ok := ns.order.front()
+		if !ok {
+			return evicted, LowSpaceError
+		}
+		evictKey(victim)
+	}
+	for !c.isSpaceAvaible(key, size) {
+		victim, ok := c.overMinVictim()
+		if !ok {
+			break
+		}
+		evictKey(victim)
+	}
+	if c.isSpaceAvaible(key, size) {
+		return evicted, nil
+	}
+	for _, victim := range c.policyOrder() {
+		owner := c.namespaces.of(victim)
+		victimSize, ok := c.Size[victim]
+		if !ok || (owner != nil && owner != ns && owner.size-victimSize < owner.minSize) {
+			continue
+		}
+		evictKey(victim)
+		if c.isSpaceAvaible(key, size) {
+			return evicted, nil
+		}
+	}
+	return evicted, LowSpaceError
+}
+
+// overMinVictim returns the least recently used key of the namespace most
+// over its min size, among the namespaces staying at their min size without
+// it. The caller holds the cache lock.
+func (c *TypedCache[K, V]) overMinVictim() (K, bool) {
+	var victim K
+	found := false
+	mostOver := 0
+	for _, ns := range c.namespaces.byName {
+		over := ns.size - ns.minSize
+		if over <= mostOver {
+			continue
+		}
+		if key, ok := ns.order.front(); ok && c.Size[key] <= over {
+			victim = key
+			found = true
+			mostOver = over
+		}
+	}
+	return victim, found
+}
+
+// policyOrder returns the keys of the cache in the order of the eviction
+// policy, the next victim first, or in no particular order for a policy
+// which is not an OrderedPolicy. The caller holds the cache lock.
+func (c *TypedCache[K, V]) policyOrder() []K {
+	c.policyLock.Lock()
+	ordered, ok := c.policy.(OrderedPolicy[K])
+	var keys []K
+	if ok {
+		keys = ordered.Keys()
+	}
+	c.policyLock.Unlock()
+	if ok {
+		return keys
+	}
+	keys = make([]K, 0, len(c.Size))
+	for key := range c.Size {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Namespace is a named part of a VolatileLRU, for the teams sharing one
+// cache. The namespaces share the max size of the cache, each one with a
+// min size its keys are never evicted under by the writes of the others,
+// a max size it never grows over and a default ttl. When the cache is full
+// the namespaces over their min size are evicted first, the one most over
+// it first ; then the eviction policy picks among the keys out of any
+// namespace and the keys of the namespace of the write.
+// The namespaces share the keys of the cache : a key belongs to the
+// namespace of its last set, so each namespace should prefix its keys. A
+// Namespace only sees its own keys. The namespaces of the keys are not
+// kept by snapshots and the op log.
+type Namespace[K comparable, V any] struct {
+	cache *VolatileLRU[K, V]
+	ns    *namespace[K]
+}
+
+// Namespace returns the namespace of the name, created on the first call
+// with a min size of 0, the max size of the cache and its ttl. The options
+// change the quotas and the ttl of the namespace, also on a later call ;
+// the keys over a lowered max size are evicted by the next set.
+// return values :
+//		namespace: the namespace of the name
+//		error: InvalidNamespaceError for a bad name or bad quotas
+func (vlruCache *VolatileLRU[K, V]) Namespace(name string, opts ...NamespaceOption) (*Namespace[K, V], error) {
+	c := vlruCache.cache
+	c.Lock()
+	defer c.Unlock()
+	ns, ok := c.namespaces.byName[name]
+	cfg := namespaceConfig{maxSize: c.MaxSize}
+	if ok {
+		cfg = namespaceConfig{minSize: ns.minSize, maxSize: ns.maxSize, ttl: ns.ttl}
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	minSizes := cfg.minSize
+	for _, other := range c.namespaces.byName {
+		if other != ns {
+			minSizes = minSizes + other.minSize
+		}
+	}
+	if name == "" || cfg.minSize < 0 || cfg.minSize > cfg.maxSize || cfg.maxSize > c.MaxSize ||
+		cfg.ttl < 0 || minSizes > c.MaxSize {
+		return nil, InvalidNamespaceError
+	}
+	if !ok {
+		ns = &namespace[K]{name: name, order: newListPolicy[K](true), stats: &cacheStats{}}
+		c.namespaces.byName[name] = ns
+	}
+	ns.minSize = cfg.minSize
+	ns.maxSize = cfg.maxSize
+	ns.ttl = cfg.ttl
+	return &Namespace[K, V]{cache: vlruCache, ns: ns}, nil
+}
+
+// Name returns the name of the namespace.
+func (n *Namespace[K, V]) Name() string {
+	return n.ns.name
+}
+
+// Set sets the key in the namespace, see VolatileLRU Set. A keyExpire of 0
+// takes the ttl of the namespace, the global ttl when it has none. A key
+// of another namespace moves to this one.
+// return values :
+//		ok: true if operation is successful else false
+//		error: NamespaceLimitError for a value bigger than the max size of
+//			   the namespace, else the errors of VolatileLRU Set
+func (n *Namespace[K, V]) Set(key K, value V, size int, keyExpire time.Duration, opts ...SetOption) (bool, error) {
+	if keyExpire != NoExpiry && keyExpire != KeepTTL && keyExpire.Seconds() <= 0 {
+		n.cache.cache.RLocker().Lock()
+		if n.ns.ttl > 0 {
+			keyExpire = n.ns.ttl
+		}
+		n.cache.cache.RLocker().Unlock()
+	}
+	opts = append(opts[:len(opts):len(opts)], inNamespace(n.ns))
+	success, err := n.cache.Set(key, value, size, keyExpire, opts...)
+	n.ns.stats.recordSet(success, err)
+	return success, err
+}
+
+// owns tells if the key belongs to the namespace.
+func (n *Namespace[K, V]) owns(key K) bool {
+	n.cache.cache.RLocker().Lock()
+	defer n.cache.cache.RLocker().Unlock()
+	return n.cache.cache.namespaces.of(key) == n.ns
+}
+
+// Get returns the value of a key of the namespace, see VolatileLRU Get.
+// The keys of the other namespaces are misses.
+// return values :
+//		val: value corresponding to the key, the zero value on a miss
+//		ok: true if success else false
+func (n *Namespace[K, V]) Get(key K) (V, bool) {
+	if !n.owns(key) {
+		var zero V
+		n.ns.stats.recordGet(false)
+		return zero, false
+	}
+	value, ok := n.cache.Get(key)
+	n.ns.stats.recordGet(ok)
+	return value, ok
+}
+
+// Delete deletes a key of the namespace, the keys of the other namespaces
+// are left alone.
+func (n *Namespace[K, V]) Delete(key K) {
+	if n.owns(key) {
+		n.cache.Delete(key)
+	}
+}
+
+// Clear removes every key of the namespace, the removal listeners are
+// told they were Cleared. With WithInvalidation the keys are invalidated
+// on the other caches too.
+func (n *Namespace[K, V]) Clear() {
+	vlruCache := n.cache
+	vlruCache.Lock()
+	if vlruCache.isClosed() {
+		vlruCache.Unlock()
+		return
+	}
+	keys := vlruCache.cache.removeNamespace(n.ns)
+	for _, key := range keys {
+		vlruCache.removeLink(key)
+		vlruCache.logDelete(key)
+	}
+	vlruCache.Unlock()
+	for _, key := range keys {
+		vlruCache.invalidate(key)
+	}
+}
+
+// removeNamespace removes the keys of the namespace and returns them.
+func (c *TypedCache[K, V]) removeNamespace(ns *namespace[K]) []K {
+	c.Lock()
+	defer c.Unlock()
+	keys := ns.order.Keys()
+	for _, key := range keys {
+		c.removeLocked(key, Cleared)
+	}
+	return keys
+}
+
+// CurrentSize returns the size in bytes of the keys of the namespace.
+func (n *Namespace[K, V]) CurrentSize() int {
+	n.cache.cache.RLocker().Lock()
+	defer n.cache.cache.RLocker().Unlock()
+	return n.ns.size
+}
+
+// Stats returns the counters of the namespace : the reads, sets and
+// removals of its keys, its entries and bytes, its max size as MaxSize.
+// The loads are counted by the cache only.
+func (n *Namespace[K, V]) Stats() Stats {
+	stats := n.ns.stats.snapshot()
+	n.cache.cache.RLocker().Lock()
+	stats.Entries = n.ns.entries
+	stats.Bytes = n.ns.size
+	stats.MaxSize = n.ns.maxSize
+	n.cache.cache.RLocker().Unlock()
+	return stats
+}
@@ -0,0 +1,202 @@
+package spectre
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNamespaceQuotas(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	search, err := cache.Namespace("search", WithQuota(30, 50))
+	if err != nil {
+		t.Fatalf("namespace failed %v", err)
+	}
+	ads, _ := cache.Namespace("ads", WithQuota(20, 40))
+
+	// a namespace evicts its own keys at its max size
+	for i := 0; i < 10; i++ {
+		search.Set("search:"+strconv.Itoa(i), "value", 10, 0)
+	}
+	if search.CurrentSize() != 50 || cache.VolatileLRUCacheCurrentSize() != 50 {
+		t.Fatalf("expected 50 bytes in search got %d", search.CurrentSize())
+	}
+	if stats := search.Stats(); stats.Entries != 5 || stats.Removals[Evicted] != 5 || stats.Sets != 10 {
+		t.Fatalf("bad namespace stats %+v", stats)
+	}
+	if _, ok := search.Get("search:4"); ok {
+		t.Fatalf("least recently used key of the namespace was not evicted")
+	}
+	if ok, err := ads.Set("ads:big", "value", 41, 0); ok || err != NamespaceLimitError {
+		t.Fatalf("expected NamespaceLimitError got %v %v", ok, err)
+	}
+
+	// fill the cache out of any namespace
+	for i := 0; i < 5; i++ {
+		cache.VolatileLRUCacheSet("plain:"+strconv.Itoa(i), "value", 10, 0)
+	}
+	// search is evicted down to its min size, then the plain keys go until
+	// ads is over its own min size
+	for i := 0; i < 4; i++ {
+		ads.Set("ads:"+strconv.Itoa(i), "value", 10, 0)
+	}
+	if search.CurrentSize() != 30 || ads.CurrentSize() != 30 || cache.VolatileLRUCacheCurrentSize() != 100 {
+		t.Fatalf("expected 30 and 30 bytes got %d and %d", search.CurrentSize(), ads.CurrentSize())
+	}
+	if _, ok := ads.Get("ads:0"); ok {
+		t.Fatalf("namespace over its min size was not evicted before the plain keys")
+	}
+	// a noisy writer out of the namespaces can not take their min sizes
+	for i := 5; i < 20; i++ {
+		cache.VolatileLRUCacheSet("plain:"+strconv.Itoa(i), "value", 10, 0)
+	}
+	if search.CurrentSize() != 30 || ads.CurrentSize() != 20 {
+		t.Fatalf("expected the min sizes 30 and 20 got %d and %d", search.CurrentSize(), ads.CurrentSize())
+	}
+	// the spared keys stay readable
+	if _, ok := search.Get("search:9"); !ok {
+		t.Fatalf("key within the min size was evicted")
+	}
+	// only namespaces at their min size left : the writer gives its own keys
+	cache.VolatileLRUCacheClear()
+	cache.Namespace("search", WithQuota(50, 100))
+	cache.Namespace("ads", WithQuota(50, 100))
+	for i := 0; i < 5; i++ {
+		ads.Set("ads:"+strconv.Itoa(i), "value", 10, 0)
+	}
+	for i := 0; i < 10; i++ {
+		search.Set("search:"+strconv.Itoa(i), "value", 10, 0)
+	}
+	if search.CurrentSize() != 50 || ads.CurrentSize() != 50 {
+		t.Fatalf("expected the min sizes 50 and 50 got %d and %d", search.CurrentSize(), ads.CurrentSize())
+	}
+	if _, ok := search.Get("search:9"); !ok {
+		t.Fatalf("last key of the writer was not kept")
+	}
+}
+
+func TestNamespaceEvictsMostOverMinFirst(t *testing.T) {
+	for _, factory := range []PolicyFactory[string]{NewLRUPolicy[string], NewFIFOPolicy[string], NewRandomPolicy[string], NewTinyLFUPolicy[string]} {
+		cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithEvictionPolicy(factory))
+		quiet, _ := cache.Namespace("quiet", WithQuota(40, 100))
+		steady, _ := cache.Namespace("steady", WithQuota(10, 100))
+		noisy, _ := cache.Namespace("noisy", WithQuota(10, 100))
+		for i := 0; i < 3; i++ {
+			quiet.Set("quiet:"+strconv.Itoa(i), "value", 10, 0)
+		}
+		for i := 0; i < 2; i++ {
+			steady.Set("steady:"+strconv.Itoa(i), "value", 10, 0)
+		}
+		// the older keys of steady are over its min size too, but noisy is
+		// the most over its own
+		for i := 0; i < 20; i++ {
+			noisy.Set("noisy:"+strconv.Itoa(i), "value", 10, 0)
+		}
+		if quiet.CurrentSize() != 30 || steady.CurrentSize() != 20 || noisy.CurrentSize() != 50 {
+			t.Fatalf("expected 30, 20 and 50 bytes got %d, %d and %d", quiet.CurrentSize(), steady.CurrentSize(), noisy.CurrentSize())
+		}
+		if stats := noisy.Stats(); stats.Removals[Evicted] != 15 {
+			t.Fatalf("expected 15 keys of noisy evicted %+v", stats)
+		}
+		if _, ok := noisy.Get("noisy:19"); !ok {
+			t.Fatalf("last key of noisy was evicted")
+		}
+		cache.Close(context.Background())
+	}
+}
+
+func TestNamespaceKeys(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	search, _ := cache.Namespace("search", WithDefaultTTL(time.Minute))
+	ads, _ := cache.Namespace("ads")
+
+	search.Set("shared", "search", 6, 0)
+	if ttl, ok := cache.TTL("shared"); !ok || ttl > time.Minute {
+		t.Fatalf("default ttl of the namespace not used %v", ttl)
+	}
+	if _, ok := ads.Get("shared"); ok {
+		t.Fatalf("key of another namespace was read")
+	}
+	ads.Delete("shared")
+	if _, ok := search.Get("shared"); !ok {
+		t.Fatalf("key of another namespace was deleted")
+	}
+	// a key moves to the namespace of its last set
+	ads.Set("shared", "ads", 3, 0)
+	if search.CurrentSize() != 0 || ads.CurrentSize() != 3 {
+		t.Fatalf("moved key not accounted %d %d", search.CurrentSize(), ads.CurrentSize())
+	}
+	if ttl, _ := cache.TTL("shared"); ttl <= time.Minute {
+		t.Fatalf("expected the global ttl got %v", ttl)
+	}
+
+	search.Set("search:1", "value", 5, 0)
+	search.Set("search:2", "value", 5, 0)
+	cache.VolatileLRUCacheSet("plain", "value", 5, 0)
+	search.Clear()
+	if cache.VolatileLRUCacheCurrentSize() != 8 || search.CurrentSize() != 0 {
+		t.Fatalf("expected 8 bytes left got %d", cache.VolatileLRUCacheCurrentSize())
+	}
+	// the key moved to ads was replaced for search
+	if stats := search.Stats(); stats.Removals[Cleared] != 2 || stats.Removals[Replaced] != 1 || stats.Hits != 1 || stats.Misses != 0 {
+		t.Fatalf("bad namespace stats %+v", stats)
+	}
+	if stats := ads.Stats(); stats.Misses != 1 || stats.Sets != 1 || stats.Entries != 1 {
+		t.Fatalf("bad namespace stats %+v", stats)
+	}
+	cache.VolatileLRUCacheClear()
+	if stats := ads.Stats(); stats.Entries != 0 || stats.Bytes != 0 || stats.Removals[Cleared] != 1 {
+		t.Fatalf("cache clear not counted by the namespace %+v", stats)
+	}
+}
+
+func TestNamespaceOptions(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	for _, test := range []struct {
+		name string
+		opts []NamespaceOption
+	}{
+		{"", nil},
+		{"search", []NamespaceOption{WithQuota(-1, 10)}},
+		{"search", []NamespaceOption{WithQuota(20, 10)}},
+		{"search", []NamespaceOption{WithQuota(0, 101)}},
+		{"search", []NamespaceOption{WithDefaultTTL(-time.Second)}},
+	} {
+		if _, err := cache.Namespace(test.name, test.opts...); err != InvalidNamespaceError {
+			t.Fatalf("expected InvalidNamespaceError for %+v got %v", test, err)
+		}
+	}
+	if _, err := cache.Namespace("search", WithQuota(60, 100)); err != nil {
+		t.Fatalf("namespace failed %v", err)
+	}
+	if _, err := cache.Namespace("ads", WithQuota(60, 100)); err != InvalidNamespaceError {
+		t.Fatalf("expected the min sizes to be checked together got %v", err)
+	}
+	// a later call changes the quotas of the same namespace
+	search, err := cache.Namespace("search", WithQuota(10, 20))
+	if err != nil || search.Stats().MaxSize != 20 || search.Name() != "search" {
+		t.Fatalf("quotas not updated %v", err)
+	}
+	if _, err := cache.Namespace("ads", WithQuota(60, 100)); err != nil {
+		t.Fatalf("namespace failed %v", err)
+	}
+}
+
+func BenchmarkNamespaceSetUnderPressure(b *testing.B) {
+	cache, _ := New(WithMaxSize(1000), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	search, _ := cache.Namespace("search", WithQuota(300, 600))
+	ads, _ := cache.Namespace("ads", WithQuota(300, 600))
+	for i := 0; i < b.N; i++ {
+		key := strconv.Itoa(i % 2000)
+		if i%2 == 0 {
+			search.Set("search:"+key, "value", 1, 0)
+		} else {
+			ads.Set("ads:"+key, "value", 1, 0)
+		}
+	}
+}
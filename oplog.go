@@ -0,0 +1,586 @@
+package spectre
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy tells an op log when its writes are synced to disk.
+type FsyncPolicy int
+
+const (
+	// FsyncAlways syncs every operation to disk before the call returns.
+	FsyncAlways FsyncPolicy = iota
+	// FsyncEverySecond syncs the log once a second, a crash loses at most
+	// the operations of the last second.
+	FsyncEverySecond
+	// FsyncNever hands the writes to the operating system once a second and
+	// leaves the syncs to it.
+	FsyncNever
+)
+
+// opLogError is the error which is thrown when an op log can not be replayed.
+type opLogError struct {
+	errorNumber int
+	problem     string
+}
+
+func (oe *opLogError) Error() string {
+	return fmt.Sprintf("%d---%s", oe.errorNumber, oe.problem)
+}
+
+// CorruptOpLogError returns when the file given to WithOpLog is not an op
+// log, is of an unknown version or holds a malformed operation. For a
+// corrupt record in the middle of the log it is wrapped with the count of
+// the records skipped
+var CorruptOpLogError = &opLogError{problem: "op log is corrupt or of an unknown version", errorNumber: 23}
+
+const (
+	// opLogMagic and opLogVersion start every op log.
+	opLogMagic   = "SPECTLOG"
+	opLogVersion = 1
+)
+
+// the operations of the log
+const (
+	opSet byte = iota + 1
+	opDelete
+	opClear
+	opExpire
+)
+
+// opLog is the append only log of the operations of a cache. Every record
+// is framed with its length and a CRC-32 checksum, so a record torn by a
+// crash is detected and dropped on replay.
+type opLog struct {
+	path        string
+	file        *os.File
+	out         *bufio.Writer
+	fsync       FsyncPolicy
+	size        int64 // bytes of the log file
+	baseSize    int64 // bytes of the log file after the last compaction
+	rewriteSize int64
+
+	rewriting     bool
+	rewriteBuffer []byte // records appended while the log is rewritten
+	rewrites      sync.WaitGroup
+	ticker        *janitor
+
+	closed bool
+	err    error // the first write error, the log takes no record after it
+	sync.Mutex
+}
+
+// openOpLog opens the log at path, creating it when it is missing, and
+// replays every record of it through apply. A torn record at the end of the
+// log is cut off.
+func openOpLog(path string, fsync FsyncPolicy, rewriteSize int64, apply func(payload []byte) error) (*opLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	size, err := replayOpLog(file, apply)
+	if err == nil {
+		err = file.Truncate(size)
+	}
+	if err == nil {
+		_, err = file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &opLog{
+		path:        path,
+		file:        file,
+		out:         bufio.NewWriter(file),
+		fsync:       fsync,
+		size:        size,
+		baseSize:    size,
+		rewriteSize: rewriteSize,
+	}, nil
+}
+
+// replayOpLog applies the records of the log and returns the size of its
+// valid part. An empty log gets its header. A bad record ending the log was
+// torn by a crash and is left out of the valid part, one followed by more
+// data is an error : the records after it would be lost.
+func replayOpLog(file *os.File, apply func(payload []byte) error) (int64, error) {
+	in := bufio.NewReader(file)
+	header := make([]byte, len(opLogHeader()))
+	n, err := io.ReadFull(in, header)
+	if n == 0 && err == io.EOF {
+		_, err = file.Write(opLogHeader())
+		return int64(len(header)), err
+	}
+	if err != nil || !bytes.Equal(header, opLogHeader()) {
+		return 0, CorruptOpLogError
+	}
+	size := int64(len(header))
+	for {
+		payload, n, err := readOpRecord(in)
+		if err != nil {
+			if _, err := in.Peek(1); err == io.EOF {
+				// the end of the log or a record torn by a crash
+				return size, nil
+			}
+			skipped := 1 + countOpRecords(in)
+			return 0, fmt.Errorf("%w: record at byte %d, %d records skipped", CorruptOpLogError, size, skipped)
+		}
+		if err := apply(payload); err != nil {
+			return 0, err
+		}
+		size = size + n
+	}
+}
+
+// opLogHeader returns the bytes starting every op log.
+func opLogHeader() []byte {
+	return binary.AppendUvarint([]byte(opLogMagic), opLogVersion)
+}
+
+// frameOpRecord frames an operation with its length and checksum.
+func frameOpRecord(payload []byte) []byte {
+	record := binary.AppendUvarint(nil, uint64(len(payload)))
+	record = binary.BigEndian.AppendUint32(record, crc32.ChecksumIEEE(payload))
+	return append(record, payload...)
+}
+
+// readOpRecord reads a framed operation and returns it with the size of
+// its record.
+func readOpRecord(in *bufio.Reader) ([]byte, int64, error) {
+	length, err := binary.ReadUvarint(in)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length > maxSnapshotField {
+		return nil, 0, CorruptOpLogError
+	}
+	var checksum [4]byte
+	if _, err := io.ReadFull(in, checksum[:]); err != nil {
+		return nil, 0, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(in, payload); err != nil {
+		return nil, 0, err
+	}
+	if binary.BigEndian.Uint32(checksum[:]) != crc32.ChecksumIEEE(payload) {
+		return nil, 0, CorruptOpLogError
+	}
+	framing := len(binary.AppendUvarint(nil, length)) + len(checksum)
+	return payload, int64(framing) + int64(length), nil
+}
+
+// countOpRecords counts the whole records left in the log, up to the next
+// bad one.
+func countOpRecords(in *bufio.Reader) int {
+	count := 0
+	for {
+		if _, _, err := readOpRecord(in); err != nil {
+			return count
+		}
+		count++
+	}
+}
+
+// append writes a record to the log, and to the rewrite buffer while the
+// log is compacted.
+func (ol *opLog) append(record []byte) {
+	ol.Lock()
+	defer ol.Unlock()
+	if ol.closed || ol.err != nil {
+		return
+	}
+	if ol.rewriting {
+		ol.rewriteBuffer = append(ol.rewriteBuffer, record...)
+	}
+	if _, err := ol.out.Write(record); err != nil {
+		ol.err = err
+		return
+	}
+	ol.size = ol.size + int64(len(record))
+	if ol.fsync == FsyncAlways {
+		ol.err = ol.flush(true)
+	}
+}
+
+// fail keeps the error of a record which could not be encoded.
+func (ol *opLog) fail(err error) {
+	ol.Lock()
+	defer ol.Unlock()
+	if ol.err == nil {
+		ol.err = err
+	}
+}
+
+// flush hands the buffered records to the file, and syncs the file to disk
+// with fsync. The caller holds the log lock.
+func (ol *opLog) flush(fsync bool) error {
+	if err := ol.out.Flush(); err != nil {
+		return err
+	}
+	if fsync {
+		return ol.file.Sync()
+	}
+	return nil
+}
+
+// sync flushes the log and syncs it to disk.
+// return values :
+//		error: the first error of the log else nil
+func (ol *opLog) sync() error {
+	ol.Lock()
+	defer ol.Unlock()
+	if ol.closed || ol.err != nil {
+		return ol.err
+	}
+	ol.err = ol.flush(true)
+	return ol.err
+}
+
+// tick is called every second, it flushes the log as told by its fsync
+// policy and tells if the log has grown enough to be compacted.
+func (ol *opLog) tick() bool {
+	ol.Lock()
+	defer ol.Unlock()
+	if ol.closed || ol.err != nil {
+		return false
+	}
+	ol.err = ol.flush(ol.fsync == FsyncEverySecond)
+	return !ol.rewriting && ol.size >= ol.rewriteSize && ol.size >= 2*ol.baseSize
+}
+
+// beginRewrite starts buffering the records for a compaction, it returns
+// false when a compaction is already running or the log has failed.
+func (ol *opLog) beginRewrite() bool {
+	ol.Lock()
+	defer ol.Unlock()
+	if ol.closed || ol.err != nil || ol.rewriting {
+		return false
+	}
+	ol.rewriting = true
+	ol.rewriteBuffer = nil
+	return true
+}
+
+// abortRewrite stops the compaction and drops its buffer.
+func (ol *opLog) abortRewrite() {
+	ol.Lock()
+	defer ol.Unlock()
+	ol.rewriting = false
+	ol.rewriteBuffer = nil
+}
+
+// finishRewrite appends the records buffered during the compaction to the
+// rewritten log of the given size, then renames it over the log and keeps
+// appending to it. The rewritten log is removed when it can not replace
+// the log. Once renamed the directory is synced, so the log read back after
+// a crash is the one taking the records ; the log fails when it can not be.
+func (ol *opLog) finishRewrite(file *os.File, out *bufio.Writer, size int64) error {
+	ol.Lock()
+	defer ol.Unlock()
+	buffer := ol.rewriteBuffer
+	ol.rewriting = false
+	ol.rewriteBuffer = nil
+	err := ol.err
+	if ol.closed {
+		err = ErrClosed
+	}
+	if err == nil {
+		out.Write(buffer)
+		err = out.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), ol.path)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	// the records of the old file are all in the new one
+	ol.file.Close()
+	ol.file = file
+	ol.out = out
+	ol.size = size + int64(len(buffer))
+	ol.baseSize = ol.size
+	if err := syncDir(filepath.Dir(ol.path)); err != nil {
+		ol.err = err
+		return err
+	}
+	return nil
+}
+
+// syncDir syncs a directory to disk, with the files renamed in it.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// close flushes and syncs the log, then closes its file.
+// return values :
+//		error: the first error of the log else nil
+func (ol *opLog) close() error {
+	ol.Lock()
+	defer ol.Unlock()
+	if ol.closed {
+		return ol.err
+	}
+	ol.closed = true
+	if err := ol.flush(true); ol.err == nil {
+		ol.err = err
+	}
+	if err := ol.file.Close(); ol.err == nil {
+		ol.err = err
+	}
+	return ol.err
+}
+
+// setRecord encodes a set of the key expiring at the given time. The tags
+// of the key, if any, follow the expiry ; the logs written before tags
+// simply end there.
+func (vlruCache *VolatileLRU[K, V]) setRecord(key K, value V, size int, expireTime time.Time, tags []string) ([]byte, error) {
+	keyData, err := vlruCache.codec.EncodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	valueData, err := vlruCache.codec.EncodeValue(value)
+	if err != nil {
+		return nil, err
+	}
+	payload := appendOpField([]byte{opSet}, keyData)
+	payload = appendOpField(payload, valueData)
+	payload = binary.AppendUvarint(payload, uint64(size))
+	payload = binary.AppendVarint(payload, expireTime.UnixNano())
+	if len(tags) > 0 {
+		payload = binary.AppendUvarint(payload, uint64(len(tags)))
+		for _, tag := range tags {
+			payload = appendOpField(payload, []byte(tag))
+		}
+	}
+	return frameOpRecord(payload), nil
+}
+
+// logSet appends a set to the op log of the cache, if it has one.
+func (vlruCache *VolatileLRU[K, V]) logSet(key K, value V, size int, expireTime time.Time, tags []string) {
+	if vlruCache.oplog == nil {
+		return
+	}
+	record, err := vlruCache.setRecord(key, value, size, expireTime, tags)
+	if err != nil {
+		vlruCache.oplog.fail(err)
+		return
+	}
+	vlruCache.oplog.append(record)
+}
+
+// logDelete appends a delete to the op log of the cache, if it has one.
+func (vlruCache *VolatileLRU[K, V]) logDelete(key K) {
+	if vlruCache.oplog == nil {
+		return
+	}
+	keyData, err := vlruCache.codec.EncodeKey(key)
+	if err != nil {
+		vlruCache.oplog.fail(err)
+		return
+	}
+	vlruCache.oplog.append(frameOpRecord(appendOpField([]byte{opDelete}, keyData)))
+}
+
+// logClear appends a clear to the op log of the cache, if it has one.
+func (vlruCache *VolatileLRU[K, V]) logClear() {
+	if vlruCache.oplog == nil {
+		return
+	}
+	vlruCache.oplog.append(frameOpRecord([]byte{opClear}))
+}
+
+// logExpire appends a ttl change to the op log of the cache, if it has one.
+func (vlruCache *VolatileLRU[K, V]) logExpire(key K, expireTime time.Time) {
+	if vlruCache.oplog == nil {
+		return
+	}
+	keyData, err := vlruCache.codec.EncodeKey(key)
+	if err != nil {
+		vlruCache.oplog.fail(err)
+		return
+	}
+	payload := appendOpField([]byte{opExpire}, keyData)
+	vlruCache.oplog.append(frameOpRecord(binary.AppendVarint(payload, expireTime.UnixNano())))
+}
+
+// replay applies an operation read from the op log, the log is not
+// attached to the cache yet so nothing is logged again.
+func (vlruCache *VolatileLRU[K, V]) replay(payload []byte) error {
+	in := bytes.NewReader(payload)
+	op, err := in.ReadByte()
+	if err != nil {
+		return CorruptOpLogError
+	}
+	if op == opClear {
+		vlruCache.clear()
+		return nil
+	}
+	keyData, err := readOpField(in)
+	if err != nil {
+		return err
+	}
+	key, err := vlruCache.codec.DecodeKey(keyData)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case opDelete:
+		vlruCache.delete(key)
+	case opExpire:
+		expireTime, err := binary.ReadVarint(in)
+		if err != nil {
+			return CorruptOpLogError
+		}
+		vlruCache.expire(key, time.Unix(0, expireTime))
+	case opSet:
+		valueData, err := readOpField(in)
+		if err != nil {
+			return err
+		}
+		size, err := binary.ReadUvarint(in)
+		if err != nil {
+			return CorruptOpLogError
+		}
+		expireTime, err := binary.ReadVarint(in)
+		if err != nil {
+			return CorruptOpLogError
+		}
+		var tags []string
+		if in.Len() > 0 {
+			if tags, err = readOpTags(in); err != nil {
+				return err
+			}
+		}
+		value, err := vlruCache.codec.DecodeValue(valueData)
+		if err != nil {
+			return err
+		}
+		if time.Unix(0, expireTime).Before(time.Now()) {
+			// the value set may replace a live one
+			vlruCache.delete(key)
+			return nil
+		}
+		vlruCache.store(key, value, int(size), time.Unix(0, expireTime), WithTags(tags...))
+	default:
+		return CorruptOpLogError
+	}
+	return nil
+}
+
+// appendOpField appends a length prefixed key or value to an operation.
+func appendOpField(payload []byte, field []byte) []byte {
+	return append(binary.AppendUvarint(payload, uint64(len(field))), field...)
+}
+
+// readOpField reads a length prefixed key or value of an operation.
+func readOpField(in *bytes.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(in)
+	if err != nil || length > uint64(in.Len()) {
+		return nil, CorruptOpLogError
+	}
+	field := make([]byte, length)
+	in.Read(field)
+	return field, nil
+}
+
+// readOpTags reads the count prefixed tags of a set.
+func readOpTags(in *bytes.Reader) ([]string, error) {
+	count, err := binary.ReadUvarint(in)
+	if err != nil || count > uint64(in.Len()) {
+		return nil, CorruptOpLogError
+	}
+	tags := make([]string, count)
+	for i := range tags {
+		tag, err := readOpField(in)
+		if err != nil {
+			return nil, err
+		}
+		tags[i] = string(tag)
+	}
+	return tags, nil
+}
+
+// CompactOpLog rewrites the op log given by WithOpLog from the live keys of
+// the cache, dropping the operations they make useless. The cache keeps
+// running meanwhile ; its operations go to the old log and are appended to
+// the new one before it replaces the old one. The log is also compacted in
+// the background once it doubles in size. Without op log it does nothing.
+// return values :
+//		error: the error of the file system or of the codec, ErrClosed
+//			   after Close else nil
+func (vlruCache *VolatileLRU[K, V]) CompactOpLog() error {
+	if vlruCache.oplog == nil {
+		return nil
+	}
+	if vlruCache.isClosed() {
+		return ErrClosed
+	}
+	vlruCache.RLocker().Lock()
+	entries := vlruCache.liveEntries()
+	started := vlruCache.oplog.beginRewrite()
+	vlruCache.RLocker().Unlock()
+	if !started {
+		// a compaction is running, or the log failed and SyncOpLog tells why
+		return nil
+	}
+
+	path := vlruCache.oplog.path
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".rewrite*")
+	if err != nil {
+		vlruCache.oplog.abortRewrite()
+		return err
+	}
+	out := bufio.NewWriter(file)
+	size, _ := out.Write(opLogHeader())
+	for _, entry := range entries {
+		var record []byte
+		if record, err = vlruCache.setRecord(entry.key, entry.value, entry.size, entry.expireTime, entry.tags); err != nil {
+			break
+		}
+		out.Write(record)
+		size = size + len(record)
+	}
+	if err != nil {
+		vlruCache.oplog.abortRewrite()
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	return vlruCache.oplog.finishRewrite(file, out, int64(size))
+}
+
+// SyncOpLog flushes the op log given by WithOpLog and syncs it to disk,
+// whatever its fsync policy. Without op log it does nothing.
+// return values :
+//		error: the first error met by the log, after which it takes no
+//			   more operation, else nil
+func (vlruCache *VolatileLRU[K, V]) SyncOpLog() error {
+	if vlruCache.oplog == nil {
+		return nil
+	}
+	return vlruCache.oplog.sync()
+}
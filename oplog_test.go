@@ -0,0 +1,204 @@
+package spectre
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpLogReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	cache, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncAlways))
+	if err != nil {
+		t.Fatalf("new failed %v", err)
+	}
+	cache.VolatileLRUCacheSet("cleared", "cleared", 5, time.Duration(0))
+	cache.VolatileLRUCacheClear()
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("spectre", "spectre", 7, time.Duration(0))
+	cache.VolatileLRUCacheSet("vivek", "vivek2", 6, time.Duration(0))
+	cache.VolatileLRUCacheDelete("ibibo")
+	cache.Expire("spectre", time.Minute)
+	cache.VolatileLRUCacheSet("expired", "expired", 1, time.Millisecond)
+	cache.RLocker().Lock()
+	expireTime := cache.linkMap["spectre"].ExpireTime
+	cache.RLocker().Unlock()
+	// no Close, the log is synced on every operation
+	time.Sleep(2 * time.Millisecond)
+
+	replayed, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncAlways))
+	if err != nil {
+		t.Fatalf("replay failed %v", err)
+	}
+	defer replayed.Close(context.Background())
+	for key, expected := range map[string]interface{}{"vivek": "vivek2", "spectre": "spectre", "ibibo": nil, "cleared": nil, "expired": nil} {
+		value, ok := replayed.VolatileLRUCacheGet(key)
+		if ok != (expected != nil) || (ok && value != expected) {
+			t.Fatalf("expected %v for %v got %v %v", expected, key, value, ok)
+		}
+	}
+	replayed.RLocker().Lock()
+	replayedExpireTime := replayed.linkMap["spectre"].ExpireTime
+	replayed.RLocker().Unlock()
+	if !replayedExpireTime.Equal(expireTime) {
+		t.Fatalf("expected expire time %v got %v", expireTime, replayedExpireTime)
+	}
+	if replayed.VolatileLRUCacheCurrentSize() != 13 {
+		t.Fatalf("expected size 13 got %v", replayed.VolatileLRUCacheCurrentSize())
+	}
+	if stats := replayed.Stats(); stats.Sets != 0 {
+		t.Fatalf("replay was counted in the stats %+v", stats)
+	}
+	cache.Close(context.Background())
+}
+
+func TestOpLogTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncNever))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.Close(context.Background())
+	info, _ := os.Stat(path)
+
+	// a crash in the middle of a write
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.Write([]byte{40, 1, 2})
+	file.Close()
+	replayed, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncNever))
+	if err != nil {
+		t.Fatalf("replay failed %v", err)
+	}
+	if _, ok := replayed.VolatileLRUCacheGet("vivek"); !ok {
+		t.Fatalf("key before the torn record was lost")
+	}
+	if truncated, _ := os.Stat(path); truncated.Size() != info.Size() {
+		t.Fatalf("torn record was not cut off, expected %v bytes got %v", info.Size(), truncated.Size())
+	}
+	replayed.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	replayed.Close(context.Background())
+
+	again, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncNever))
+	defer again.Close(context.Background())
+	if _, ok := again.VolatileLRUCacheGet("ibibo"); !ok {
+		t.Fatalf("key after the cut was lost")
+	}
+}
+
+func TestOpLogCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncNever))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("spectre", "spectre", 7, time.Duration(0))
+	cache.Close(context.Background())
+
+	// a byte of the payload of the first record, after the header, the
+	// length and the checksum
+	data, _ := os.ReadFile(path)
+	data[len(opLogHeader())+1+4+2] ^= 0xff
+	os.WriteFile(path, data, 0644)
+	_, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncNever))
+	if !errors.Is(err, CorruptOpLogError) || !strings.Contains(err.Error(), "3 records skipped") {
+		t.Fatalf("expected CorruptOpLogError for 3 records got %v", err)
+	}
+	if kept, _ := os.ReadFile(path); !bytes.Equal(kept, data) {
+		t.Fatalf("log with a corrupt record was changed")
+	}
+}
+
+func TestOpLogEvictions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	cache, _ := New(WithMaxSize(10), WithTTL(time.Hour), WithOpLog(path, FsyncAlways))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("spectre", "spect", 5, time.Duration(0))
+	cache.Close(context.Background())
+
+	// a bigger cache would keep the evicted key without its delete
+	replayed, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncAlways))
+	if err != nil {
+		t.Fatalf("replay failed %v", err)
+	}
+	defer replayed.Close(context.Background())
+	if _, ok := replayed.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("evicted key came back from the log")
+	}
+	if replayed.VolatileLRUCacheCurrentSize() != 10 {
+		t.Fatalf("expected size 10 got %v", replayed.VolatileLRUCacheCurrentSize())
+	}
+}
+
+func TestOpLogCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	cache, _ := New(WithMaxSize(1000), WithTTL(time.Hour), WithOpLog(path, FsyncEverySecond))
+	for i := 0; i < 100; i++ {
+		cache.VolatileLRUCacheSet("vivek", strconv.Itoa(i), 5, time.Duration(0))
+	}
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	cache.SyncOpLog()
+	before, _ := os.Stat(path)
+	if err := cache.CompactOpLog(); err != nil {
+		t.Fatalf("compaction failed %v", err)
+	}
+	cache.VolatileLRUCacheSet("spectre", "spectre", 7, time.Duration(0))
+	cache.SyncOpLog()
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size()/10 {
+		t.Fatalf("log was not compacted, %v bytes before and %v after", before.Size(), after.Size())
+	}
+	cache.Close(context.Background())
+	if matches, _ := filepath.Glob(path + ".rewrite*"); len(matches) != 0 {
+		t.Fatalf("temporary files left %v", matches)
+	}
+
+	replayed, _ := New(WithMaxSize(1000), WithTTL(time.Hour), WithOpLog(path, FsyncEverySecond))
+	defer replayed.Close(context.Background())
+	for key, expected := range map[string]string{"vivek": "99", "ibibo": "ibibo", "spectre": "spectre"} {
+		if value, ok := replayed.VolatileLRUCacheGet(key); !ok || value != expected {
+			t.Fatalf("expected %v for %v got %v", expected, key, value)
+		}
+	}
+}
+
+func TestOpLogBackgroundCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	cache, _ := New(WithMaxSize(1000), WithTTL(time.Hour), WithOpLog(path, FsyncEverySecond), WithOpLogRewriteSize(1024))
+	defer cache.Close(context.Background())
+	for i := 0; i < 200; i++ {
+		cache.VolatileLRUCacheSet("vivek", strconv.Itoa(i), 5, time.Duration(0))
+	}
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if info, _ := os.Stat(path); info.Size() < 1024 {
+			return
+		}
+	}
+	t.Fatalf("log was not compacted in the background")
+}
+
+func TestOpLogErrors(t *testing.T) {
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog("", FsyncAlways)); err != InvalidOpLogError {
+		t.Fatalf("expected InvalidOpLogError got %v", err)
+	}
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog("spectre.log", FsyncPolicy(7))); err != InvalidOpLogError {
+		t.Fatalf("expected InvalidOpLogError got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	os.WriteFile(path, []byte("not an op log"), 0644)
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncAlways)); err != CorruptOpLogError {
+		t.Fatalf("expected CorruptOpLogError got %v", err)
+	}
+}
+
+func BenchmarkOpLogSet(b *testing.B) {
+	cache, _ := New(WithMaxSize(1<<20), WithTTL(time.Hour), WithOpLog(filepath.Join(b.TempDir(), "spectre.log"), FsyncEverySecond))
+	defer cache.Close(context.Background())
+	for i := 0; i < b.N; i++ {
+		cache.VolatileLRUCacheSet(strconv.Itoa(i%1000), "vivek", 5, time.Duration(0))
+	}
+}
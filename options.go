@@ -0,0 +1,463 @@
+package spectre
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultPartitions is the number of internal maps used when no
+	// WithPartitions option is given.
+	DefaultPartitions = 32
+	// DefaultAsyncQueueDepth is the number of distinct keys SetAsync can
+	// keep waiting when no WithAsyncQueue option is given.
+	DefaultAsyncQueueDepth = 1024
+	// DefaultOpLogRewriteSize is the size in bytes an op log must reach
+	// before it is compacted when no WithOpLogRewriteSize option is given.
+	DefaultOpLogRewriteSize = 64 << 20
+)
+
+// configError is the error which is thrown when a constructor receives
+// an option value it can not work with.
+type configError struct {
+	errorNumber int
+	problem     string
+}
+
+func (ce *configError) Error() string {
+	return fmt.Sprintf("%d---%s", ce.errorNumber, ce.problem)
+}
+
+var (
+	// InvalidSizeError returns when the max size of a cache is not positive
+	InvalidSizeError = &configError{problem: "max size must be greater than zero", errorNumber: 2}
+	// InvalidPartitionsError returns when the partition count of a cache is not positive
+	InvalidPartitionsError = &configError{problem: "partitions must be greater than zero", errorNumber: 3}
+	// InvalidTTLError returns when the global ttl of a cache is not positive
+	InvalidTTLError = &configError{problem: "ttl must be greater than zero", errorNumber: 4}
+	// InvalidHashError returns when a nil hash function is given
+	InvalidHashError = &configError{problem: "hash function must not be nil", errorNumber: 5}
+	// InvalidPolicyError returns when a nil eviction policy factory is given
+	InvalidPolicyError = &configError{problem: "eviction policy must not be nil", errorNumber: 9}
+	// InvalidJanitorError returns when the janitor interval or its work per
+	// tick is not positive
+	InvalidJanitorError = &configError{problem: "janitor interval and max keys per tick must be greater than zero", errorNumber: 10}
+	// InvalidQueueError returns when the async queue depth is not positive
+	// or its policy is unknown
+	InvalidQueueError = &configError{problem: "async queue depth must be greater than zero with a known policy", errorNumber: 6}
+	// KeyTypeError returns when a hasher, an eviction policy or a loader given
+	// as an option is made for another key or value type than the ones of the cache
+	KeyTypeError = &configError{problem: "option type does not match the cache key or value type", errorNumber: 12}
+	// InvalidLoaderError returns when a nil loader is given
+	InvalidLoaderError = &configError{problem: "loader must not be nil", errorNumber: 14}
+	// InvalidRefreshError returns when the refresh duration or the stale
+	// window is not positive
+	InvalidRefreshError = &configError{problem: "refresh after and stale for must be greater than zero", errorNumber: 15}
+	// InvalidSnapshotError returns when a nil snapshot codec, an empty
+	// snapshot file or a snapshot interval which is not positive is given
+	InvalidSnapshotError = &configError{problem: "snapshot codec, file and interval must be given", errorNumber: 18}
+	// InvalidOpLogError returns when the op log file is empty, its fsync
+	// policy unknown or its rewrite size not positive
+	InvalidOpLogError = &configError{problem: "op log file, fsync policy and rewrite size must be valid", errorNumber: 22}
+	// InvalidTransportError returns when a nil invalidation transport is given
+	InvalidTransportError = &configError{problem: "invalidation transport must not be nil", errorNumber: 28}
+)
+
+// config keeps the settings collected from the options given to a
+// constructor. The options are shared by every key type, so the hasher and
+// the policy factory are kept untyped and checked against the key type of
+// the cache when it is built.
+type config struct {
+	maxSize    int
+	partitions int
+	ttl        time.Duration
+	hasher     interface{} // Hasher[K], nil for the maphash default
+	queueDepth int
+	queueFull  QueueFullPolicy
+	policy     interface{} // PolicyFactory[K], nil for the cache default
+
+	janitorInterval time.Duration
+	janitorLimit    int
+
+	heapAccounting bool
+	loader         interface{} // Loader[K, V], nil without read-through
+	refreshAfter   time.Duration
+	staleFor       time.Duration
+
+	snapshotCodec    interface{} // Codec[K, V], nil for GobCodec
+	snapshotFile     string
+	snapshotInterval time.Duration
+
+	opLogFile        string
+	opLogFsync       FsyncPolicy
+	opLogRewriteSize int64
+
+	invalidation InvalidationTransport
+}
+
+// defaultConfig returns the settings used for everything not given as an option.
+func defaultConfig() *config {
+	return &config{
+		partitions: DefaultPartitions,
+		queueDepth: DefaultAsyncQueueDepth,
+		queueFull:  QueueBlock,
+
+		opLogRewriteSize: DefaultOpLogRewriteSize,
+	}
+}
+
+// Option configures a cache built by New, NewCache, NewTypedCache or
+// NewVolatileLRU.
+type Option func(*config) error
+
+// WithMaxSize sets the maximum size of the cache in bytes.
+func WithMaxSize(size int) Option {
+	return func(cfg *config) error {
+		if size <= 0 {
+			return InvalidSizeError
+		}
+		cfg.maxSize = size
+		return nil
+	}
+}
+
+// WithPartitions sets the number of internal maps participating in the cache.
+func WithPartitions(partitions int) Option {
+	return func(cfg *config) error {
+		if partitions <= 0 {
+			return InvalidPartitionsError
+		}
+		cfg.partitions = partitions
+		return nil
+	}
+}
+
+// WithTTL sets the global time to live of the keys. Unlike the ttl argument
+// of GetVolatileLRUCache the duration is used as it is.
+func WithTTL(ttl time.Duration) Option {
+	return func(cfg *config) error {
+		if ttl <= 0 {
+			return InvalidTTLError
+		}
+		cfg.ttl = ttl
+		return nil
+	}
+}
+
+// WithHashFunc sets the function used to pick the partition of a string
+// key, see WithHasher for the other key types.
+func WithHashFunc(hash HashFunc) Option {
+	return func(cfg *config) error {
+		if hash == nil {
+			return InvalidHashError
+		}
+		cfg.hasher = Hasher[string](func(key string) uint64 {
+			return uint64(hash(key))
+		})
+		return nil
+	}
+}
+
+// WithHasher sets the function used to pick the partition of a key. The
+// cache fails with KeyTypeError when K is not its key type.
+func WithHasher[K comparable](hasher Hasher[K]) Option {
+	return func(cfg *config) error {
+		if hasher == nil {
+			return InvalidHashError
+		}
+		cfg.hasher = hasher
+		return nil
+	}
+}
+
+// WithEvictionPolicy sets the policy choosing the keys to evict when space
+// is not available. The factory is called once with the max size of the
+// cache so every cache gets its own policy instance. The cache fails with
+// KeyTypeError when K is not its key type.
+func WithEvictionPolicy[K comparable](factory PolicyFactory[K]) Option {
+	return func(cfg *config) error {
+		if factory == nil {
+			return InvalidPolicyError
+		}
+		cfg.policy = factory
+		return nil
+	}
+}
+
+// WithAsyncQueue bounds the number of distinct keys SetAsync keeps waiting
+// to be written and chooses what happens when the queue is full.
+func WithAsyncQueue(depth int, policy QueueFullPolicy) Option {
+	return func(cfg *config) error {
+		if depth <= 0 || policy < QueueBlock || policy > QueueError {
+			return InvalidQueueError
+		}
+		cfg.queueDepth = depth
+		cfg.queueFull = policy
+		return nil
+	}
+}
+
+// WithJanitor starts a background goroutine removing the expired keys every
+// interval, at most maxPerTick keys per run so a tick never holds the cache
+// for long. Without it expired keys are only removed on Set and Delete.
+// The janitor is stopped by Close.
+func WithJanitor(interval time.Duration, maxPerTick int) Option {
+	return func(cfg *config) error {
+		if interval <= 0 || maxPerTick <= 0 {
+			return InvalidJanitorError
+		}
+		cfg.janitorInterval = interval
+		cfg.janitorLimit = maxPerTick
+		return nil
+	}
+}
+
+// WithHeapAccounting charges every key with the estimated size of the key
+// and EntryOverhead on top of the size of its value, so MaxSize bounds the
+// memory really used by the cache rather than the values alone.
+func WithHeapAccounting() Option {
+	return func(cfg *config) error {
+		cfg.heapAccounting = true
+		return nil
+	}
+}
+
+// WithLoader sets the Loader used by GetOrLoad when the call does not give
+// its own. The cache fails with KeyTypeError when K and V are not its key
+// and value types.
+func WithLoader[K comparable, V any](loader Loader[K, V]) Option {
+	return func(cfg *config) error {
+		if loader == nil {
+			return InvalidLoaderError
+		}
+		cfg.loader = loader
+		return nil
+	}
+}
+
+// WithRefreshAfter makes the reads of a key set more than refreshAfter ago
+// reload it in the background with the loader given by WithLoader, while
+// the cached value keeps being returned. It should be shorter than the ttl
+// of the keys to refresh them before they expire.
+func WithRefreshAfter(refreshAfter time.Duration) Option {
+	return func(cfg *config) error {
+		if refreshAfter <= 0 {
+			return InvalidRefreshError
+		}
+		cfg.refreshAfter = refreshAfter
+		return nil
+	}
+}
+
+// WithStaleFor keeps the expired keys for a grace window, during which reads
+// still return the expired value and reload it in the background with the
+// loader given by WithLoader.
+func WithStaleFor(staleFor time.Duration) Option {
+	return func(cfg *config) error {
+		if staleFor <= 0 {
+			return InvalidRefreshError
+		}
+		cfg.staleFor = staleFor
+		return nil
+	}
+}
+
+// WithSnapshotCodec sets the Codec writing the keys and values of the
+// snapshots, GobCodec by default. The cache fails with KeyTypeError when K
+// and V are not its key and value types.
+func WithSnapshotCodec[K comparable, V any](codec Codec[K, V]) Option {
+	return func(cfg *config) error {
+		if codec == nil {
+			return InvalidSnapshotError
+		}
+		cfg.snapshotCodec = codec
+		return nil
+	}
+}
+
+// WithSnapshotFile saves a snapshot of the cache to the file every interval
+// and once more on Close, see SaveSnapshotFile. Load it back with
+// LoadSnapshotFile when the cache starts.
+func WithSnapshotFile(path string, interval time.Duration) Option {
+	return func(cfg *config) error {
+		if path == "" || interval <= 0 {
+			return InvalidSnapshotError
+		}
+		cfg.snapshotFile = path
+		cfg.snapshotInterval = interval
+		return nil
+	}
+}
+
+// WithOpLog appends every Set, Delete, Clear and Expire of the cache to the
+// log file at path, synced to disk as told by the fsync policy ; the evicted
+// keys are logged as deletes and the expired ones need no record. The log is
+// replayed when the cache is built and compacted in the background once it
+// doubles in size since its last compaction, see WithOpLogRewriteSize. A
+// record torn by a crash at the end of the log is cut off, a corrupt record
+// followed by others fails the build of the cache and the log is left as
+// it is.
+func WithOpLog(path string, fsync FsyncPolicy) Option {
+	return func(cfg *config) error {
+		if path == "" || fsync < FsyncAlways || fsync > FsyncNever {
+			return InvalidOpLogError
+		}
+		cfg.opLogFile = path
+		cfg.opLogFsync = fsync
+		return nil
+	}
+}
+
+// WithOpLogRewriteSize sets the size in bytes under which the op log is
+// never compacted, DefaultOpLogRewriteSize by default.
+func WithOpLogRewriteSize(size int64) Option {
+	return func(cfg *config) error {
+		if size <= 0 {
+			return InvalidOpLogError
+		}
+		cfg.opLogRewriteSize = size
+		return nil
+	}
+}
+
+// WithInvalidation shares the changes of the cache with the other caches of
+// the transport, in this process or others : every Set, SetAsync, Update,
+// Delete and Clear publishes an invalidation once applied, and the keys of
+// the invalidations received from the other caches are deleted, so a key
+// changed on one replica is not served stale by the others. The values
+// loaded by GetOrLoad are not published. Keys are sent written by the
+// Codec of WithSnapshotCodec, every cache of a transport must use the same.
+func WithInvalidation(transport InvalidationTransport) Option {
+	return func(cfg *config) error {
+		if transport == nil {
+			return InvalidTransportError
+		}
+		cfg.invalidation = transport
+		return nil
+	}
+}
+
+// newConfig applies the options over the defaults and validates the result.
+func newConfig(opts []Option) (*config, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.maxSize <= 0 {
+		return nil, InvalidSizeError
+	}
+	return cfg, nil
+}
+
+// setCondition tells when a set applies, whatever the presence of the key
+// by default.
+type setCondition int
+
+const (
+	setAlways setCondition = iota
+	setIfAbsent
+	setIfPresent
+)
+
+// setConfig keeps the settings collected from the options given to a set.
+type setConfig struct {
+	cost      float64
+	condition setCondition
+	tags      []string
+	namespace interface{} // *namespace[K], set by Namespace Set
+}
+
+// SetOption configures a single set of a key.
+type SetOption func(*setConfig)
+
+// WithCost sets the cost of recomputing the value, used by cost aware
+// eviction policies like GDSF. The default cost of a key is 1.
+func WithCost(cost float64) SetOption {
+	return func(sc *setConfig) {
+		sc.cost = cost
+	}
+}
+
+// WithTags attaches tags to the key, like the rows a rendered fragment was
+// built from ; InvalidateTag removes every key of a tag at once. A key has
+// the tags of its last set only.
+func WithTags(tags ...string) SetOption {
+	return func(sc *setConfig) {
+		sc.tags = append(sc.tags, tags...)
+	}
+}
+
+// inNamespace makes the key of the set belong to the namespace.
+func inNamespace[K comparable](ns *namespace[K]) SetOption {
+	return func(sc *setConfig) {
+		sc.namespace = ns
+	}
+}
+
+// IfAbsent makes the set apply only when the key is not in the cache ; it
+// returns false with a nil error otherwise. An expired key is absent.
+func IfAbsent() SetOption {
+	return func(sc *setConfig) {
+		sc.condition = setIfAbsent
+	}
+}
+
+// IfPresent makes the set apply only when the key is already in the cache ;
+// it returns false with a nil error otherwise.
+func IfPresent() SetOption {
+	return func(sc *setConfig) {
+		sc.condition = setIfPresent
+	}
+}
+
+// allows tells if the condition of the set lets it apply to a key present
+// or not.
+func (sc *setConfig) allows(present bool) bool {
+	switch sc.condition {
+	case setIfAbsent:
+		return !present
+	case setIfPresent:
+		return present
+	}
+	return true
+}
+
+// newSetConfig applies the set options over the defaults.
+func newSetConfig(opts []SetOption) *setConfig {
+	sc := &setConfig{cost: 1}
+	for _, opt := range opts {
+		opt(sc)
+	}
+	return sc
+}
+
+// namespaceConfig keeps the settings collected from the options given to
+// Namespace.
+type namespaceConfig struct {
+	minSize int
+	maxSize int
+	ttl     time.Duration
+}
+
+// NamespaceOption configures a Namespace.
+type NamespaceOption func(*namespaceConfig)
+
+// WithQuota gives the namespace the bytes its keys are never evicted under
+// by the writes of the other namespaces, and the bytes they never grow
+// over ; the namespace evicts its own keys to stay under maxSize.
+func WithQuota(minSize int, maxSize int) NamespaceOption {
+	return func(nc *namespaceConfig) {
+		nc.minSize = minSize
+		nc.maxSize = maxSize
+	}
+}
+
+// WithDefaultTTL gives the ttl of the keys set in the namespace with a
+// keyExpire of 0, in place of the global ttl of the cache.
+func WithDefaultTTL(ttl time.Duration) NamespaceOption {
+	return func(nc *namespaceConfig) {
+		nc.ttl = ttl
+	}
+}
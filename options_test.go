@@ -0,0 +1,96 @@
+package spectre
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestNewCache(t *testing.T) {
+	cache, err := NewCache(WithMaxSize(5000), WithPartitions(7))
+	if err != nil {
+		t.Fatalf("could not build cache %v", err)
+	}
+	if len(cache.Data.MapList) != 7 {
+		t.Fatalf("expected 7 partitions got %v", len(cache.Data.MapList))
+	}
+	cache, err = NewCache(WithMaxSize(5000))
+	if err != nil {
+		t.Fatalf("could not build cache %v", err)
+	}
+	if len(cache.Data.MapList) != DefaultPartitions {
+		t.Fatalf("expected %v partitions got %v", DefaultPartitions, len(cache.Data.MapList))
+	}
+}
+
+func TestNewCacheInvalidConfig(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		err  error
+	}{
+		{nil, InvalidSizeError},
+		{[]Option{WithMaxSize(0)}, InvalidSizeError},
+		{[]Option{WithMaxSize(10), WithPartitions(0)}, InvalidPartitionsError},
+		{[]Option{WithMaxSize(10), WithHashFunc(nil)}, InvalidHashError},
+	}
+	for _, test := range tests {
+		if _, err := NewCache(test.opts...); err != test.err {
+			t.Fatalf("expected %v got %v", test.err, err)
+		}
+	}
+}
+
+func TestNewInvalidTTL(t *testing.T) {
+	if _, err := New(WithMaxSize(10)); err != InvalidTTLError {
+		t.Fatalf("expected %v got %v", InvalidTTLError, err)
+	}
+	if _, err := New(WithMaxSize(10), WithTTL(-time.Second)); err != InvalidTTLError {
+		t.Fatalf("expected %v got %v", InvalidTTLError, err)
+	}
+	if _, err := New(WithMaxSize(10), WithTTL(time.Second)); err != nil {
+		t.Fatalf("could not build cache %v", err)
+	}
+}
+
+func TestIndependentPartitions(t *testing.T) {
+	first := GetDefaultCache(5000, 2)
+	first.CacheSet("vivek", "vivek", binary.Size([]byte("vivek")))
+	// a second cache with more partitions must not re-shard the first one
+	second := GetDefaultCache(5000, 64)
+	second.CacheSet("ibibo", "ibibo", binary.Size([]byte("ibibo")))
+	if val, ok := first.CacheGet("vivek"); !ok || val != "vivek" {
+		t.Fatalf("first cache lost its key after second cache creation")
+	}
+	if val, ok := second.CacheGet("ibibo"); !ok || val != "ibibo" {
+		t.Fatalf("second cache lost its key")
+	}
+}
+
+func TestWithHashFunc(t *testing.T) {
+	cache, _ := NewCache(WithMaxSize(5000), WithPartitions(4), WithHashFunc(func(key string) uint32 {
+		return 3
+	}))
+	cache.CacheSet("vivek", "vivek", binary.Size([]byte("vivek")))
+	if _, ok := cache.Data.MapList[3].Items["vivek"]; !ok {
+		t.Fatalf("custom hash function was not used to pick the partition")
+	}
+}
+
+func TestWithHasher(t *testing.T) {
+	cache, _ := NewTypedCache[int, int](WithMaxSize(100), WithPartitions(4), WithHasher(func(key int) uint64 {
+		return uint64(key)
+	}))
+	cache.Set(6, 6, 1)
+	if _, ok := cache.Data.MapList[2].Items[6]; !ok {
+		t.Fatalf("custom hasher was not used to pick the partition")
+	}
+}
+
+func TestKeyTypeError(t *testing.T) {
+	if _, err := NewTypedCache[int, int](WithMaxSize(100), WithHashFunc(func(key string) uint32 { return 0 })); err != KeyTypeError {
+		t.Fatalf("expected KeyTypeError for a string hasher got %v", err)
+	}
+	if _, err := NewVolatileLRU[int, int](WithMaxSize(100), WithTTL(time.Hour), WithEvictionPolicy(NewFIFOPolicy[string])); err != KeyTypeError {
+		t.Fatalf("expected KeyTypeError for a string policy got %v", err)
+	}
+}
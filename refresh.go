@@ -0,0 +1,29 @@
+package spectre
+
+import (
+	"context"
+	"time"
+)
+
+// needsRefresh tells if the key is older than the refresh duration of its
+// cache.
+func (l *Link[K]) needsRefresh() bool {
+	return !l.refreshTime.IsZero() && l.refreshTime.Before(time.Now())
+}
+
+// isLinkDead tells if the key is expired and out of the stale window, so it
+// can not be returned anymore.
+func (vlruCache *VolatileLRU[K, V]) isLinkDead(link *Link[K]) bool {
+	return link.ExpireTime.Add(vlruCache.staleFor).Before(time.Now())
+}
+
+// refresh reloads the key in the background with the loader of the cache.
+// A load of the key already in flight, from GetOrLoad or an earlier
+// refresh, is not started again ; a failed reload leaves the cached value
+// as it is.
+func (vlruCache *VolatileLRU[K, V]) refresh(key K) {
+	if vlruCache.loader == nil {
+		return
+	}
+	vlruCache.load(context.Background(), key, vlruCache.loader)
+}
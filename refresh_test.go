@@ -0,0 +1,81 @@
+package spectre
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// versionLoader returns a loader answering the number of loads done so far.
+func versionLoader(calls *int32) Loader[string, interface{}] {
+	return func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		return key + strconv.Itoa(int(atomic.AddInt32(calls, 1))), 1, 0, nil
+	}
+}
+
+// waitForValue polls the cache until the key holds the expected value.
+func waitForValue(t *testing.T, cache *VolatileLRUCache, key string, expected interface{}) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if value, _ := cache.VolatileLRUCacheGet(key); value == expected {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("key %v never held %v", key, expected)
+}
+
+func TestRefreshAfter(t *testing.T) {
+	var calls int32
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithLoader(versionLoader(&calls)), WithRefreshAfter(5*time.Millisecond))
+	cache.GetOrLoad(context.Background(), "vivek", nil)
+	if value, _ := cache.VolatileLRUCacheGet("vivek"); value != "vivek1" {
+		t.Fatalf("expected vivek1 before the refresh got %v", value)
+	}
+	time.Sleep(10 * time.Millisecond)
+	// the read past the refresh duration still returns the cached value
+	if value, ok := cache.VolatileLRUCacheGet("vivek"); !ok || value != "vivek1" {
+		t.Fatalf("expected the cached vivek1 got %v", value)
+	}
+	waitForValue(t, cache, "vivek", "vivek2")
+	if loads := atomic.LoadInt32(&calls); loads != 2 {
+		t.Fatalf("expected a single reload got %v loads", loads)
+	}
+}
+
+func TestStaleFor(t *testing.T) {
+	var calls int32
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithLoader(versionLoader(&calls)), WithStaleFor(time.Hour))
+	cache.VolatileLRUCacheSet("vivek", "vivek0", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	// expired keys in the grace window survive the cleanup of the sets
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 1, time.Duration(0))
+	if value, ok := cache.VolatileLRUCacheGet("vivek"); !ok || value != "vivek0" {
+		t.Fatalf("expected the stale vivek0 got %v", value)
+	}
+	waitForValue(t, cache, "vivek", "vivek1")
+}
+
+func TestStaleForWindowEnd(t *testing.T) {
+	var calls int32
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithLoader(versionLoader(&calls)), WithStaleFor(time.Millisecond))
+	cache.VolatileLRUCacheSet("vivek", "vivek0", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("key returned after its stale window")
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Fatalf("dead key was reloaded")
+	}
+}
+
+func TestRefreshNeedsLoader(t *testing.T) {
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithRefreshAfter(time.Minute)); err != NoLoaderError {
+		t.Fatalf("expected NoLoaderError got %v", err)
+	}
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithStaleFor(0)); err != InvalidRefreshError {
+		t.Fatalf("expected InvalidRefreshError got %v", err)
+	}
+}
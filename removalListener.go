@@ -0,0 +1,169 @@
+package spectre
+
+import (
+	"context"
+	"sync"
+)
+
+// RemovalReason tells why a key left the cache.
+type RemovalReason int
+
+const (
+	// Expired keys outlived their time to live.
+	Expired RemovalReason = iota
+	// Evicted keys were chosen by the eviction policy to make space.
+	Evicted
+	// Deleted keys were deleted by a caller.
+	Deleted
+	// Replaced values were overwritten by a set of their key.
+	Replaced
+	// Cleared keys were removed by a clear of the whole cache.
+	Cleared
+)
+
+func (rr RemovalReason) String() string {
+	switch rr {
+	case Expired:
+		return "expired"
+	case Evicted:
+		return "evicted"
+	case Deleted:
+		return "deleted"
+	case Replaced:
+		return "replaced"
+	case Cleared:
+		return "cleared"
+	}
+	return "unknown"
+}
+
+// RemovalListener is called with every key leaving the cache, its value and
+// the reason of the removal.
+type RemovalListener[K comparable, V any] func(key K, value V, reason RemovalReason)
+
+// removal is a removal waiting to be delivered to the listeners.
+type removal[K comparable, V any] struct {
+	key    K
+	value  V
+	reason RemovalReason
+}
+
+// removalNotifier delivers the removals of a cache to its listeners from a
+// goroutine of its own, in their order. The cache only queues the removals
+// under its lock, so the listeners never run under it and may use the cache.
+type removalNotifier[K comparable, V any] struct {
+	listeners []RemovalListener[K, V]
+	queue     []removal[K, V]
+	running   bool
+	closed    bool
+	notEmpty  *sync.Cond
+	done      chan struct{}
+	sync.Mutex
+}
+
+func newRemovalNotifier[K comparable, V any]() *removalNotifier[K, V] {
+	notifier := &removalNotifier[K, V]{done: make(chan struct{})}
+	notifier.notEmpty = sync.NewCond(notifier)
+	return notifier
+}
+
+// add registers a listener. The delivery goroutine starts with the first one.
+func (rn *removalNotifier[K, V]) add(listener RemovalListener[K, V]) {
+	rn.Lock()
+	defer rn.Unlock()
+	if rn.closed {
+		return
+	}
+	rn.listeners = append(rn.listeners, listener)
+	if !rn.running {
+		rn.running = true
+		go rn.run()
+	}
+}
+
+// active tells if removals are listened to, so the cache can skip
+// collecting them.
+func (rn *removalNotifier[K, V]) active() bool {
+	rn.Lock()
+	defer rn.Unlock()
+	return len(rn.listeners) > 0 && !rn.closed
+}
+
+// notify queues a removal for the listeners, it never blocks on them.
+func (rn *removalNotifier[K, V]) notify(key K, value V, reason RemovalReason) {
+	rn.Lock()
+	defer rn.Unlock()
+	if len(rn.listeners) == 0 || rn.closed {
+		return
+	}
+	rn.queue = append(rn.queue, removal[K, V]{key: key, value: value, reason: reason})
+	rn.notEmpty.Signal()
+}
+
+// run delivers the queued removals until the notifier is closed and the
+// queue is empty.
+func (rn *removalNotifier[K, V]) run() {
+	defer close(rn.done)
+	for {
+		rn.Lock()
+		for len(rn.queue) == 0 && !rn.closed {
+			rn.notEmpty.Wait()
+		}
+		if len(rn.queue) == 0 {
+			rn.Unlock()
+			return
+		}
+		batch := rn.queue
+		rn.queue = nil
+		listeners := rn.listeners
+		rn.Unlock()
+		for _, event := range batch {
+			for _, listener := range listeners {
+				rn.deliver(listener, event)
+			}
+		}
+	}
+}
+
+// deliver calls a listener, a panicking listener does not stop the delivery
+// to the others.
+func (rn *removalNotifier[K, V]) deliver(listener RemovalListener[K, V], event removal[K, V]) {
+	//panic handlling at listener level
+	defer func() {
+		recover()
+	}()
+	listener(event.key, event.value, event.reason)
+}
+
+// close stops taking removals and waits until the queued ones are delivered,
+// or until the context is done.
+func (rn *removalNotifier[K, V]) close(ctx context.Context) error {
+	rn.Lock()
+	rn.closed = true
+	running := rn.running
+	rn.notEmpty.Broadcast()
+	rn.Unlock()
+	if !running {
+		return nil
+	}
+	select {
+	case <-rn.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnRemove registers a listener called with every key leaving the cache :
+// expired, evicted, deleted, replaced by a new value or cleared. Listeners
+// are called one at a time, in the order of the removals, from a goroutine
+// of the cache and never under its lock, so they may use the cache.
+func (c *TypedCache[K, V]) OnRemove(listener RemovalListener[K, V]) {
+	c.removals.add(listener)
+}
+
+// OnRemove registers a listener called with every key leaving the cache,
+// see TypedCache OnRemove. Close delivers the removals still queued.
+func (vlruCache *VolatileLRU[K, V]) OnRemove(listener RemovalListener[K, V]) {
+	vlruCache.cache.OnRemove(listener)
+}
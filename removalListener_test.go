@@ -0,0 +1,109 @@
+package spectre
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// removalRecorder collects the removals delivered to a listener.
+type removalRecorder struct {
+	events chan removal[string, interface{}]
+}
+
+func newRemovalRecorder() *removalRecorder {
+	return &removalRecorder{events: make(chan removal[string, interface{}], 100)}
+}
+
+func (rr *removalRecorder) listener(key string, value interface{}, reason RemovalReason) {
+	rr.events <- removal[string, interface{}]{key: key, value: value, reason: reason}
+}
+
+// expect waits for the next removal and checks it.
+func (rr *removalRecorder) expect(t *testing.T, key string, value interface{}, reason RemovalReason) {
+	select {
+	case event := <-rr.events:
+		if event.key != key || event.value != value || event.reason != reason {
+			t.Fatalf("expected removal %v %v %v got %v %v %v", key, value, reason, event.key, event.value, event.reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("removal of %v was not delivered", key)
+	}
+}
+
+func TestOnRemoveReasons(t *testing.T) {
+	cache, _ := New(WithMaxSize(10), WithTTL(time.Hour))
+	recorder := newRemovalRecorder()
+	cache.OnRemove(recorder.listener)
+
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("vivek", "vivek2", 5, time.Duration(0))
+	recorder.expect(t, "vivek", "vivek", Replaced)
+
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("spectre", "spectre", 5, time.Duration(0))
+	recorder.expect(t, "vivek", "vivek2", Evicted)
+
+	cache.VolatileLRUCacheDelete("ibibo")
+	recorder.expect(t, "ibibo", "ibibo", Deleted)
+
+	cache.VolatileLRUCacheSet("ttl", "ttl", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	cache.VolatileLRUCacheDelete("missing")
+	recorder.expect(t, "ttl", "ttl", Expired)
+
+	cache.VolatileLRUCacheClear()
+	recorder.expect(t, "spectre", "spectre", Cleared)
+}
+
+func TestOnRemoveUsesCache(t *testing.T) {
+	cache, _ := NewCache(WithMaxSize(100))
+	done := make(chan bool)
+	// a listener calling the cache must not deadlock it
+	cache.OnRemove(func(key string, value interface{}, reason RemovalReason) {
+		_, ok := cache.CacheGet(key)
+		cache.CacheSet("audit", key, 5)
+		done <- ok
+	})
+	cache.CacheSet("vivek", "vivek", 5)
+	cache.CacheDelete("vivek")
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatalf("deleted key still in the cache in its listener")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("listener was not called")
+	}
+}
+
+func TestOnRemovePanic(t *testing.T) {
+	cache, _ := NewCache(WithMaxSize(100))
+	recorder := newRemovalRecorder()
+	cache.OnRemove(func(key string, value interface{}, reason RemovalReason) {
+		panic("listener bug")
+	})
+	cache.OnRemove(recorder.listener)
+	cache.CacheSet("vivek", "vivek", 5)
+	cache.CacheDelete("vivek")
+	recorder.expect(t, "vivek", "vivek", Deleted)
+}
+
+func TestCloseDeliversRemovals(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	delivered := 0
+	cache.OnRemove(func(key string, value interface{}, reason RemovalReason) {
+		time.Sleep(time.Millisecond)
+		delivered++
+	})
+	for _, key := range []string{"vivek", "ibibo", "spectre"} {
+		cache.VolatileLRUCacheSet(key, key, 5, time.Duration(0))
+		cache.VolatileLRUCacheDelete(key)
+	}
+	if err := cache.Close(context.Background()); err != nil {
+		t.Fatalf("close failed %v", err)
+	}
+	if delivered != 3 {
+		t.Fatalf("expected 3 removals delivered on close got %v", delivered)
+	}
+}
@@ -0,0 +1,428 @@
+package resp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+// command is a command of the server. A positive arity is the exact number
+// of arguments, the name included, a negative one the minimum.
+type command struct {
+	arity int
+	run   func(s *Server, w writer, args [][]byte)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":     {-1, ping},
+		"get":      {2, get},
+		"set":      {-3, set},
+		"del":      {-2, del},
+		"exists":   {-2, exists},
+		"expire":   {3, expire},
+		"ttl":      {2, ttl},
+		"persist":  {2, persist},
+		"mget":     {-2, mget},
+		"mset":     {-3, mset},
+		"keys":     {2, keys},
+		"scan":     {-2, scan},
+		"flushall": {-1, flushall},
+		"flushdb":  {-1, flushall},
+		"dbsize":   {1, dbsize},
+		"info":     {-1, info},
+		"command":  {-1, func(s *Server, w writer, args [][]byte) { w.array(0) }},
+	}
+}
+
+const (
+	errSyntax     = "ERR syntax error"
+	errNotInteger = "ERR value is not an integer or out of range"
+)
+
+// execute runs a command and writes its reply, it tells if the connection
+// asked to be closed.
+func (s *Server) execute(w writer, args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+	if name == "quit" {
+		w.simple("OK")
+		return true
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		w.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return false
+	}
+	cmd.run(s, w, args)
+	return false
+}
+
+// valueBytes returns the bytes of a value, the items of the memcache
+// package included. The values set by the Go side of the cache which are
+// not bytes, strings nor values with a Bytes method are sent in JSON.
+func valueBytes(value interface{}) []byte {
+	switch value := value.(type) {
+	case []byte:
+		return value
+	case string:
+		return []byte(value)
+	case interface{ Bytes() []byte }:
+		return value.Bytes()
+	}
+	data, _ := json.Marshal(value)
+	return data
+}
+
+// exists tells if a key is in the cache without reading it.
+func (s *Server) exists(key string) bool {
+	_, ok := s.cache.TTL(key)
+	return ok
+}
+
+// liveKeys returns the live keys of the cache in their order.
+func (s *Server) liveKeys() []string {
+	rows := make(chan spectre.CacheRow)
+	s.cache.VolatileLRUCacheIterator(rows)
+	var keys []string
+	for row := range rows {
+		keys = append(keys, row.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func ping(s *Server, w writer, args [][]byte) {
+	switch len(args) {
+	case 1:
+		w.simple("PONG")
+	case 2:
+		w.bulk(args[1])
+	default:
+		w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func get(s *Server, w writer, args [][]byte) {
+	if value, ok := s.cache.VolatileLRUCacheGet(string(args[1])); ok {
+		w.bulk(valueBytes(value))
+	} else {
+		w.null()
+	}
+}
+
+// set handles SET key value [EX seconds|PX milliseconds] [NX|XX].
+func set(s *Server, w writer, args [][]byte) {
+	var keyExpire time.Duration
+	var opts []spectre.SetOption
+	expireGiven, conditionGiven := false, false
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToLower(string(args[i])); option {
+		case "ex", "px":
+			if expireGiven || i+1 == len(args) {
+				w.error(errSyntax)
+				return
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				w.error(errNotInteger)
+				return
+			}
+			unit := time.Second
+			if option == "px" {
+				unit = time.Millisecond
+			}
+			// like Redis, a time overflowing the expiry is refused
+			if n <= 0 || n > math.MaxInt64/int64(unit) {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			keyExpire = time.Duration(n) * unit
+			expireGiven = true
+			i++
+		case "nx", "xx":
+			if conditionGiven {
+				w.error(errSyntax)
+				return
+			}
+			if option == "nx" {
+				opts = append(opts, spectre.IfAbsent())
+			} else {
+				opts = append(opts, spectre.IfPresent())
+			}
+			conditionGiven = true
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+	value := args[2]
+	ok, err := s.cache.VolatileLRUCacheSet(string(args[1]), value, len(value), keyExpire, opts...)
+	switch {
+	case err != nil:
+		w.error("ERR " + err.Error())
+	case ok:
+		w.simple("OK")
+	default:
+		// the NX or XX condition was not met
+		w.null()
+	}
+}
+
+func del(s *Server, w writer, args [][]byte) {
+	var removed int64
+	for _, key := range args[1:] {
+		if s.cache.Remove(string(key)) {
+			removed++
+		}
+	}
+	w.integer(removed)
+}
+
+func exists(s *Server, w writer, args [][]byte) {
+	var count int64
+	for _, key := range args[1:] {
+		if s.exists(string(key)) {
+			count++
+		}
+	}
+	w.integer(count)
+}
+
+func expire(s *Server, w writer, args [][]byte) {
+	seconds, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		w.error(errNotInteger)
+		return
+	}
+	key := string(args[1])
+	if seconds > math.MaxInt64/int64(time.Second) {
+		w.error("ERR invalid expire time in 'expire' command")
+		return
+	}
+	if seconds <= 0 {
+		// like Redis, a key expiring in the past is deleted
+		if s.cache.Remove(key) {
+			w.integer(1)
+		} else {
+			w.integer(0)
+		}
+		return
+	}
+	if s.cache.Expire(key, time.Duration(seconds)*time.Second) {
+		w.integer(1)
+	} else {
+		w.integer(0)
+	}
+}
+
+func ttl(s *Server, w writer, args [][]byte) {
+	left, ok := s.cache.TTL(string(args[1]))
+	switch {
+	case !ok:
+		w.integer(-2)
+	case left == spectre.NoExpiry:
+		w.integer(-1)
+	default:
+		w.integer(int64((left + time.Second/2) / time.Second))
+	}
+}
+
+func persist(s *Server, w writer, args [][]byte) {
+	key := string(args[1])
+	left, ok := s.cache.TTL(key)
+	if !ok || left == spectre.NoExpiry || !s.cache.Expire(key, spectre.NoExpiry) {
+		w.integer(0)
+		return
+	}
+	w.integer(1)
+}
+
+func mget(s *Server, w writer, args [][]byte) {
+	w.array(len(args) - 1)
+	for _, key := range args[1:] {
+		get(s, w, [][]byte{nil, key})
+	}
+}
+
+func mset(s *Server, w writer, args [][]byte) {
+	if len(args)%2 == 0 {
+		w.error("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	for i := 1; i < len(args); i = i + 2 {
+		value := args[i+1]
+		if _, err := s.cache.VolatileLRUCacheSet(string(args[i]), value, len(value), 0); err != nil {
+			w.error("ERR " + err.Error())
+			return
+		}
+	}
+	w.simple("OK")
+}
+
+func keys(s *Server, w writer, args [][]byte) {
+	pattern := string(args[1])
+	var matches []string
+	for _, key := range s.liveKeys() {
+		if matchGlob(pattern, key) {
+			matches = append(matches, key)
+		}
+	}
+	w.array(len(matches))
+	for _, key := range matches {
+		w.bulk([]byte(key))
+	}
+}
+
+// scan handles SCAN cursor [MATCH pattern] [COUNT count]. The cursor is the
+// position in the sorted live keys, so keys set or deleted during a scan
+// may be returned twice or missed, like with Redis.
+func scan(s *Server, w writer, args [][]byte) {
+	cursor, err := strconv.Atoi(string(args[1]))
+	if err != nil || cursor < 0 {
+		w.error("ERR invalid cursor")
+		return
+	}
+	pattern, count := "*", 10
+	for i := 2; i < len(args); i = i + 2 {
+		if i+1 == len(args) {
+			w.error(errSyntax)
+			return
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			if count, err = strconv.Atoi(string(args[i+1])); err != nil || count < 1 {
+				w.error(errSyntax)
+				return
+			}
+		default:
+			w.error(errSyntax)
+			return
+		}
+	}
+	liveKeys := s.liveKeys()
+	next := cursor + count
+	if next >= len(liveKeys) {
+		next = 0
+	}
+	var matches []string
+	for i := cursor; i < cursor+count && i < len(liveKeys); i++ {
+		if matchGlob(pattern, liveKeys[i]) {
+			matches = append(matches, liveKeys[i])
+		}
+	}
+	w.array(2)
+	w.bulk([]byte(strconv.Itoa(next)))
+	w.array(len(matches))
+	for _, key := range matches {
+		w.bulk([]byte(key))
+	}
+}
+
+func flushall(s *Server, w writer, args [][]byte) {
+	s.cache.VolatileLRUCacheClear()
+	w.simple("OK")
+}
+
+func dbsize(s *Server, w writer, args [][]byte) {
+	w.integer(int64(s.cache.Stats().Entries))
+}
+
+func info(s *Server, w writer, args [][]byte) {
+	stats := s.cache.Stats()
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "# Server\r\nredis_mode:standalone\r\n\r\n")
+	fmt.Fprintf(&buffer, "# Clients\r\nconnected_clients:%d\r\n\r\n", s.conns.Clients())
+	fmt.Fprintf(&buffer, "# Memory\r\nused_memory:%d\r\nmaxmemory:%d\r\n\r\n", stats.Bytes, stats.MaxSize)
+	fmt.Fprintf(&buffer, "# Stats\r\nkeyspace_hits:%d\r\nkeyspace_misses:%d\r\nexpired_keys:%d\r\nevicted_keys:%d\r\n\r\n",
+		stats.Hits, stats.Misses, stats.Removals[spectre.Expired], stats.Removals[spectre.Evicted])
+	fmt.Fprintf(&buffer, "# Keyspace\r\ndb0:keys=%d,expires=%d,avg_ttl=0\r\n", stats.Entries, s.cache.ExpiringKeys())
+	w.bulk(buffer.Bytes())
+}
+
+// matchGlob tells if the string matches the glob style pattern of KEYS and
+// SCAN : * matches any run of bytes, ? a single byte, [abc], [^abc] and
+// [a-z] a byte of a set, and \ escapes the next character.
+func matchGlob(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			negate := len(pattern) > 0 && pattern[0] == '^'
+			if negate {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				switch {
+				case pattern[0] == '\\' && len(pattern) >= 2:
+					match = match || pattern[1] == s[0]
+					pattern = pattern[2:]
+				case len(pattern) >= 3 && pattern[1] == '-' && pattern[2] != ']':
+					low, high := pattern[0], pattern[2]
+					if low > high {
+						low, high = high, low
+					}
+					match = match || (s[0] >= low && s[0] <= high)
+					pattern = pattern[3:]
+				default:
+					match = match || pattern[0] == s[0]
+					pattern = pattern[1:]
+				}
+			}
+			if match == negate {
+				return false
+			}
+			if len(pattern) == 0 {
+				// an unterminated set ends the pattern
+				return len(s) == 1
+			}
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+	return len(s) == 0
+}
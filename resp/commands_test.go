@@ -0,0 +1,88 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"*", "", true},
+		{"*", "vivek", true},
+		{"user:*", "user:vivek", true},
+		{"user:*", "session:vivek", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"**a", "bba", true},
+	} {
+		if matchGlob(test.pattern, test.s) != test.match {
+			t.Fatalf("expected %v matching %q against %q", test.match, test.s, test.pattern)
+		}
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	r := reader{bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$0\r\n\r\n  set  vivek   ibibo \n\r\n*1\r\n$5\r\nab\r\n"))}
+	args, err := r.readCommand()
+	if err != nil || len(args) != 2 || string(args[0]) != "GET" || len(args[1]) != 0 {
+		t.Fatalf("bad multibulk command %q %v", args, err)
+	}
+	args, err = r.readCommand()
+	if err != nil || len(args) != 3 || string(args[2]) != "ibibo" {
+		t.Fatalf("bad inline command %q %v", args, err)
+	}
+	if args, err = r.readCommand(); err != nil || len(args) != 0 {
+		t.Fatalf("expected an empty command got %q %v", args, err)
+	}
+	if _, err = r.readCommand(); err == nil {
+		t.Fatalf("expected an error for a truncated bulk string")
+	}
+
+	// null and empty arrays are skipped, like Redis does
+	r = reader{bufio.NewReader(strings.NewReader("*-1\r\n*0\r\n*-2147483648\r\n*1\r\n$4\r\nPING\r\n*x\r\n"))}
+	for i := 0; i < 3; i++ {
+		if args, err = r.readCommand(); err != nil || len(args) != 0 {
+			t.Fatalf("expected an empty command got %q %v", args, err)
+		}
+	}
+	if args, err = r.readCommand(); err != nil || len(args) != 1 || string(args[0]) != "PING" {
+		t.Fatalf("command after empty arrays not read %q %v", args, err)
+	}
+	if _, err = r.readCommand(); err == nil {
+		t.Fatalf("expected an error for a bad multibulk length")
+	}
+
+	// a long argument is read as it arrives
+	long := strings.Repeat("x", 3*bulkChunk+1)
+	r = reader{bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$" + strconv.Itoa(len(long)) + "\r\n" + long + "\r\n"))}
+	if args, err = r.readCommand(); err != nil || len(args) != 2 || string(args[1]) != long {
+		t.Fatalf("long argument not read %v", err)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	r = reader{bufio.NewReader(strings.NewReader("*1024\r\n$536870912\r\nGET"))}
+	if _, err = r.readCommand(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("announced lengths allocated %d bytes", allocated)
+	}
+}
@@ -0,0 +1,163 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+)
+
+const (
+	// maxArgs bounds the number of arguments of a command.
+	maxArgs = 1024 * 1024
+	// maxBulk bounds the length of an argument, like the proto-max-bulk-len
+	// of Redis.
+	maxBulk = 512 * 1024 * 1024
+	// bulkChunk is the longest argument allocated at once, the longer ones
+	// grow as their bytes arrive so a length alone does not take memory.
+	bulkChunk = 64 * 1024
+)
+
+// protocolError is a request which is not RESP, the connection is closed
+// after it is reported.
+type protocolError struct {
+	problem string
+}
+
+func (pe *protocolError) Error() string {
+	return "Protocol error: " + pe.problem
+}
+
+// reader reads the commands sent on a connection : arrays of bulk strings,
+// sent by the clients, or inline commands typed in a telnet session.
+type reader struct {
+	*bufio.Reader
+}
+
+// readCommand returns the arguments of the next command, none for an empty
+// inline command or, like Redis, for a multibulk length of 0 or less.
+func (r reader) readCommand() ([][]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		var args [][]byte
+		for _, field := range bytes.Fields(line) {
+			args = append(args, append([]byte(nil), field...))
+		}
+		return args, nil
+	}
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count > maxArgs {
+		return nil, &protocolError{problem: "invalid multibulk length"}
+	}
+	if count <= 0 {
+		return nil, nil
+	}
+	// the arguments announced are not allocated before they arrive either
+	capacity := count
+	if capacity > 64 {
+		capacity = 64
+	}
+	args := make([][]byte, 0, capacity)
+	for i := 0; i < count; i++ {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, &protocolError{problem: "expected '$'"}
+		}
+		length, err := strconv.Atoi(string(line[1:]))
+		if err != nil || length < 0 || length > maxBulk {
+			return nil, &protocolError{problem: "invalid bulk length"}
+		}
+		arg, err := r.readBulk(length + 2)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.HasSuffix(arg, []byte("\r\n")) {
+			return nil, &protocolError{problem: "expected CRLF after bulk string"}
+		}
+		args = append(args, arg[:length])
+	}
+	return args, nil
+}
+
+// readBulk reads the n bytes of an argument and its line ending.
+func (r reader) readBulk(n int) ([]byte, error) {
+	if n <= bulkChunk {
+		arg := make([]byte, n)
+		_, err := io.ReadFull(r, arg)
+		return arg, err
+	}
+	var arg bytes.Buffer
+	if _, err := io.CopyN(&arg, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return arg.Bytes(), nil
+}
+
+// readLine returns a line without its line ending, it is only valid until
+// the next read.
+func (r reader) readLine() ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, &protocolError{problem: "too big request line"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+// writer writes the replies of a connection.
+type writer struct {
+	*bufio.Writer
+}
+
+func (w writer) simple(s string) {
+	w.WriteByte('+')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w writer) error(s string) {
+	w.WriteByte('-')
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func (w writer) integer(n int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+func (w writer) bulk(b []byte) {
+	w.WriteByte('$')
+	w.WriteString(strconv.Itoa(len(b)))
+	w.WriteString("\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+// null writes the null bulk string of a missing value.
+func (w writer) null() {
+	w.WriteString("$-1\r\n")
+}
+
+// array writes the header of an array of n replies.
+func (w writer) array(n int) {
+	w.WriteByte('*')
+	w.WriteString(strconv.Itoa(n))
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,82 @@
+// Package resp serves a spectre VolatileLRUCache with the Redis protocol,
+// RESP2, so the existing Redis clients can use it. The server supports
+// GET, SET with EX, PX, NX and XX, DEL, EXISTS, EXPIRE, TTL, PERSIST, MGET,
+// MSET, KEYS, SCAN, FLUSHALL, DBSIZE, INFO and PING, over TCP or Unix
+// sockets. Commands of a connection may be pipelined.
+//
+// Values are raw bytes, the size of a value is its length. A key set
+// without EX or PX gets the global ttl of the cache, PERSIST keeps it until
+// it is evicted.
+package resp
+
+import (
+	"bufio"
+	"net"
+
+	"github.com/vivek07672/spectre"
+	"github.com/vivek07672/spectre/internal/netserver"
+)
+
+// ErrServerClosed returns from Serve and ListenAndServe after Close
+var ErrServerClosed = netserver.NewError(24, "resp server closed")
+
+// Server serves a cache to the connections of its listeners, each
+// connection from a goroutine of its own.
+type Server struct {
+	cache *spectre.VolatileLRUCache
+	conns *netserver.Server
+}
+
+// New returns a Server for the cache.
+func New(cache *spectre.VolatileLRUCache) *Server {
+	s := &Server{cache: cache}
+	s.conns = netserver.New(s.serveConn, ErrServerClosed)
+	return s
+}
+
+// ListenAndServe listens on the address of the network, "tcp" or "unix",
+// and serves the connections, see Serve.
+func (s *Server) ListenAndServe(network string, address string) error {
+	return s.conns.ListenAndServe(network, address)
+}
+
+// Serve accepts the connections of the listener until it fails or the
+// server is closed. The listener is closed on return.
+// return values :
+//		error: ErrServerClosed after Close else the error of the listener
+func (s *Server) Serve(listener net.Listener) error {
+	return s.conns.Serve(listener)
+}
+
+// Close closes the listeners and the connections of the server, and waits
+// for the commands in flight.
+func (s *Server) Close() error {
+	s.conns.Close()
+	return nil
+}
+
+// serveConn runs the commands of a connection in their order. The replies
+// are flushed once the pipelined commands already received are run.
+func (s *Server) serveConn(conn net.Conn) {
+	r := reader{bufio.NewReader(conn)}
+	w := writer{bufio.NewWriter(conn)}
+	for {
+		args, err := r.readCommand()
+		if err != nil {
+			if protocolErr, ok := err.(*protocolError); ok {
+				w.error("ERR " + protocolErr.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(w, args)
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
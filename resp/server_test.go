@@ -0,0 +1,175 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+// startServer serves a cache of 100 bytes on the network and returns the
+// address to dial.
+func startServer(t *testing.T, network string, address string) (*spectre.VolatileLRUCache, string) {
+	cache, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour))
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("listen failed %v", err)
+	}
+	server := New(cache)
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("expected ErrServerClosed got %v", err)
+		}
+	})
+	return cache, listener.Addr().String()
+}
+
+// exchange sends the raw request and reads the raw replies expected.
+func exchange(t *testing.T, conn net.Conn, in *bufio.Reader, request string, expected string) {
+	t.Helper()
+	if _, err := io.WriteString(conn, request); err != nil {
+		t.Fatalf("write failed %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	reply := make([]byte, len(expected))
+	if _, err := io.ReadFull(in, reply); err != nil {
+		t.Fatalf("expected %q, read %q then %v", expected, reply, err)
+	}
+	if string(reply) != expected {
+		t.Fatalf("expected %q got %q", expected, reply)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	cache, address := startServer(t, "tcp", "127.0.0.1:0")
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("dial failed %v", err)
+	}
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+
+	exchange(t, conn, in, "PING\r\n", "+PONG\r\n")
+	exchange(t, conn, in, "*3\r\n$3\r\nSET\r\n$5\r\nvivek\r\n$5\r\nvi\r\nk\r\n", "+OK\r\n")
+	exchange(t, conn, in, "*2\r\n$3\r\nGET\r\n$5\r\nvivek\r\n", "$5\r\nvi\r\nk\r\n")
+	exchange(t, conn, in, "GET missing\r\n", "$-1\r\n")
+	exchange(t, conn, in, "SET vivek other NX\r\n", "$-1\r\n")
+	exchange(t, conn, in, "SET ibibo ibibo XX\r\n", "$-1\r\n")
+	exchange(t, conn, in, "SET ibibo ibibo NX EX 100\r\n", "+OK\r\n")
+	exchange(t, conn, in, "TTL ibibo\r\n", ":100\r\n")
+	exchange(t, conn, in, "PERSIST ibibo\r\n", ":1\r\n")
+	exchange(t, conn, in, "TTL ibibo\r\n", ":-1\r\n")
+	exchange(t, conn, in, "PERSIST ibibo\r\n", ":0\r\n")
+	exchange(t, conn, in, "EXPIRE ibibo 20\r\n", ":1\r\n")
+	exchange(t, conn, in, "TTL ibibo\r\n", ":20\r\n")
+	exchange(t, conn, in, "TTL missing\r\n", ":-2\r\n")
+	exchange(t, conn, in, "SET short short PX 1\r\n", "+OK\r\n")
+	time.Sleep(2 * time.Millisecond)
+	exchange(t, conn, in, "EXISTS vivek ibibo short missing\r\n", ":2\r\n")
+	exchange(t, conn, in, "MSET a 1 b 2\r\n", "+OK\r\n")
+	exchange(t, conn, in, "MGET a missing b\r\n", "*3\r\n$1\r\n1\r\n$-1\r\n$1\r\n2\r\n")
+	exchange(t, conn, in, "KEYS [ab]\r\n", "*2\r\n$1\r\na\r\n$1\r\nb\r\n")
+	exchange(t, conn, in, "DBSIZE\r\n", ":4\r\n")
+	exchange(t, conn, in, "SCAN 0 COUNT 3\r\n", "*2\r\n$1\r\n3\r\n*3\r\n$1\r\na\r\n$1\r\nb\r\n$5\r\nibibo\r\n")
+	exchange(t, conn, in, "SCAN 3 COUNT 3 MATCH v*\r\n", "*2\r\n$1\r\n0\r\n*1\r\n$5\r\nvivek\r\n")
+	exchange(t, conn, in, "DEL a b missing\r\n", ":2\r\n")
+	exchange(t, conn, in, "EXPIRE vivek 0\r\n", ":1\r\n")
+	exchange(t, conn, in, "EXPIRE vivek 0\r\n", ":0\r\n")
+	exchange(t, conn, in, "EXPIRE ibibo 9223372036854775807\r\n", "-ERR invalid expire time in 'expire' command\r\n")
+	exchange(t, conn, in, "FLUSHALL\r\n", "+OK\r\n")
+	if cache.VolatileLRUCacheCurrentSize() != 0 {
+		t.Fatalf("cache was not flushed")
+	}
+	exchange(t, conn, in, "SET vivek vivek EX 0\r\n", "-ERR invalid expire time in 'set' command\r\n")
+	exchange(t, conn, in, "SET vivek vivek EX 9223372037\r\n", "-ERR invalid expire time in 'set' command\r\n")
+	exchange(t, conn, in, "SET vivek vivek PX 9223372036855\r\n", "-ERR invalid expire time in 'set' command\r\n")
+	exchange(t, conn, in, "SET vivek vivek NX XX\r\n", "-ERR syntax error\r\n")
+	exchange(t, conn, in, "GET\r\n", "-ERR wrong number of arguments for 'get' command\r\n")
+	exchange(t, conn, in, "NOPE\r\n", "-ERR unknown command 'NOPE'\r\n")
+	exchange(t, conn, in, "QUIT\r\n", "+OK\r\n")
+	if _, err := in.ReadByte(); err != io.EOF {
+		t.Fatalf("connection was not closed after QUIT %v", err)
+	}
+}
+
+func TestPipelining(t *testing.T) {
+	_, address := startServer(t, "tcp", "127.0.0.1:0")
+	conn, _ := net.Dial("tcp", address)
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	var request, expected strings.Builder
+	for _, key := range []string{"a", "b", "c", "d"} {
+		request.WriteString("*3\r\n$3\r\nSET\r\n$1\r\n" + key + "\r\n$1\r\n" + key + "\r\n")
+		expected.WriteString("+OK\r\n")
+	}
+	request.WriteString("*3\r\n$4\r\nMGET\r\n$1\r\na\r\n$1\r\nd\r\n")
+	expected.WriteString("*2\r\n$1\r\na\r\n$1\r\nd\r\n")
+	exchange(t, conn, in, request.String(), expected.String())
+}
+
+func TestUnixSocket(t *testing.T) {
+	cache, address := startServer(t, "unix", filepath.Join(t.TempDir(), "spectre.sock"))
+	cache.VolatileLRUCacheSet("vivek", []byte("vivek"), 5, 0)
+	cache.VolatileLRUCacheSet("forever", []byte("forever"), 7, spectre.NoExpiry)
+	conn, err := net.Dial("unix", address)
+	if err != nil {
+		t.Fatalf("dial failed %v", err)
+	}
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	exchange(t, conn, in, "*2\r\n$4\r\nPING\r\n$5\r\nhello\r\n", "$5\r\nhello\r\n")
+	exchange(t, conn, in, "INFO\r\n", "$")
+	header, _ := in.ReadString('\n')
+	length, err := strconv.Atoi(strings.TrimSuffix(header, "\r\n"))
+	if err != nil {
+		t.Fatalf("bad info header %q", header)
+	}
+	info := make([]byte, length+2)
+	io.ReadFull(in, info)
+	if !strings.Contains(string(info), "db0:keys=2,expires=1,") {
+		t.Fatalf("keys without ttl counted as expiring %q", info)
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	_, address := startServer(t, "tcp", "127.0.0.1:0")
+	conn, _ := net.Dial("tcp", address)
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	// negative and zero counts are skipped without killing the server
+	exchange(t, conn, in, "*-1\r\n*0\r\n*1\r\n$4\r\nPING\r\n", "+PONG\r\n")
+	exchange(t, conn, in, "*1\r\n+PING\r\n", "-ERR Protocol error: expected '$'\r\n")
+	if _, err := in.ReadByte(); err != io.EOF {
+		t.Fatalf("connection was not closed after a protocol error %v", err)
+	}
+}
+
+func BenchmarkPipelinedGet(b *testing.B) {
+	cache, _ := spectre.New(spectre.WithMaxSize(100), spectre.WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("vivek", []byte("vivek"), 5, 0)
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := New(cache)
+	go server.Serve(listener)
+	defer server.Close()
+	conn, _ := net.Dial("tcp", listener.Addr().String())
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	request := strings.Repeat("*2\r\n$3\r\nGET\r\n$5\r\nvivek\r\n", 100)
+	reply := make([]byte, 100*len("$5\r\nvivek\r\n"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.WriteString(conn, request)
+		io.ReadFull(in, reply)
+	}
+}
@@ -0,0 +1,205 @@
+// Package server serves a spectre VolatileLRUCache over HTTP, so processes
+// which are not written in Go can share it :
+//
+//	GET    /keys/{key}      the value of the key, 404 when it is missing
+//	PUT    /keys/{key}      sets the body as the value of the key
+//	DELETE /keys/{key}      deletes the key
+//	GET    /keys?prefix=    the live keys starting with the prefix, in JSON
+//	POST   /flush           clears the cache
+//	GET    /stats           the statistics of the cache, in JSON
+//
+// Values are raw bytes, the size of a value is its length. The time to live
+// of a set is given by the X-Spectre-TTL header or the ttl query parameter,
+// as a Go duration like "90s" or a number of seconds, the global ttl of the
+// cache without them.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+// TTLHeader is the header giving the time to live of a set.
+const TTLHeader = "X-Spectre-TTL"
+
+// Server is the http.Handler serving a cache.
+type Server struct {
+	cache *spectre.VolatileLRUCache
+	mux   *http.ServeMux
+}
+
+// New returns a Server for the cache.
+func New(cache *spectre.VolatileLRUCache) *Server {
+	s := &Server{cache: cache, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /keys/{key...}", s.get)
+	s.mux.HandleFunc("PUT /keys/{key...}", s.set)
+	s.mux.HandleFunc("DELETE /keys/{key...}", s.delete)
+	s.mux.HandleFunc("GET /keys", s.keys)
+	s.mux.HandleFunc("POST /flush", s.flush)
+	s.mux.HandleFunc("GET /stats", s.stats)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	value, ok := s.cache.VolatileLRUCacheGet(r.PathValue("key"))
+	if !ok {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	switch value := value.(type) {
+	case []byte:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(value)
+	case string:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, value)
+	case interface{ Bytes() []byte }:
+		// an item of the memcache package
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(value.Bytes())
+	default:
+		// a value set by the Go side of the cache
+		writeJSON(w, value)
+	}
+}
+
+func (s *Server) set(w http.ResponseWriter, r *http.Request) {
+	ttl, err := parseTTL(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// a value larger than the cache can never be set
+	maxSize := int64(s.cache.Stats().MaxSize)
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		http.Error(w, spectre.SizeLimitError.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err = s.cache.VolatileLRUCacheSet(r.PathValue("key"), value, len(value), ttl)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case spectre.SizeLimitError:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case spectre.LowSpaceError:
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+	case spectre.ErrClosed:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	s.cache.VolatileLRUCacheDelete(r.PathValue("key"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) keys(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	rows := make(chan spectre.CacheRow)
+	s.cache.VolatileLRUCacheIterator(rows)
+	keys := []string{}
+	for row := range rows {
+		if strings.HasPrefix(row.Key, prefix) {
+			keys = append(keys, row.Key)
+		}
+	}
+	sort.Strings(keys)
+	writeJSON(w, keys)
+}
+
+func (s *Server) flush(w http.ResponseWriter, r *http.Request) {
+	s.cache.VolatileLRUCacheClear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// statsResponse is the JSON form of spectre.Stats.
+type statsResponse struct {
+	Hits          uint64            `json:"hits"`
+	Misses        uint64            `json:"misses"`
+	ExpiredMisses uint64            `json:"expired_misses"`
+	HitRatio      float64           `json:"hit_ratio"`
+	Sets          uint64            `json:"sets"`
+	RejectedSets  uint64            `json:"rejected_sets"`
+	Removals      map[string]uint64 `json:"removals"`
+	BytesEvicted  uint64            `json:"bytes_evicted"`
+	Entries       int               `json:"entries"`
+	Bytes         int               `json:"bytes"`
+	MaxSize       int               `json:"max_size"`
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	stats := s.cache.Stats()
+	response := statsResponse{
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		ExpiredMisses: stats.ExpiredMisses,
+		HitRatio:      stats.HitRatio(),
+		Sets:          stats.Sets,
+		RejectedSets:  stats.RejectedSets,
+		Removals:      make(map[string]uint64),
+		BytesEvicted:  stats.BytesEvicted,
+		Entries:       stats.Entries,
+		Bytes:         stats.Bytes,
+		MaxSize:       stats.MaxSize,
+	}
+	for reason, count := range stats.Removals {
+		response.Removals[reason.String()] = count
+	}
+	writeJSON(w, response)
+}
+
+// parseTTL returns the time to live given by the header or the query of a
+// set, 0 without it.
+func parseTTL(r *http.Request) (time.Duration, error) {
+	ttl := r.Header.Get(TTLHeader)
+	if ttl == "" {
+		ttl = r.URL.Query().Get("ttl")
+	}
+	if ttl == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseInt(ttl, 10, 64); err == nil {
+		// like resp, a number of seconds overflowing the ttl is refused
+		if seconds < 0 || seconds > math.MaxInt64/int64(time.Second) {
+			return 0, fmt.Errorf("invalid ttl %q", ttl)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	duration, err := time.ParseDuration(ttl)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid ttl %q", ttl)
+	}
+	return duration, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
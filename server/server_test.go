@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vivek07672/spectre"
+)
+
+// newTestServer returns a server of a cache of maxSize bytes.
+func newTestServer(t *testing.T, maxSize int) (*spectre.VolatileLRUCache, *httptest.Server) {
+	cache, err := spectre.New(spectre.WithMaxSize(maxSize), spectre.WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("new failed %v", err)
+	}
+	httpServer := httptest.NewServer(New(cache))
+	t.Cleanup(httpServer.Close)
+	return cache, httpServer
+}
+
+func do(t *testing.T, method string, url string, body string, header http.Header) (*http.Response, string) {
+	request, _ := http.NewRequest(method, url, strings.NewReader(body))
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%v %v failed %v", method, url, err)
+	}
+	defer response.Body.Close()
+	data, _ := io.ReadAll(response.Body)
+	return response, string(data)
+}
+
+func TestKeys(t *testing.T) {
+	cache, httpServer := newTestServer(t, 100)
+	if response, _ := do(t, "PUT", httpServer.URL+"/keys/user/vivek", "vivek", nil); response.StatusCode != http.StatusNoContent {
+		t.Fatalf("put failed %v", response.Status)
+	}
+	response, body := do(t, "GET", httpServer.URL+"/keys/user/vivek", "", nil)
+	if response.StatusCode != http.StatusOK || body != "vivek" {
+		t.Fatalf("expected vivek got %v %q", response.Status, body)
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 5 {
+		t.Fatalf("expected size 5 got %v", cache.VolatileLRUCacheCurrentSize())
+	}
+	do(t, "PUT", httpServer.URL+"/keys/user/ibibo", "ibibo", nil)
+	do(t, "PUT", httpServer.URL+"/keys/spectre", "spectre", nil)
+	_, body = do(t, "GET", httpServer.URL+"/keys?prefix=user/", "", nil)
+	var keys []string
+	if err := json.Unmarshal([]byte(body), &keys); err != nil || len(keys) != 2 || keys[0] != "user/ibibo" || keys[1] != "user/vivek" {
+		t.Fatalf("expected the 2 user keys got %v %v", body, err)
+	}
+
+	do(t, "DELETE", httpServer.URL+"/keys/user/vivek", "", nil)
+	if response, _ := do(t, "GET", httpServer.URL+"/keys/user/vivek", "", nil); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a deleted key got %v", response.Status)
+	}
+	if response, _ := do(t, "POST", httpServer.URL+"/flush", "", nil); response.StatusCode != http.StatusNoContent {
+		t.Fatalf("flush failed %v", response.Status)
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 0 {
+		t.Fatalf("cache was not flushed")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	_, httpServer := newTestServer(t, 100)
+	do(t, "PUT", httpServer.URL+"/keys/header", "header", http.Header{TTLHeader: {"10ms"}})
+	do(t, "PUT", httpServer.URL+"/keys/query?ttl=1", "query", nil)
+	time.Sleep(20 * time.Millisecond)
+	if response, _ := do(t, "GET", httpServer.URL+"/keys/header", "", nil); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected an expired key got %v", response.Status)
+	}
+	if response, _ := do(t, "GET", httpServer.URL+"/keys/query", "", nil); response.StatusCode != http.StatusOK {
+		t.Fatalf("expected a live key got %v", response.Status)
+	}
+	for _, ttl := range []string{"soon", "-1", "9223372037"} {
+		if response, _ := do(t, "PUT", httpServer.URL+"/keys/bad?ttl="+ttl, "bad", nil); response.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected a bad request for %v got %v", ttl, response.Status)
+		}
+	}
+	if response, _ := do(t, "GET", httpServer.URL+"/keys/bad", "", nil); response.StatusCode != http.StatusNotFound {
+		t.Fatalf("key with a bad ttl was set %v", response.Status)
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	_, httpServer := newTestServer(t, 10)
+	if response, _ := do(t, "PUT", httpServer.URL+"/keys/huge", strings.Repeat("x", 11), nil); response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected too large got %v", response.Status)
+	}
+}
+
+func TestStats(t *testing.T) {
+	cache, httpServer := newTestServer(t, 100)
+	cache.VolatileLRUCacheSet("vivek", 42, 5, time.Duration(0))
+	if _, body := do(t, "GET", httpServer.URL+"/keys/vivek", "", nil); body != "42\n" {
+		t.Fatalf("expected a JSON value got %q", body)
+	}
+	do(t, "GET", httpServer.URL+"/keys/missing", "", nil)
+	do(t, "DELETE", httpServer.URL+"/keys/vivek", "", nil)
+	_, body := do(t, "GET", httpServer.URL+"/stats", "", nil)
+	var stats statsResponse
+	if err := json.Unmarshal([]byte(body), &stats); err != nil {
+		t.Fatalf("bad stats %v", err)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Removals["deleted"] != 1 || stats.MaxSize != 100 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,149 @@
+package spectre
+
+import (
+	"reflect"
+)
+
+const (
+	// AutoSize given as the size of a set makes the cache estimate the size
+	// of the value, with its Sizer when it implements one else with
+	// EstimateSize. Any negative size does the same, so the -1 returned by
+	// binary.Size for the types it can not measure is estimated too.
+	AutoSize = -1
+	// EntryOverhead is the approximate number of bytes the cache spends on
+	// the bookkeeping of every key, charged by WithHeapAccounting.
+	EntryOverhead = 128
+)
+
+// Sizer is implemented by the values able to tell their own size in bytes.
+// It is preferred to the reflection based EstimateSize.
+type Sizer interface {
+	Size() int
+}
+
+// EstimateSize returns the approximate number of bytes held by the value :
+// its own size plus everything reachable from it through strings, slices,
+// maps, pointers and interfaces. Values implementing Sizer report their own
+// size, anywhere in the graph. Memory shared by several pointers is counted
+// once, channels, functions and nil pointers count as their header only ;
+// the Size method of a nil pointer is not called.
+func EstimateSize(value interface{}) int {
+	if value == nil {
+		return 0
+	}
+	v := reflect.ValueOf(value)
+	if isNilPointer(v) {
+		return int(v.Type().Size())
+	}
+	if sizer, ok := value.(Sizer); ok {
+		return sizer.Size()
+	}
+	estimator := &sizeEstimator{seen: make(map[uintptr]bool)}
+	return int(v.Type().Size()) + estimator.indirect(v)
+}
+
+// sizeEstimator walks a value graph, remembering the memory already counted.
+type sizeEstimator struct {
+	seen map[uintptr]bool
+}
+
+// visit tells if the memory at the address has to be counted, false once
+// it has been counted.
+func (se *sizeEstimator) visit(address uintptr) bool {
+	if se.seen[address] {
+		return false
+	}
+	se.seen[address] = true
+	return true
+}
+
+// isNilPointer tells if the value is a nil pointer, or an interface
+// holding one.
+func isNilPointer(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// sizerSize returns the size a Sizer value reports for itself, a nil
+// pointer is not asked.
+func (se *sizeEstimator) sizerSize(v reflect.Value) (int, bool) {
+	if !v.CanInterface() || isNilPointer(v) {
+		return 0, false
+	}
+	sizer, ok := v.Interface().(Sizer)
+	if !ok {
+		return 0, false
+	}
+	return sizer.Size(), true
+}
+
+// direct returns the size of the value with everything it refers to.
+func (se *sizeEstimator) direct(v reflect.Value) int {
+	if size, ok := se.sizerSize(v); ok {
+		return size
+	}
+	return int(v.Type().Size()) + se.indirect(v)
+}
+
+// indirect returns the size of the memory the value refers to, its own
+// inline size excluded.
+func (se *sizeEstimator) indirect(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len()
+	case reflect.Slice:
+		if v.IsNil() || !se.visit(v.Pointer()) {
+			return 0
+		}
+		size := v.Cap() * int(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size = size + se.inner(v.Index(i))
+		}
+		return size
+	case reflect.Array:
+		size := 0
+		for i := 0; i < v.Len(); i++ {
+			size = size + se.inner(v.Index(i))
+		}
+		return size
+	case reflect.Map:
+		if v.IsNil() || !se.visit(v.Pointer()) {
+			return 0
+		}
+		entrySize := int(v.Type().Key().Size() + v.Type().Elem().Size())
+		size := v.Len() * entrySize
+		iter := v.MapRange()
+		for iter.Next() {
+			size = size + se.inner(iter.Key()) + se.inner(iter.Value())
+		}
+		return size
+	case reflect.Ptr:
+		if v.IsNil() || !se.visit(v.Pointer()) {
+			return 0
+		}
+		return se.direct(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return se.direct(v.Elem())
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < v.NumField(); i++ {
+			size = size + se.inner(v.Field(i))
+		}
+		return size
+	}
+	return 0
+}
+
+// inner returns the extra size of a value stored inline in another one,
+// whose inline size is already counted by its container.
+func (se *sizeEstimator) inner(v reflect.Value) int {
+	if size, ok := se.sizerSize(v); ok {
+		return maxInt(0, size-int(v.Type().Size()))
+	}
+	return se.indirect(v)
+}
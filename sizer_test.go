@@ -0,0 +1,114 @@
+package spectre
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+type sizedValue struct{}
+
+func (sv sizedValue) Size() int {
+	return 1000
+}
+
+type treeNode struct {
+	Name     string
+	Children []*treeNode
+	Parent   *treeNode
+}
+
+func TestEstimateSize(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected int
+	}{
+		{nil, 0},
+		{int64(7), 8},
+		{"vivek", 16 + 5},
+		{[]byte("vivek"), 24 + 5},
+		{[]string{"vivek", "ibibo"}, 24 + 2*16 + 10},
+		{map[string]int{"vivek": 1}, 8 + 24 + 5},
+		{struct {
+			Name string
+			Tags []string
+		}{"vivek", []string{"go"}}, 16 + 24 + 5 + 16 + 2},
+		{sizedValue{}, 1000},
+		{[]sizedValue{{}, {}}, 24 + 2000},
+	}
+	for _, c := range cases {
+		if size := EstimateSize(c.value); size != c.expected {
+			t.Fatalf("expected size %v for %#v got %v", c.expected, c.value, size)
+		}
+	}
+}
+
+type sizedBuffer struct {
+	data []byte
+}
+
+func (sb *sizedBuffer) Size() int {
+	return len(sb.data)
+}
+
+func TestEstimateSizeNilSizer(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected int
+	}{
+		{(*sizedBuffer)(nil), 8},
+		{struct{ B *sizedBuffer }{}, 8},
+		{struct{ S Sizer }{(*sizedBuffer)(nil)}, 16 + 8},
+		{[]*sizedBuffer{nil, {data: []byte("vivek")}}, 24 + 2*8},
+		{map[string]*sizedBuffer{"vivek": nil}, 8 + 16 + 5 + 8},
+	}
+	for _, c := range cases {
+		if size := EstimateSize(c.value); size != c.expected {
+			t.Fatalf("expected size %v for %#v got %v", c.expected, c.value, size)
+		}
+	}
+}
+
+func TestEstimateSizeCycle(t *testing.T) {
+	root := &treeNode{Name: "root"}
+	child := &treeNode{Name: "child", Parent: root}
+	root.Children = []*treeNode{child}
+	// pointer, two nodes counted once, their names and the children slice
+	nodeSize := 16 + 24 + 8
+	expected := 8 + 2*nodeSize + 4 + 5 + 8
+	if size := EstimateSize(root); size != expected {
+		t.Fatalf("expected size %v got %v", expected, size)
+	}
+}
+
+func TestAutoSize(t *testing.T) {
+	cache, _ := NewCache(WithMaxSize(100))
+	cache.CacheSet("vivek", "vivek", AutoSize)
+	if size := cache.GetCurrentSize(); size != 21 {
+		t.Fatalf("expected estimated size 21 got %v", size)
+	}
+	// binary.Size gives -1 for a string, it is estimated too
+	cache.CacheSet("ibibo", "ibibo", binary.Size("ibibo"))
+	if size := cache.GetCurrentSize(); size != 42 {
+		t.Fatalf("expected estimated size 42 got %v", size)
+	}
+	if _, err := cache.CacheSet("spectre", sizedValue{}, AutoSize); err != SizeLimitError {
+		t.Fatalf("expected SizeLimitError for the Sizer value got %v", err)
+	}
+}
+
+func TestHeapAccounting(t *testing.T) {
+	cache, _ := New(WithMaxSize(1000), WithTTL(time.Hour), WithHeapAccounting())
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	expected := 5 + 16 + 5 + EntryOverhead
+	if size := cache.VolatileLRUCacheCurrentSize(); size != expected {
+		t.Fatalf("expected size %v got %v", expected, size)
+	}
+}
+
+func BenchmarkEstimateSize(b *testing.B) {
+	value := map[string][]string{"vivek": {"a", "b"}, "ibibo": {"c"}}
+	for i := 0; i < b.N; i++ {
+		EstimateSize(value)
+	}
+}
@@ -0,0 +1,415 @@
+package spectre
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotError is the error which is thrown when a snapshot can not be read.
+type snapshotError struct {
+	errorNumber int
+	problem     string
+}
+
+func (se *snapshotError) Error() string {
+	return fmt.Sprintf("%d---%s", se.errorNumber, se.problem)
+}
+
+var (
+	// CorruptSnapshotError returns when the data read is not a snapshot or
+	// is truncated
+	CorruptSnapshotError = &snapshotError{problem: "snapshot is corrupt or truncated", errorNumber: 19}
+	// SnapshotVersionError returns when the snapshot was written by an
+	// unknown version of the format
+	SnapshotVersionError = &snapshotError{problem: "snapshot format version is not supported", errorNumber: 20}
+	// SnapshotChecksumError returns when the checksum of the snapshot does
+	// not match its content
+	SnapshotChecksumError = &snapshotError{problem: "snapshot checksum mismatch", errorNumber: 21}
+	// PartialSnapshotError returns when some keys of a snapshot could not be
+	// set in the cache, it is wrapped with their count and the first error
+	PartialSnapshotError = &snapshotError{problem: "some keys of the snapshot were not set", errorNumber: 33}
+)
+
+const (
+	// snapshotMagic starts every snapshot.
+	snapshotMagic = "SPECTRE\x00"
+	// snapshotVersion is the version of the format written by SaveSnapshot,
+	// the version 2 adds the tags of the keys. The version 1 is still read.
+	snapshotVersion = 2
+	// maxSnapshotField bounds the length of a key or a value read back, so a
+	// corrupt length does not allocate the memory of the machine.
+	maxSnapshotField = 1 << 30
+)
+
+// Codec turns the keys and values of a cache into bytes for its snapshots
+// and back.
+type Codec[K comparable, V any] interface {
+	EncodeKey(key K) ([]byte, error)
+	DecodeKey(data []byte) (K, error)
+	EncodeValue(value V) ([]byte, error)
+	DecodeValue(data []byte) (V, error)
+}
+
+// GobCodec is the default Codec, it writes the keys and values with
+// encoding/gob. Values stored in an interface type, like the ones of
+// VolatileLRUCache, must have their concrete type registered with
+// gob.Register unless it is a basic type.
+type GobCodec[K comparable, V any] struct{}
+
+func (GobCodec[K, V]) EncodeKey(key K) ([]byte, error) {
+	return gobEncode(&key)
+}
+
+func (GobCodec[K, V]) DecodeKey(data []byte) (K, error) {
+	var key K
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&key)
+	return key, err
+}
+
+func (GobCodec[K, V]) EncodeValue(value V) ([]byte, error) {
+	return gobEncode(&value)
+}
+
+func (GobCodec[K, V]) DecodeValue(data []byte) (V, error) {
+	var value V
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+func gobEncode(v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// snapshotEntry is a key of a snapshot with its value, charged size,
+// absolute expiry and tags.
+type snapshotEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	size       int
+	expireTime time.Time
+	tags       []string
+}
+
+// snapshotEntries returns the live keys of the cache, see liveEntries.
+func (vlruCache *VolatileLRU[K, V]) snapshotEntries() []snapshotEntry[K, V] {
+	vlruCache.RLocker().Lock()
+	defer vlruCache.RLocker().Unlock()
+	return vlruCache.liveEntries()
+}
+
+// liveEntries returns the live keys of the cache, the least recently used
+// first, or in expiry order for a policy which is not an OrderedPolicy. The
+// caller holds the read lock.
+func (vlruCache *VolatileLRU[K, V]) liveEntries() []snapshotEntry[K, V] {
+	var keys []K
+	vlruCache.cache.policyLock.Lock()
+	if orderedPolicy, ok := vlruCache.cache.policy.(OrderedPolicy[K]); ok {
+		keys = orderedPolicy.Keys()
+	}
+	vlruCache.cache.policyLock.Unlock()
+	if keys == nil {
+		for _, link := range vlruCache.ttlLinks.sorted() {
+			keys = append(keys, link.key)
+		}
+	}
+	entries := make([]snapshotEntry[K, V], 0, len(keys))
+	for _, key := range keys {
+		link, ok := vlruCache.linkMap[key]
+		if !ok || link.isLinkTTLExpired() {
+			continue
+		}
+		if value, ok := vlruCache.cache.peek(key); ok {
+			entries = append(entries, snapshotEntry[K, V]{
+				key:        key,
+				value:      value,
+				size:       link.size,
+				expireTime: link.ExpireTime,
+				tags:       vlruCache.cache.Tags(key),
+			})
+		}
+	}
+	return entries
+}
+
+// SaveSnapshot writes the live keys of the cache to w with their value,
+// size, expiry and tags, the least recently used first. The snapshot starts with
+// a format version and ends with a CRC-32 checksum ; keys and values are
+// written by the Codec given with WithSnapshotCodec, GobCodec by default.
+// The keys are collected under the read lock, the encoding and the writes
+// happen without it.
+// return values :
+//		error: the first error of the codec or of w else nil
+func (vlruCache *VolatileLRU[K, V]) SaveSnapshot(w io.Writer) error {
+	entries := vlruCache.snapshotEntries()
+	checksum := crc32.NewIEEE()
+	out := bufio.NewWriter(io.MultiWriter(w, checksum))
+	out.WriteString(snapshotMagic)
+	writeUvarint(out, snapshotVersion)
+	writeUvarint(out, uint64(len(entries)))
+	for _, entry := range entries {
+		key, err := vlruCache.codec.EncodeKey(entry.key)
+		if err != nil {
+			return err
+		}
+		value, err := vlruCache.codec.EncodeValue(entry.value)
+		if err != nil {
+			return err
+		}
+		writeUvarint(out, uint64(len(key)))
+		out.Write(key)
+		writeUvarint(out, uint64(len(value)))
+		out.Write(value)
+		writeUvarint(out, uint64(entry.size))
+		writeVarint(out, entry.expireTime.UnixNano())
+		writeUvarint(out, uint64(len(entry.tags)))
+		for _, tag := range entry.tags {
+			writeUvarint(out, uint64(len(tag)))
+			out.WriteString(tag)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], checksum.Sum32())
+	_, err := w.Write(sum[:])
+	return err
+}
+
+// LoadSnapshot sets the keys of a snapshot written by SaveSnapshot in the
+// cache, with their size, expiry and tags ; the keys already expired are
+// skipped. They are set in the order of the snapshot, so the recency of
+// the keys is rebuilt for the eviction policy. Nothing is set unless the
+// whole snapshot is read and its checksum matches ; the keys the cache
+// refuses, like the ones bigger than its max size, are counted in the
+// error while the others are set.
+// return values :
+//		error: CorruptSnapshotError, SnapshotVersionError or
+//			   SnapshotChecksumError for a bad snapshot, the error of the
+//			   codec, PartialSnapshotError wrapped with the count of the
+//			   keys not set, ErrClosed after Close else nil
+func (vlruCache *VolatileLRU[K, V]) LoadSnapshot(r io.Reader) error {
+	buffered := bufio.NewReader(r)
+	in := &checksumReader{r: buffered, checksum: crc32.NewIEEE()}
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(in, magic); err != nil || string(magic) != snapshotMagic {
+		return CorruptSnapshotError
+	}
+	version, err := binary.ReadUvarint(in)
+	if err != nil {
+		return CorruptSnapshotError
+	}
+	if version != 1 && version != snapshotVersion {
+		return SnapshotVersionError
+	}
+	count, err := binary.ReadUvarint(in)
+	if err != nil {
+		return CorruptSnapshotError
+	}
+	var records []snapshotRecord
+	for i := uint64(0); i < count; i++ {
+		record, err := readSnapshotRecord(in, version)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	var sum [4]byte
+	if _, err := io.ReadFull(buffered, sum[:]); err != nil {
+		return CorruptSnapshotError
+	}
+	if binary.BigEndian.Uint32(sum[:]) != in.checksum.Sum32() {
+		return SnapshotChecksumError
+	}
+	entries := make([]snapshotEntry[K, V], len(records))
+	for i, record := range records {
+		if entries[i].key, err = vlruCache.codec.DecodeKey(record.key); err != nil {
+			return err
+		}
+		if entries[i].value, err = vlruCache.codec.DecodeValue(record.value); err != nil {
+			return err
+		}
+		entries[i].size = record.size
+		entries[i].expireTime = record.expireTime
+		entries[i].tags = record.tags
+	}
+	return vlruCache.restore(entries)
+}
+
+// snapshotRecord is a key of a snapshot read back, not decoded yet.
+type snapshotRecord struct {
+	key        []byte
+	value      []byte
+	size       int
+	expireTime time.Time
+	tags       []string
+}
+
+// readSnapshotRecord reads a key of a snapshot of the given version.
+func readSnapshotRecord(in *checksumReader, version uint64) (snapshotRecord, error) {
+	var record snapshotRecord
+	var err error
+	if record.key, err = readField(in); err != nil {
+		return record, err
+	}
+	if record.value, err = readField(in); err != nil {
+		return record, err
+	}
+	size, err := binary.ReadUvarint(in)
+	if err != nil {
+		return record, CorruptSnapshotError
+	}
+	expireTime, err := binary.ReadVarint(in)
+	if err != nil {
+		return record, CorruptSnapshotError
+	}
+	record.size = int(size)
+	record.expireTime = time.Unix(0, expireTime)
+	if version < 2 {
+		return record, nil
+	}
+	count, err := binary.ReadUvarint(in)
+	if err != nil || count > maxSnapshotField {
+		return record, CorruptSnapshotError
+	}
+	for i := uint64(0); i < count; i++ {
+		tag, err := readField(in)
+		if err != nil {
+			return record, err
+		}
+		record.tags = append(record.tags, string(tag))
+	}
+	return record, nil
+}
+
+// restore sets the keys read from a snapshot, skipping the expired ones.
+// return values :
+//		error: PartialSnapshotError wrapped with the count of the keys the
+//			   cache refused, ErrClosed after Close else nil
+func (vlruCache *VolatileLRU[K, V]) restore(entries []snapshotEntry[K, V]) error {
+	vlruCache.Lock()
+	defer vlruCache.Unlock()
+	if vlruCache.isClosed() {
+		return ErrClosed
+	}
+	vlruCache.RemoveVolatileKey()
+	now := time.Now()
+	failed := 0
+	var firstErr error
+	for _, entry := range entries {
+		if entry.expireTime.Before(now) {
+			continue
+		}
+		// the size was charged when the key was first set
+		success, err := vlruCache.store(entry.key, entry.value, entry.size, entry.expireTime, WithTags(entry.tags...))
+		vlruCache.cache.stats.recordSet(success, err)
+		if err != nil {
+			if failed == 0 {
+				firstErr = err
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d keys, first error %v", PartialSnapshotError, failed, len(entries), firstErr)
+	}
+	return nil
+}
+
+// SaveSnapshotFile writes a snapshot of the cache to the file at path, see
+// SaveSnapshot. The snapshot is written to a temporary file of the same
+// directory renamed over path once synced, so path always holds a whole
+// snapshot ; the directory is synced after the rename, so the snapshot read
+// back after a crash is the new one.
+// return values :
+//		error: the error of the file system or of SaveSnapshot else nil
+func (vlruCache *VolatileLRU[K, V]) SaveSnapshotFile(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	err = vlruCache.SaveSnapshot(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// LoadSnapshotFile sets the keys of the snapshot saved in the file at path,
+// see LoadSnapshot.
+// return values :
+//		error: the error of the file system or of LoadSnapshot else nil
+func (vlruCache *VolatileLRU[K, V]) LoadSnapshotFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return vlruCache.LoadSnapshot(file)
+}
+
+// checksumReader feeds the bytes read to a checksum.
+type checksumReader struct {
+	r        *bufio.Reader
+	checksum hash.Hash32
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.checksum.Write(p[:n])
+	return n, err
+}
+
+func (cr *checksumReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.checksum.Write([]byte{b})
+	}
+	return b, err
+}
+
+// readField reads a length prefixed key or value.
+func readField(in *checksumReader) ([]byte, error) {
+	length, err := binary.ReadUvarint(in)
+	if err != nil || length > maxSnapshotField {
+		return nil, CorruptSnapshotError
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(in, field); err != nil {
+		return nil, CorruptSnapshotError
+	}
+	return field, nil
+}
+
+func writeUvarint(w *bufio.Writer, x uint64) {
+	var buffer [binary.MaxVarintLen64]byte
+	w.Write(buffer[:binary.PutUvarint(buffer[:], x)])
+}
+
+func writeVarint(w *bufio.Writer, x int64) {
+	var buffer [binary.MaxVarintLen64]byte
+	w.Write(buffer[:binary.PutVarint(buffer[:], x)])
+}
@@ -0,0 +1,166 @@
+package spectre
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cache, _ := New(WithMaxSize(15), WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("ibibo", 7, 5, time.Minute)
+	cache.VolatileLRUCacheSet("expired", "expired", 1, time.Millisecond)
+	cache.VolatileLRUCacheSet("spectre", []byte("spectre"), 4, time.Duration(0))
+	cache.VolatileLRUCacheGet("vivek")
+	time.Sleep(2 * time.Millisecond)
+	var snapshot bytes.Buffer
+	if err := cache.SaveSnapshot(&snapshot); err != nil {
+		t.Fatalf("save failed %v", err)
+	}
+
+	restored, _ := New(WithMaxSize(15), WithTTL(time.Hour))
+	if err := restored.LoadSnapshot(&snapshot); err != nil {
+		t.Fatalf("load failed %v", err)
+	}
+	if _, ok := restored.VolatileLRUCacheGet("expired"); ok {
+		t.Fatalf("expired key was restored")
+	}
+	if value, ok := restored.VolatileLRUCacheGet("ibibo"); !ok || value != 7 {
+		t.Fatalf("expected 7 got %v", value)
+	}
+	if restored.VolatileLRUCacheCurrentSize() != 14 {
+		t.Fatalf("expected size 14 got %v", restored.VolatileLRUCacheCurrentSize())
+	}
+	restored.RLocker().Lock()
+	expireTime := restored.linkMap["ibibo"].ExpireTime
+	restored.RLocker().Unlock()
+	cache.RLocker().Lock()
+	original := cache.linkMap["ibibo"].ExpireTime
+	cache.RLocker().Unlock()
+	if !expireTime.Equal(original) {
+		t.Fatalf("expected expire time %v got %v", original, expireTime)
+	}
+	// spectre is the least recently used once ibibo is read after the restore
+	restored.VolatileLRUCacheSet("new", "new", 5, time.Duration(0))
+	if _, ok := restored.VolatileLRUCacheGet("spectre"); ok {
+		t.Fatalf("lru order was not restored")
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	var snapshot bytes.Buffer
+	cache.SaveSnapshot(&snapshot)
+	data := snapshot.Bytes()
+
+	corrupt := append([]byte(nil), data...)
+	// the last byte of the value, before the size, the expiry, the tag
+	// count and the checksum
+	corrupt[len(corrupt)-16] ^= 0xff
+	if err := cache.LoadSnapshot(bytes.NewReader(corrupt)); err != SnapshotChecksumError {
+		t.Fatalf("expected SnapshotChecksumError got %v", err)
+	}
+	if err := cache.LoadSnapshot(bytes.NewReader(data[:len(data)-2])); err != CorruptSnapshotError {
+		t.Fatalf("expected CorruptSnapshotError got %v", err)
+	}
+	if err := cache.LoadSnapshot(bytes.NewReader([]byte("not a snapshot"))); err != CorruptSnapshotError {
+		t.Fatalf("expected CorruptSnapshotError got %v", err)
+	}
+	future := append([]byte(nil), data...)
+	future[len(snapshotMagic)] = snapshotVersion + 1
+	if err := cache.LoadSnapshot(bytes.NewReader(future)); err != SnapshotVersionError {
+		t.Fatalf("expected SnapshotVersionError got %v", err)
+	}
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithSnapshotCodec[int, string](GobCodec[int, string]{})); err != KeyTypeError {
+		t.Fatalf("expected KeyTypeError got %v", err)
+	}
+}
+
+func TestPartialSnapshot(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("big", "big", 50, time.Duration(0))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	var snapshot bytes.Buffer
+	cache.SaveSnapshot(&snapshot)
+
+	restored, _ := New(WithMaxSize(20), WithTTL(time.Hour))
+	err := restored.LoadSnapshot(&snapshot)
+	if !errors.Is(err, PartialSnapshotError) || !strings.Contains(err.Error(), "1 of 2 keys") {
+		t.Fatalf("expected PartialSnapshotError for 1 key got %v", err)
+	}
+	if _, ok := restored.VolatileLRUCacheGet("vivek"); !ok {
+		t.Fatalf("the keys which fit were not set")
+	}
+	if stats := restored.Stats(); stats.RejectedSets != 1 {
+		t.Fatalf("expected 1 rejected set got %d", stats.RejectedSets)
+	}
+}
+
+// stringCodec writes string keys and int values as text.
+type stringCodec struct{}
+
+func (stringCodec) EncodeKey(key string) ([]byte, error)  { return []byte(key), nil }
+func (stringCodec) DecodeKey(data []byte) (string, error) { return string(data), nil }
+func (stringCodec) EncodeValue(value int) ([]byte, error) { return []byte(strconv.Itoa(value)), nil }
+func (stringCodec) DecodeValue(data []byte) (int, error)  { return strconv.Atoi(string(data)) }
+
+func TestSnapshotCodec(t *testing.T) {
+	cache, _ := NewVolatileLRU[string, int](WithMaxSize(100), WithTTL(time.Hour), WithSnapshotCodec[string, int](stringCodec{}))
+	cache.Set("vivek", 42, 5, time.Duration(0))
+	var snapshot bytes.Buffer
+	cache.SaveSnapshot(&snapshot)
+	if !bytes.Contains(snapshot.Bytes(), []byte("42")) {
+		t.Fatalf("snapshot was not written by the codec")
+	}
+	cache.Clear()
+	cache.LoadSnapshot(&snapshot)
+	if value, ok := cache.Get("vivek"); !ok || value != 42 {
+		t.Fatalf("expected 42 got %v", value)
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.snapshot")
+	cache, err := New(WithMaxSize(100), WithTTL(time.Hour), WithSnapshotFile(path, time.Hour))
+	if err != nil {
+		t.Fatalf("new failed %v", err)
+	}
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.SetAsync("ibibo", "ibibo", 5, time.Duration(0))
+	if err := cache.Close(context.Background()); err != nil {
+		t.Fatalf("close failed %v", err)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) != 0 {
+		t.Fatalf("temporary files left %v", matches)
+	}
+	restored, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	if err := restored.LoadSnapshotFile(path); err != nil {
+		t.Fatalf("load failed %v", err)
+	}
+	if _, ok := restored.VolatileLRUCacheGet("ibibo"); !ok {
+		t.Fatalf("queued write was not in the last snapshot")
+	}
+	if _, err := New(WithMaxSize(100), WithTTL(time.Hour), WithSnapshotFile("", time.Hour)); err != InvalidSnapshotError {
+		t.Fatalf("expected InvalidSnapshotError got %v", err)
+	}
+}
+
+func BenchmarkSaveSnapshot(b *testing.B) {
+	cache, _ := New(WithMaxSize(1<<20), WithTTL(time.Hour))
+	for i := 0; i < 1000; i++ {
+		cache.VolatileLRUCacheSet(strconv.Itoa(i), strconv.Itoa(i), 10, time.Duration(0))
+	}
+	var snapshot bytes.Buffer
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshot.Reset()
+		cache.SaveSnapshot(&snapshot)
+	}
+}
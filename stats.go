@@ -0,0 +1,234 @@
+package spectre
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// loadLatencyBounds are the upper bounds of the buckets of the load latency
+// histogram, the last bucket takes the slower loads.
+var loadLatencyBounds = [...]time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// LatencyHistogram is a snapshot of the latencies of the loads.
+// Counts[i] is the number of loads not slower than Bounds[i] and slower
+// than the previous bound ; the last count, past the bounds, is the number
+// of loads slower than every bound.
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Stats is a snapshot of the statistics of a cache, see Stats.
+type Stats struct {
+	// Hits and Misses count the reads, ExpiredMisses the misses of keys
+	// found expired.
+	Hits          uint64
+	Misses        uint64
+	ExpiredMisses uint64
+	// Sets counts the values stored, RejectedSets the ones refused with
+	// SizeLimitError, LowSpaceError or NamespaceLimitError.
+	Sets         uint64
+	RejectedSets uint64
+	// Removals counts the keys which left the cache, by reason.
+	Removals map[RemovalReason]uint64
+	// BytesEvicted is the size of the keys removed by the cache itself,
+	// evicted or expired.
+	BytesEvicted uint64
+	// Entries and Bytes are the current number of keys and their size,
+	// MaxSize the size the cache is allowed to grow to.
+	Entries int
+	Bytes   int
+	MaxSize int
+	// LoadSuccesses and LoadFailures count the loads of GetOrLoad and of the
+	// refreshes, LoadLatency their duration.
+	LoadSuccesses uint64
+	LoadFailures  uint64
+	LoadLatency   LatencyHistogram
+}
+
+// HitRatio returns the share of the reads which were hits, 0 without read.
+func (s Stats) HitRatio() float64 {
+	reads := s.Hits + s.Misses
+	if reads == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(reads)
+}
+
+// cacheStats keeps the counters of a cache. They are updated atomically so
+// the hot path never takes a lock for them.
+type cacheStats struct {
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	expiredMisses  atomic.Uint64
+	sets           atomic.Uint64
+	rejectedSets   atomic.Uint64
+	removals       [Cleared + 1]atomic.Uint64
+	bytesEvicted   atomic.Uint64
+	loadSuccesses  atomic.Uint64
+	loadFailures   atomic.Uint64
+	loadLatency    [len(loadLatencyBounds) + 1]atomic.Uint64
+	loadLatencySum atomic.Int64
+}
+
+// recordGet counts a read.
+func (cs *cacheStats) recordGet(hit bool) {
+	if hit {
+		cs.hits.Add(1)
+	} else {
+		cs.misses.Add(1)
+	}
+}
+
+// recordExpiredMiss counts a read of a key found expired.
+func (cs *cacheStats) recordExpiredMiss() {
+	cs.misses.Add(1)
+	cs.expiredMisses.Add(1)
+}
+
+// recordSet counts the outcome of a set.
+func (cs *cacheStats) recordSet(success bool, err error) {
+	if success {
+		cs.sets.Add(1)
+	} else if err == SizeLimitError || err == LowSpaceError || err == NamespaceLimitError {
+		cs.rejectedSets.Add(1)
+	}
+}
+
+// recordRemovals counts keys of the given total size leaving the cache.
+func (cs *cacheStats) recordRemovals(count int, size int, reason RemovalReason) {
+	cs.removals[reason].Add(uint64(count))
+	if reason == Evicted || reason == Expired {
+		cs.bytesEvicted.Add(uint64(size))
+	}
+}
+
+// recordLoad counts a load and its latency.
+func (cs *cacheStats) recordLoad(latency time.Duration, err error) {
+	if err != nil {
+		cs.loadFailures.Add(1)
+	} else {
+		cs.loadSuccesses.Add(1)
+	}
+	bucket := len(loadLatencyBounds)
+	for i, bound := range loadLatencyBounds {
+		if latency <= bound {
+			bucket = i
+			break
+		}
+	}
+	cs.loadLatency[bucket].Add(1)
+	cs.loadLatencySum.Add(int64(latency))
+}
+
+// snapshot returns the counters, the current entries and bytes are given
+// by the cache.
+func (cs *cacheStats) snapshot() Stats {
+	stats := Stats{
+		Hits:          cs.hits.Load(),
+		Misses:        cs.misses.Load(),
+		ExpiredMisses: cs.expiredMisses.Load(),
+		Sets:          cs.sets.Load(),
+		RejectedSets:  cs.rejectedSets.Load(),
+		Removals:      make(map[RemovalReason]uint64),
+		BytesEvicted:  cs.bytesEvicted.Load(),
+		LoadSuccesses: cs.loadSuccesses.Load(),
+		LoadFailures:  cs.loadFailures.Load(),
+		LoadLatency: LatencyHistogram{
+			Bounds: loadLatencyBounds[:],
+			Counts: make([]uint64, len(cs.loadLatency)),
+			Sum:    time.Duration(cs.loadLatencySum.Load()),
+		},
+	}
+	for reason := range cs.removals {
+		stats.Removals[RemovalReason(reason)] = cs.removals[reason].Load()
+	}
+	for i := range cs.loadLatency {
+		stats.LoadLatency.Counts[i] = cs.loadLatency[i].Load()
+		stats.LoadLatency.Count = stats.LoadLatency.Count + stats.LoadLatency.Counts[i]
+	}
+	return stats
+}
+
+// reset zeroes every counter.
+func (cs *cacheStats) reset() {
+	cs.hits.Store(0)
+	cs.misses.Store(0)
+	cs.expiredMisses.Store(0)
+	cs.sets.Store(0)
+	cs.rejectedSets.Store(0)
+	for i := range cs.removals {
+		cs.removals[i].Store(0)
+	}
+	cs.bytesEvicted.Store(0)
+	cs.loadSuccesses.Store(0)
+	cs.loadFailures.Store(0)
+	for i := range cs.loadLatency {
+		cs.loadLatency[i].Store(0)
+	}
+	cs.loadLatencySum.Store(0)
+}
+
+// Stats returns a snapshot of the statistics of the cache. The counters
+// are read one by one while the cache keeps running, so the snapshot is not
+// taken at a single instant.
+func (c *TypedCache[K, V]) Stats() Stats {
+	stats := c.stats.snapshot()
+	c.RLocker().Lock()
+	stats.Entries = len(c.Size)
+	stats.Bytes = c.CurrentSize
+	stats.MaxSize = c.MaxSize
+	c.RLocker().Unlock()
+	return stats
+}
+
+// ShardOccupancy returns the number of keys held by every internal map of
+// the cache, in the order of Data.MapList.
+func (c *TypedCache[K, V]) ShardOccupancy() []int {
+	c.RLocker().Lock()
+	defer c.RLocker().Unlock()
+	occupancy := make([]int, c.Data.shardCount)
+	for i, sharedMap := range c.Data.MapList {
+		sharedMap.RLocker().Lock()
+		occupancy[i] = len(sharedMap.Items)
+		sharedMap.RLocker().Unlock()
+	}
+	return occupancy
+}
+
+// ResetStats zeroes the counters of the cache, the current entries and
+// bytes are kept.
+func (c *TypedCache[K, V]) ResetStats() {
+	c.stats.reset()
+}
+
+// Stats returns a snapshot of the statistics of the cache, see TypedCache
+// Stats.
+func (vlruCache *VolatileLRU[K, V]) Stats() Stats {
+	return vlruCache.cache.Stats()
+}
+
+// ResetStats zeroes the counters of the cache, the current entries and
+// bytes are kept.
+func (vlruCache *VolatileLRU[K, V]) ResetStats() {
+	vlruCache.cache.ResetStats()
+}
+
+// ShardOccupancy returns the number of keys held by every internal map of
+// the cache, see TypedCache ShardOccupancy.
+func (vlruCache *VolatileLRU[K, V]) ShardOccupancy() []int {
+	return vlruCache.cache.ShardOccupancy()
+}
@@ -0,0 +1,98 @@
+package spectre
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCacheStats(t *testing.T) {
+	cache, _ := NewCache(WithMaxSize(10), WithEvictionPolicy(NewFIFOPolicy[string]))
+	cache.CacheSet("vivek", "vivek", 5)
+	cache.CacheSet("ibibo", "ibibo", 5)
+	cache.CacheSet("spectre", "spectre", 7)
+	cache.CacheSet("huge", "huge", 11)
+	cache.CacheGet("spectre")
+	cache.CacheGet("vivek")
+	cache.CacheDelete("spectre")
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.HitRatio() != 0.5 {
+		t.Fatalf("expected 1 hit and 1 miss got %+v", stats)
+	}
+	if stats.Sets != 3 || stats.RejectedSets != 1 {
+		t.Fatalf("expected 3 sets and 1 rejected got %+v", stats)
+	}
+	if stats.Removals[Evicted] != 2 || stats.Removals[Deleted] != 1 || stats.BytesEvicted != 10 {
+		t.Fatalf("expected 2 evictions of 10 bytes and 1 delete got %+v", stats)
+	}
+	if stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("expected an empty cache got %+v", stats)
+	}
+}
+
+func TestVolatileLRUCacheStats(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Millisecond)
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 6, time.Duration(0))
+	time.Sleep(2 * time.Millisecond)
+	cache.VolatileLRUCacheGet("vivek")
+	cache.VolatileLRUCacheGet("ibibo")
+	cache.VolatileLRUCacheClear()
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.ExpiredMisses != 1 {
+		t.Fatalf("expected 1 hit and 1 expired miss got %+v", stats)
+	}
+	if stats.Sets != 3 || stats.Removals[Replaced] != 1 || stats.Removals[Cleared] != 2 {
+		t.Fatalf("expected 3 sets, 1 replaced and 2 cleared keys got %+v", stats)
+	}
+	cache.ResetStats()
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Sets != 0 || stats.Removals[Cleared] != 0 {
+		t.Fatalf("stats were not reset %+v", stats)
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	cache.GetOrLoad(context.Background(), "vivek", func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		return key, 5, 0, nil
+	})
+	cache.GetOrLoad(context.Background(), "ibibo", func(ctx context.Context, key string) (interface{}, int, time.Duration, error) {
+		time.Sleep(2 * time.Millisecond)
+		return nil, 0, 0, errors.New("backend down")
+	})
+	stats := cache.Stats()
+	if stats.LoadSuccesses != 1 || stats.LoadFailures != 1 || stats.LoadLatency.Count != 2 {
+		t.Fatalf("expected 1 load success and 1 failure got %+v", stats)
+	}
+	if len(stats.LoadLatency.Counts) != len(stats.LoadLatency.Bounds)+1 || stats.LoadLatency.Sum < 2*time.Millisecond {
+		t.Fatalf("bad latency histogram %+v", stats.LoadLatency)
+	}
+}
+
+func BenchmarkCacheGetStats(b *testing.B) {
+	cache, _ := NewCache(WithMaxSize(1 << 20))
+	cache.CacheSet("vivek", "vivek", 5)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cache.CacheGet("vivek")
+		}
+	})
+}
+
+func TestShardOccupancy(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithPartitions(4), WithHashFunc(func(key string) uint32 {
+		return uint32(len(key))
+	}))
+	cache.VolatileLRUCacheSet("a", "a", 1, time.Duration(0))
+	cache.VolatileLRUCacheSet("b", "b", 1, time.Duration(0))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	occupancy := cache.ShardOccupancy()
+	if len(occupancy) != 4 || occupancy[1] != 3 {
+		t.Fatalf("expected the 3 keys in shard 1 got %v", occupancy)
+	}
+	if stats := cache.Stats(); stats.MaxSize != 100 {
+		t.Fatalf("expected max size 100 got %v", stats.MaxSize)
+	}
+}
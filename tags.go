@@ -0,0 +1,125 @@
+package spectre
+
+// tagIndex maps the tags given with WithTags to their keys and back, so the
+// keys of a tag are found without a scan of the cache. It is guarded by the
+// lock of the cache and follows every removal of a key.
+type tagIndex[K comparable] struct {
+	keys map[string]map[K]struct{}
+	tags map[K][]string
+}
+
+func newTagIndex[K comparable]() *tagIndex[K] {
+	return &tagIndex[K]{
+		keys: make(map[string]map[K]struct{}),
+		tags: make(map[K][]string),
+	}
+}
+
+// set replaces the tags of the key.
+func (ti *tagIndex[K]) set(key K, tags []string) {
+	ti.remove(key)
+	if len(tags) == 0 {
+		return
+	}
+	kept := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys, ok := ti.keys[tag]
+		if !ok {
+			keys = make(map[K]struct{})
+			ti.keys[tag] = keys
+		}
+		if _, duplicate := keys[key]; duplicate {
+			continue
+		}
+		keys[key] = struct{}{}
+		kept = append(kept, tag)
+	}
+	ti.tags[key] = kept
+}
+
+// remove forgets the tags of the key.
+func (ti *tagIndex[K]) remove(key K) {
+	for _, tag := range ti.tags[key] {
+		keys := ti.keys[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(ti.keys, tag)
+		}
+	}
+	delete(ti.tags, key)
+}
+
+// keysOf returns the keys carrying the tag.
+func (ti *tagIndex[K]) keysOf(tag string) []K {
+	keys := make([]K, 0, len(ti.keys[tag]))
+	for key := range ti.keys[tag] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// reset forgets every tag.
+func (ti *tagIndex[K]) reset() {
+	ti.keys = make(map[string]map[K]struct{})
+	ti.tags = make(map[K][]string)
+}
+
+// InvalidateTag removes every key set with the tag, see WithTags. The
+// removal listeners are told the keys were Deleted.
+// return values :
+//		removed: the number of keys removed
+func (c *TypedCache[K, V]) InvalidateTag(tag string) int {
+	return len(c.removeTag(tag))
+}
+
+// removeTag removes the keys of the tag and returns them.
+func (c *TypedCache[K, V]) removeTag(tag string) []K {
+	c.Lock()
+	defer c.Unlock()
+	keys := c.tags.keysOf(tag)
+	for _, key := range keys {
+		c.removeLocked(key, Deleted)
+	}
+	return keys
+}
+
+// Tags returns the tags of the key, nil for a key without tags.
+func (c *TypedCache[K, V]) Tags(key K) []string {
+	c.RLocker().Lock()
+	defer c.RLocker().Unlock()
+	return append([]string(nil), c.tags.tags[key]...)
+}
+
+// InvalidateTag removes every key set with the tag, see WithTags, with its
+// ttl information. With WithInvalidation the tag is invalidated on the other
+// caches too, so they drop their keys of the tag even when this cache does
+// not hold them.
+// return values :
+//		removed: the number of keys removed from this cache
+func (vlruCache *VolatileLRU[K, V]) InvalidateTag(tag string) int {
+	vlruCache.Lock()
+	if vlruCache.isClosed() {
+		vlruCache.Unlock()
+		return 0
+	}
+	removed := vlruCache.removeTag(tag)
+	vlruCache.Unlock()
+	vlruCache.invalidateTag(tag)
+	return removed
+}
+
+// removeTag is InvalidateTag for a caller holding the write lock, without
+// the invalidation of the other caches.
+func (vlruCache *VolatileLRU[K, V]) removeTag(tag string) int {
+	keys := vlruCache.cache.removeTag(tag)
+	for _, key := range keys {
+		vlruCache.removeLink(key)
+		vlruCache.logDelete(key)
+	}
+	return len(keys)
+}
+
+// Tags returns the tags of the key, nil for a key without tags.
+func (vlruCache *VolatileLRU[K, V]) Tags(key K) []string {
+	return vlruCache.cache.Tags(key)
+}
@@ -0,0 +1,165 @@
+package spectre
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// tagIndexSize returns the tags and the tagged keys indexed by the cache.
+func tagIndexSize(cache *VolatileLRUCache) (int, int) {
+	cache.cache.RLocker().Lock()
+	defer cache.cache.RLocker().Unlock()
+	return len(cache.cache.tags.keys), len(cache.cache.tags.tags)
+}
+
+func TestInvalidateTag(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	cache.VolatileLRUCacheSet("user:1:profile", "profile", 7, 0, WithTags("user:1"))
+	cache.VolatileLRUCacheSet("user:1:feed", "feed", 4, 0, WithTags("user:1", "feed"))
+	cache.VolatileLRUCacheSet("user:2:feed", "feed", 4, 0, WithTags("user:2", "feed", "feed"))
+	cache.VolatileLRUCacheSet("untagged", "untagged", 8, 0)
+
+	tags := cache.Tags("user:2:feed")
+	sort.Strings(tags)
+	if len(tags) != 2 || tags[0] != "feed" || tags[1] != "user:2" {
+		t.Fatalf("expected the tags [feed user:2] got %v", tags)
+	}
+	if removed := cache.InvalidateTag("user:1"); removed != 2 {
+		t.Fatalf("expected 2 keys removed got %d", removed)
+	}
+	for key, present := range map[string]bool{"user:1:profile": false, "user:1:feed": false, "user:2:feed": true, "untagged": true} {
+		if _, ok := cache.VolatileLRUCacheGet(key); ok != present {
+			t.Fatalf("expected %v present %v", key, present)
+		}
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 12 || len(cache.linkMap) != 2 {
+		t.Fatalf("size or ttl information not updated %d %d", cache.VolatileLRUCacheCurrentSize(), len(cache.linkMap))
+	}
+	if removed := cache.InvalidateTag("user:1"); removed != 0 {
+		t.Fatalf("expected no key left for the tag got %d", removed)
+	}
+	// a set replaces the tags of the key
+	cache.VolatileLRUCacheSet("user:2:feed", "feed", 4, 0)
+	if removed := cache.InvalidateTag("feed"); removed != 0 {
+		t.Fatalf("replaced tags were not forgotten, %d keys removed", removed)
+	}
+	if tags, keys := tagIndexSize(cache); tags != 0 || keys != 0 {
+		t.Fatalf("expected an empty tag index got %d tags %d keys", tags, keys)
+	}
+}
+
+func TestTagsFollowRemovals(t *testing.T) {
+	cache, _ := New(WithMaxSize(20), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	for i := 0; i < 10; i++ {
+		cache.VolatileLRUCacheSet("key"+strconv.Itoa(i), "value", 5, 0, WithTags("all", "tag"+strconv.Itoa(i)))
+	}
+	// six of the keys were evicted
+	if tags, keys := tagIndexSize(cache); tags != 5 || keys != 4 {
+		t.Fatalf("evicted keys are still indexed, %d tags %d keys", tags, keys)
+	}
+	cache.VolatileLRUCacheDelete("key9")
+	cache.VolatileLRUCacheSet("short", "short", 5, time.Millisecond, WithTags("expired"))
+	time.Sleep(2 * time.Millisecond)
+	// the expired key makes room for the untagged one
+	cache.VolatileLRUCacheSet("key0", "value", 5, 0)
+	if tags, keys := tagIndexSize(cache); tags != 4 || keys != 3 {
+		t.Fatalf("deleted or expired keys are still indexed, %d tags %d keys", tags, keys)
+	}
+	cache.VolatileLRUCacheClear()
+	if tags, keys := tagIndexSize(cache); tags != 0 || keys != 0 {
+		t.Fatalf("clear left %d tags %d keys", tags, keys)
+	}
+
+	typed, _ := NewTypedCache[string, int](WithMaxSize(100))
+	typed.Set("one", 1, 1, WithTags("odd"))
+	typed.Set("two", 2, 1, WithTags("even"))
+	typed.Set("three", 3, 1, WithTags("odd"))
+	if removed := typed.InvalidateTag("odd"); removed != 2 || typed.CurrentSize != 1 {
+		t.Fatalf("expected 2 keys removed got %d, size %d", removed, typed.CurrentSize)
+	}
+}
+
+func TestTagPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spectre.log")
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncAlways))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, 0, WithTags("people"))
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, 0, WithTags("companies"))
+	cache.VolatileLRUCacheSet("spectre", "spectre", 7, 0)
+	var snapshot bytes.Buffer
+	if err := cache.SaveSnapshot(&snapshot); err != nil {
+		t.Fatalf("save failed %v", err)
+	}
+	cache.Close(context.Background())
+
+	restored, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	defer restored.Close(context.Background())
+	if err := restored.LoadSnapshot(&snapshot); err != nil {
+		t.Fatalf("load failed %v", err)
+	}
+	replayed, err := New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncAlways))
+	if err != nil {
+		t.Fatalf("replay failed %v", err)
+	}
+	for _, reloaded := range []*VolatileLRUCache{restored, replayed} {
+		if removed := reloaded.InvalidateTag("people"); removed != 1 {
+			t.Fatalf("expected the tag to survive a reload, %d keys removed", removed)
+		}
+		if _, ok := reloaded.VolatileLRUCacheGet("ibibo"); !ok {
+			t.Fatalf("a key of another tag was removed")
+		}
+	}
+	// the keys of the tag are deleted in the log too
+	replayed.Close(context.Background())
+	replayed, err = New(WithMaxSize(100), WithTTL(time.Hour), WithOpLog(path, FsyncAlways))
+	if err != nil {
+		t.Fatalf("replay failed %v", err)
+	}
+	defer replayed.Close(context.Background())
+	if _, ok := replayed.VolatileLRUCacheGet("vivek"); ok {
+		t.Fatalf("invalidated key came back from the log")
+	}
+
+	// a snapshot of the first version has no tags
+	legacy := append([]byte(snapshotMagic), 1, 0)
+	legacy = binary.BigEndian.AppendUint32(legacy, crc32.ChecksumIEEE(legacy))
+	if err := restored.LoadSnapshot(bytes.NewReader(legacy)); err != nil {
+		t.Fatalf("version 1 snapshot not read %v", err)
+	}
+}
+
+func TestTagInvalidation(t *testing.T) {
+	transport := NewMemoryTransport()
+	first, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(transport))
+	second, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(transport))
+	defer first.Close(context.Background())
+	defer second.Close(context.Background())
+	first.VolatileLRUCacheSet("page:1", "page", 4, 0, WithTags("product:7"))
+	second.VolatileLRUCacheSet("page:2", "page", 4, 0, WithTags("product:7"))
+	second.VolatileLRUCacheSet("page:3", "page", 4, 0, WithTags("product:8"))
+	if removed := first.InvalidateTag("product:7"); removed != 1 {
+		t.Fatalf("expected 1 key removed got %d", removed)
+	}
+	if _, ok := second.VolatileLRUCacheGet("page:2"); ok {
+		t.Fatalf("tagged key of the other cache was not invalidated")
+	}
+	if _, ok := second.VolatileLRUCacheGet("page:3"); !ok {
+		t.Fatalf("key of another tag was invalidated")
+	}
+}
+
+func BenchmarkTaggedSet(b *testing.B) {
+	cache, _ := New(WithMaxSize(1000), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	for i := 0; i < b.N; i++ {
+		cache.VolatileLRUCacheSet("key"+strconv.Itoa(i%500), "value", 1, 0, WithTags("tag"+strconv.Itoa(i%50)))
+	}
+}
@@ -0,0 +1,176 @@
+package spectre
+
+const (
+	// tinyLFUWindowPercent is the share of the cache given to the admission window.
+	tinyLFUWindowPercent = 1
+	// tinyLFUProtectedPercent is the share of the main space given to the
+	// protected segment.
+	tinyLFUProtectedPercent = 80
+	// tinyLFUBytesPerEntry is the average value size assumed to size the
+	// sketch of NewTinyLFUPolicy.
+	tinyLFUBytesPerEntry = 64
+)
+
+// FrequencyEstimator is implemented by the policies keeping an estimate of
+// how often every key is used, like the W-TinyLFU policy.
+type FrequencyEstimator[K comparable] interface {
+	Estimate(key K) int
+}
+
+// tinyLFUPolicy is the W-TinyLFU policy measured in bytes. New keys enter a
+// small lru admission window. A key leaving the window is admitted in the
+// segmented lru main space only if the frequency sketch estimates it more
+// popular than the victim of the main space ; otherwise the key itself is
+// evicted, so one-hit wonders don't push out valuable entries.
+// The main space is split in a probation segment for the keys seen once
+// there and a protected segment for the keys read again.
+type tinyLFUPolicy[K comparable] struct {
+	windowMax    int
+	mainMax      int
+	protectedMax int
+	window       *sizedList[K]
+	probation    *sizedList[K]
+	protected    *sizedList[K]
+	entries      map[K]*sizedEntry[K]
+	sketch       *frequencySketch[K]
+}
+
+// NewTinyLFUPolicy returns a W-TinyLFU policy for a cache of maxSize bytes.
+// The frequency sketch is sized assuming values of 64 bytes on average, use
+// NewTinyLFUPolicyFactory when the number of keys is known.
+func NewTinyLFUPolicy[K comparable](maxSize int) EvictionPolicy[K] {
+	return newTinyLFUPolicy[K](maxSize, maxSize/tinyLFUBytesPerEntry)
+}
+
+// NewTinyLFUPolicyFactory returns a factory of W-TinyLFU policies whose
+// frequency sketch is sized for about expectedEntries keys.
+func NewTinyLFUPolicyFactory[K comparable](expectedEntries int) PolicyFactory[K] {
+	return func(maxSize int) EvictionPolicy[K] {
+		return newTinyLFUPolicy[K](maxSize, expectedEntries)
+	}
+}
+
+func newTinyLFUPolicy[K comparable](maxSize int, expectedEntries int) *tinyLFUPolicy[K] {
+	windowMax := maxSize * tinyLFUWindowPercent / 100
+	mainMax := maxSize - windowMax
+	return &tinyLFUPolicy[K]{
+		windowMax:    windowMax,
+		mainMax:      mainMax,
+		protectedMax: mainMax * tinyLFUProtectedPercent / 100,
+		window:       newSizedList[K](),
+		probation:    newSizedList[K](),
+		protected:    newSizedList[K](),
+		entries:      make(map[K]*sizedEntry[K]),
+		sketch:       newFrequencySketch[K](expectedEntries),
+	}
+}
+
+// Estimate returns the estimated frequency of the key from the sketch.
+func (tp *tinyLFUPolicy[K]) Estimate(key K) int {
+	return tp.sketch.Estimate(key)
+}
+
+func (tp *tinyLFUPolicy[K]) OnAccess(key K) {
+	tp.sketch.Increment(key)
+	if entry, ok := tp.entries[key]; ok {
+		tp.touch(entry)
+	}
+}
+
+// touch moves a read entry to the back of its segment, promoting it from
+// probation to protected.
+func (tp *tinyLFUPolicy[K]) touch(entry *sizedEntry[K]) {
+	owner := entry.owner
+	owner.remove(entry)
+	if owner == tp.probation {
+		owner = tp.protected
+	}
+	owner.pushBack(entry)
+	// the overflow of protected goes back on probation
+	for tp.protected.bytes > tp.protectedMax && tp.protected.entries.Len() > 1 {
+		demoted := tp.protected.front()
+		tp.protected.remove(demoted)
+		tp.probation.pushBack(demoted)
+	}
+}
+
+func (tp *tinyLFUPolicy[K]) OnInsert(key K, size int) {
+	tp.sketch.Increment(key)
+	if entry, ok := tp.entries[key]; ok {
+		owner := entry.owner
+		owner.remove(entry)
+		entry.size = size
+		owner.pushBack(entry)
+		tp.touch(entry)
+		return
+	}
+	entry := &sizedEntry[K]{key: key, size: size}
+	tp.entries[key] = entry
+	tp.window.pushBack(entry)
+	tp.admitWindowOverflow()
+}
+
+// admitWindowOverflow moves the keys over the window share to probation as
+// long as the main space has room for them. Once the main space is full the
+// overflow waits in the window for Victim to compare it with the main victim.
+func (tp *tinyLFUPolicy[K]) admitWindowOverflow() {
+	for tp.window.bytes > tp.windowMax {
+		candidate := tp.window.front()
+		if tp.probation.bytes+tp.protected.bytes+candidate.size > tp.mainMax {
+			return
+		}
+		tp.window.remove(candidate)
+		tp.probation.pushBack(candidate)
+	}
+}
+
+func (tp *tinyLFUPolicy[K]) OnRemove(key K) {
+	if entry, ok := tp.entries[key]; ok {
+		entry.owner.remove(entry)
+		delete(tp.entries, key)
+	}
+}
+
+// mainVictim returns the entry the main space gives up first.
+func (tp *tinyLFUPolicy[K]) mainVictim() *sizedEntry[K] {
+	if victim := tp.probation.front(); victim != nil {
+		return victim
+	}
+	return tp.protected.front()
+}
+
+func (tp *tinyLFUPolicy[K]) Victim() (K, bool) {
+	candidate := tp.window.front()
+	victim := tp.mainVictim()
+	evicted := victim
+	if candidate != nil && (victim == nil || tp.sketch.Estimate(candidate.key) <= tp.sketch.Estimate(victim.key)) {
+		// the candidate is not more popular than the victim, it is not admitted
+		evicted = candidate
+	}
+	if evicted == nil {
+		var zero K
+		return zero, false
+	}
+	evicted.owner.remove(evicted)
+	delete(tp.entries, evicted.key)
+	if evicted == victim {
+		tp.admitWindowOverflow()
+	}
+	return evicted.key, true
+}
+
+func (tp *tinyLFUPolicy[K]) Reset() {
+	tp.window = newSizedList[K]()
+	tp.probation = newSizedList[K]()
+	tp.protected = newSizedList[K]()
+	tp.entries = make(map[K]*sizedEntry[K])
+	tp.sketch.Reset()
+}
+
+// Keys lists the keys from the next candidate of the window, then the
+// probation and the protected segments.
+func (tp *tinyLFUPolicy[K]) Keys() []K {
+	keys := tp.window.keys()
+	keys = append(keys, tp.probation.keys()...)
+	return append(keys, tp.protected.keys()...)
+}
@@ -0,0 +1,70 @@
+package spectre
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTinyLFUPolicyAdmission(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithEvictionPolicy(NewTinyLFUPolicyFactory[string](1000)))
+	for i := 0; i < 100; i++ {
+		key := "hot" + strconv.Itoa(i)
+		cache.VolatileLRUCacheSet(key, key, 1, time.Duration(0))
+		for j := 0; j < 3; j++ {
+			cache.VolatileLRUCacheGet(key)
+		}
+	}
+	for i := 0; i < 500; i++ {
+		key := "once" + strconv.Itoa(i)
+		if success, err := cache.VolatileLRUCacheSet(key, key, 1, time.Duration(0)); !success {
+			t.Fatalf("set of a one-hit key failed %v", err)
+		}
+	}
+	hits := 0
+	for i := 0; i < 100; i++ {
+		if _, ok := cache.VolatileLRUCacheGet("hot" + strconv.Itoa(i)); ok {
+			hits++
+		}
+	}
+	if hits < 95 {
+		t.Fatalf("one-hit wonders evicted the frequent keys, %v hot keys left", hits)
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 100 {
+		t.Fatalf("cache size calculation is incorrect")
+	}
+}
+
+func TestTinyLFUPolicyVictim(t *testing.T) {
+	policy := newTinyLFUPolicy[string](100, 64)
+	policy.OnInsert("popular", 50)
+	policy.OnAccess("popular")
+	policy.OnAccess("popular")
+	policy.OnInsert("other", 49)
+	policy.OnInsert("new", 10)
+	// the window candidate is not more popular than the main victim
+	if victim, _ := policy.Victim(); victim != "new" {
+		t.Fatalf("expected the candidate to be rejected got %v", victim)
+	}
+	policy.OnInsert("new", 10)
+	for i := 0; i < 5; i++ {
+		policy.OnAccess("new")
+	}
+	if victim, _ := policy.Victim(); victim != "other" {
+		t.Fatalf("expected the popular candidate to be admitted got %v", victim)
+	}
+}
+
+func TestEstimateFrequency(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithEvictionPolicy(NewTinyLFUPolicy[string]))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0))
+	cache.VolatileLRUCacheGet("vivek")
+	cache.VolatileLRUCacheGet("vivek")
+	if estimate, ok := cache.EstimateFrequency("vivek"); !ok || estimate != 3 {
+		t.Fatalf("expected estimate of 3 got %v %v", estimate, ok)
+	}
+	lruCache := GetVolatileLRUCache(100, 4, time.Duration(3600))
+	if _, ok := lruCache.EstimateFrequency("vivek"); ok {
+		t.Fatalf("lru policy should not estimate frequencies")
+	}
+}
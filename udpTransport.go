@@ -0,0 +1,265 @@
+package spectre
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// transportError is the error which is thrown when an invalidation can not
+// be sent.
+type transportError struct {
+	errorNumber int
+	problem     string
+}
+
+func (te *transportError) Error() string {
+	return fmt.Sprintf("%d---%s", te.errorNumber, te.problem)
+}
+
+// InvalidationSizeError returns from Publish when an invalidation does not
+// fit in a UDP datagram
+var InvalidationSizeError = &transportError{problem: "invalidation is too big for a datagram", errorNumber: 29}
+
+const (
+	// udpMagic starts every invalidation datagram.
+	udpMagic = "SPIV"
+	// udpVersion is the version of the datagram format.
+	udpVersion = 1
+	// udpClear is the flag of a clear.
+	udpClear = 1
+	// udpTag is the flag of a tag invalidation, the tag takes the place of
+	// the key.
+	udpTag = 2
+	// maxDatagram is the largest UDP payload over IPv4.
+	maxDatagram = 65507
+)
+
+// UDPTransport is an InvalidationTransport sending each invalidation in a
+// UDP datagram, to a multicast group or to a list of peers. Datagrams may
+// be lost, so the invalidations are best effort and the keys must keep a
+// ttl bounding how long a missed invalidation leaves them stale.
+type UDPTransport struct {
+	conn     *net.UDPConn // receives, and sends to the peers
+	sender   *net.UDPConn // sends to the multicast group, else conn
+	targets  []*net.UDPAddr
+	handlers map[int]func(Invalidation)
+	next     int
+	done     chan struct{}
+	sync.RWMutex
+}
+
+// NewUDPTransport returns a transport listening on the address, like
+// "0.0.0.0:7946", and sending to the peers, see SetPeers. The address of
+// the transport itself may be one of the peers, its datagrams are dropped
+// by the cache.
+// return values :
+//		transport: the transport receiving in the background until Close
+//		error: the error of the listen or of the peer addresses
+func NewUDPTransport(address string, peers ...string) (*UDPTransport, error) {
+	local, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return nil, err
+	}
+	transport := newUDPTransport(conn, conn)
+	if err := transport.SetPeers(peers...); err != nil {
+		transport.Close()
+		return nil, err
+	}
+	go transport.receive()
+	return transport, nil
+}
+
+// NewMulticastTransport returns a transport joining the multicast group
+// address, like "239.255.77.77:7946", on the network interface, nil for
+// the one chosen by the system. Every cache of the group receives the
+// invalidations without knowing the others.
+// return values :
+//		transport: the transport receiving in the background until Close
+//		error: the error of the join
+func NewMulticastTransport(group string, ifi *net.Interface) (*UDPTransport, error) {
+	groupAddr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp", ifi, groupAddr)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	transport := newUDPTransport(conn, sender)
+	transport.targets = []*net.UDPAddr{groupAddr}
+	go transport.receive()
+	return transport, nil
+}
+
+func newUDPTransport(conn *net.UDPConn, sender *net.UDPConn) *UDPTransport {
+	return &UDPTransport{
+		conn:     conn,
+		sender:   sender,
+		handlers: make(map[int]func(Invalidation)),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetPeers replaces the addresses the invalidations are sent to.
+func (ut *UDPTransport) SetPeers(peers ...string) error {
+	targets := make([]*net.UDPAddr, 0, len(peers))
+	for _, peer := range peers {
+		target, err := net.ResolveUDPAddr("udp", peer)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	}
+	ut.Lock()
+	defer ut.Unlock()
+	ut.targets = targets
+	return nil
+}
+
+// LocalAddr returns the address the transport receives on.
+func (ut *UDPTransport) LocalAddr() net.Addr {
+	return ut.conn.LocalAddr()
+}
+
+// Publish sends the invalidation to every peer, or to the multicast group.
+// return values :
+//		error: InvalidationSizeError for a key too big, else the first
+//			   error of a send
+func (ut *UDPTransport) Publish(invalidation Invalidation) error {
+	datagram, err := encodeInvalidation(invalidation)
+	if err != nil {
+		return err
+	}
+	ut.RLocker().Lock()
+	targets := ut.targets
+	ut.RLocker().Unlock()
+	var firstErr error
+	for _, target := range targets {
+		if _, err := ut.sender.WriteToUDP(datagram, target); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Subscribe registers the handler until the returned function is called.
+// The handlers are called one at a time from the goroutine receiving the
+// datagrams.
+func (ut *UDPTransport) Subscribe(handler func(Invalidation)) func() {
+	ut.Lock()
+	defer ut.Unlock()
+	id := ut.next
+	ut.next++
+	ut.handlers[id] = handler
+	return func() {
+		ut.Lock()
+		defer ut.Unlock()
+		delete(ut.handlers, id)
+	}
+}
+
+// Close stops receiving and sending, and waits for the handler running.
+func (ut *UDPTransport) Close() error {
+	err := ut.conn.Close()
+	if ut.sender != ut.conn {
+		ut.sender.Close()
+	}
+	<-ut.done
+	return err
+}
+
+// receive hands the datagrams received to the handlers until Close, the
+// datagrams which are not invalidations are dropped.
+func (ut *UDPTransport) receive() {
+	defer close(ut.done)
+	buffer := make([]byte, maxDatagram+1)
+	for {
+		n, _, err := ut.conn.ReadFromUDP(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			continue
+		}
+		invalidation, ok := decodeInvalidation(buffer[:n])
+		if !ok {
+			continue
+		}
+		ut.RLocker().Lock()
+		handlers := make([]func(Invalidation), 0, len(ut.handlers))
+		for _, handler := range ut.handlers {
+			handlers = append(handlers, handler)
+		}
+		ut.RLocker().Unlock()
+		for _, handler := range handlers {
+			handler(invalidation)
+		}
+	}
+}
+
+// encodeInvalidation returns the datagram of an invalidation : the magic,
+// the version, the flags, the uvarint prefixed source, the uvarint
+// sequence number and the key, or the tag, up to the end.
+func encodeInvalidation(invalidation Invalidation) ([]byte, error) {
+	datagram := make([]byte, 0, len(udpMagic)+2+2*binary.MaxVarintLen64+len(invalidation.Source)+len(invalidation.Key)+len(invalidation.Tag))
+	datagram = append(datagram, udpMagic...)
+	var flags byte
+	key := invalidation.Key
+	if invalidation.Clear {
+		flags = udpClear
+	} else if invalidation.Tag != "" {
+		flags = udpTag
+		key = []byte(invalidation.Tag)
+	}
+	datagram = append(datagram, udpVersion, flags)
+	datagram = binary.AppendUvarint(datagram, uint64(len(invalidation.Source)))
+	datagram = append(datagram, invalidation.Source...)
+	datagram = binary.AppendUvarint(datagram, invalidation.Seq)
+	datagram = append(datagram, key...)
+	if len(datagram) > maxDatagram {
+		return nil, InvalidationSizeError
+	}
+	return datagram, nil
+}
+
+// decodeInvalidation reads a datagram written by encodeInvalidation, the
+// key is copied out of it.
+func decodeInvalidation(datagram []byte) (Invalidation, bool) {
+	var invalidation Invalidation
+	if len(datagram) < len(udpMagic)+2 || string(datagram[:len(udpMagic)]) != udpMagic || datagram[len(udpMagic)] != udpVersion {
+		return invalidation, false
+	}
+	flags := datagram[len(udpMagic)+1]
+	invalidation.Clear = flags&udpClear != 0
+	rest := datagram[len(udpMagic)+2:]
+	length, n := binary.Uvarint(rest)
+	if n <= 0 || uint64(len(rest)-n) < length {
+		return invalidation, false
+	}
+	invalidation.Source = string(rest[n : n+int(length)])
+	rest = rest[n+int(length):]
+	invalidation.Seq, n = binary.Uvarint(rest)
+	if n <= 0 {
+		return invalidation, false
+	}
+	switch {
+	case invalidation.Clear:
+	case flags&udpTag != 0:
+		invalidation.Tag = string(rest[n:])
+	default:
+		invalidation.Key = append([]byte{}, rest[n:]...)
+	}
+	return invalidation, true
+}
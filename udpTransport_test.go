@@ -0,0 +1,122 @@
+package spectre
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// eventually polls the condition for a second.
+func eventually(condition func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return condition()
+}
+
+func TestUDPTransport(t *testing.T) {
+	first, err := NewUDPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed %v", err)
+	}
+	defer first.Close()
+	second, err := NewUDPTransport("127.0.0.1:0", first.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("listen failed %v", err)
+	}
+	defer second.Close()
+	// the first transport sends to itself too, its cache drops the echo
+	first.SetPeers(first.LocalAddr().String(), second.LocalAddr().String())
+
+	firstCache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(first))
+	secondCache, _ := New(WithMaxSize(100), WithTTL(time.Hour), WithInvalidation(second))
+	defer firstCache.Close(context.Background())
+	defer secondCache.Close(context.Background())
+
+	firstCache.VolatileLRUCacheSet("vivek", "old", 5, 0)
+	secondCache.VolatileLRUCacheSet("vivek", "stale", 5, 0)
+	if !eventually(func() bool { _, ok := firstCache.VolatileLRUCacheGet("vivek"); return !ok }) {
+		t.Fatalf("overwritten key was not invalidated over udp")
+	}
+	firstCache.VolatileLRUCacheSet("vivek", "fresh", 5, 0)
+	if !eventually(func() bool { _, ok := secondCache.VolatileLRUCacheGet("vivek"); return !ok }) {
+		t.Fatalf("stale key was not invalidated over udp")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if value, _ := firstCache.VolatileLRUCacheGet("vivek"); value != "fresh" {
+		t.Fatalf("invalidation echoed back to its source")
+	}
+	secondCache.VolatileLRUCacheDelete("vivek")
+	if !eventually(func() bool { _, ok := firstCache.VolatileLRUCacheGet("vivek"); return !ok }) {
+		t.Fatalf("deleted key was not invalidated over udp")
+	}
+	firstCache.VolatileLRUCacheSet("ibibo", "ibibo", 5, 0)
+	secondCache.VolatileLRUCacheClear()
+	if !eventually(func() bool { return firstCache.VolatileLRUCacheCurrentSize() == 0 }) {
+		t.Fatalf("clear was not sent over udp")
+	}
+}
+
+func TestMulticastTransport(t *testing.T) {
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed %v", err)
+	}
+	group := "239.255.77.77:" + strconv.Itoa(probe.LocalAddr().(*net.UDPAddr).Port)
+	probe.Close()
+	first, err := NewMulticastTransport(group, nil)
+	if err != nil {
+		t.Skipf("multicast is not available %v", err)
+	}
+	defer first.Close()
+	second, err := NewMulticastTransport(group, nil)
+	if err != nil {
+		t.Skipf("multicast is not available %v", err)
+	}
+	defer second.Close()
+	received := make(chan Invalidation, 4)
+	second.Subscribe(func(invalidation Invalidation) { received <- invalidation })
+	if err := first.Publish(Invalidation{Source: "first", Seq: 1, Key: []byte("vivek")}); err != nil {
+		t.Skipf("multicast is not routable %v", err)
+	}
+	select {
+	case invalidation := <-received:
+		if invalidation.Source != "first" || string(invalidation.Key) != "vivek" {
+			t.Fatalf("bad invalidation %+v", invalidation)
+		}
+	case <-time.After(time.Second):
+		t.Skipf("multicast datagrams are not delivered on this host")
+	}
+}
+
+func TestInvalidationDatagram(t *testing.T) {
+	for _, invalidation := range []Invalidation{
+		{Source: "vivek", Seq: 42, Key: []byte("ibibo")},
+		{Source: "vivek", Seq: 1 << 40, Clear: true},
+		{Source: "", Seq: 1, Key: []byte{}},
+		{Source: "vivek", Seq: 7, Tag: "user:42"},
+	} {
+		datagram, err := encodeInvalidation(invalidation)
+		if err != nil {
+			t.Fatalf("encode failed %v", err)
+		}
+		decoded, ok := decodeInvalidation(datagram)
+		if !ok || decoded.Source != invalidation.Source || decoded.Seq != invalidation.Seq ||
+			decoded.Clear != invalidation.Clear || string(decoded.Key) != string(invalidation.Key) ||
+			decoded.Tag != invalidation.Tag {
+			t.Fatalf("expected %+v got %+v", invalidation, decoded)
+		}
+		for i := 0; i < len(datagram)-len(invalidation.Key)-len(invalidation.Tag); i++ {
+			if _, ok := decodeInvalidation(datagram[:i]); ok {
+				t.Fatalf("truncated datagram of %d bytes decoded", i)
+			}
+		}
+	}
+	if _, err := encodeInvalidation(Invalidation{Key: make([]byte, maxDatagram)}); err != InvalidationSizeError {
+		t.Fatalf("expected InvalidationSizeError got %v", err)
+	}
+}
@@ -2,121 +2,133 @@ package spectre
 
 import (
 	"bytes"
-	"errors"
+	"container/heap"
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
 
-// Link is a node in circular doubly linked list that stores information about the
-// key usage and time to live
-// structure is like :
-//
-//
-// link1 prev\				  /link2 next
-//			link1		   link2
-//	 link1 next\        	/link2 prev
-//				\		   /
-//				 \		  /
-//	    root prev \      /root next
-//				    ROOT
-//
-type Link struct {
-	key        string
-	ExpireTime time.Time
-	size       int
-	ttlPrev    *Link
-	ttlNext    *Link
-	lruPrev    *Link
-	lruNext    *Link
+// NoExpiry given as the keyExpire of a set or to Expire keeps the key until
+// it is evicted or deleted.
+const NoExpiry = time.Duration(math.MaxInt64)
+
+// neverExpire is the ExpireTime of the keys set with NoExpiry, the last
+// instant UnixNano can represent so it survives snapshots and op logs.
+var neverExpire = time.Unix(0, math.MaxInt64)
+
+// KeepTTL given as the keyExpire of a set or an Update keeps the expiry of
+// a key already in the cache ; a new key gets the global ttl.
+const KeepTTL = time.Duration(math.MinInt64)
+
+// Link stores the time to live information of a key. The links of a
+// VolatileLRU are kept in a min heap on ExpireTime, so the key expiring
+// first is always at the top whatever the order the keys were set in. The
+// usage of the key is tracked by the EvictionPolicy of the underlying cache.
+type Link[K comparable] struct {
+	key         K
+	ExpireTime  time.Time
+	refreshTime time.Time // reads after it reload the key, zero without refresh
+	size        int
+	index       int // position of the link in the ttl heap
 }
 
 // isLinkTTLExpired tells in boolean about the key expiration.
 // true if expired or false.
-func (l *Link) isLinkTTLExpired() bool {
+func (l *Link[K]) isLinkTTLExpired() bool {
 	//fmt.Printf("current time= %v \n", time.Now())
 	//fmt.Printf("local time = %v \n", l.ExpireTime)
 	//fmt.Printf("local expired = %v \n", l.ExpireTime.Before(time.Now()))
 	return l.ExpireTime.Before(time.Now())
 }
 
-// addLRULink adds a lru link in the circular doubly link list between root
-// and a node left of it.
-func (l *Link) addLRULink(temp *Link) {
-	l.lruNext = temp
-	l.lruPrev = temp.lruPrev
-	temp.lruPrev.lruNext = l
-	temp.lruPrev = l
-}
+// ttlHeap is a min heap of links on their ExpireTime.
+type ttlHeap[K comparable] []*Link[K]
+
+func (th ttlHeap[K]) Len() int { return len(th) }
+
+func (th ttlHeap[K]) Less(i, j int) bool { return th[i].ExpireTime.Before(th[j].ExpireTime) }
 
-// addLRULink adds a ttl link in the circular doubly link list between root
-// and a node left of it.
-func (l *Link) addTTLLink(temp *Link) {
-	l.ttlNext = temp
-	l.ttlPrev = temp.ttlPrev
-	temp.ttlPrev.ttlNext = l
-	temp.ttlPrev = l
+func (th ttlHeap[K]) Swap(i, j int) {
+	th[i], th[j] = th[j], th[i]
+	th[i].index = i
+	th[j].index = j
 }
 
-// unlinkLRULink unlinks the link from its lru pointers in the
-// doubly link list
-func (temp *Link) unlinkLRULink() {
-	nextLink := temp.lruNext
-	prevLink := temp.lruPrev
-	nextLink.lruPrev = prevLink
-	prevLink.lruNext = nextLink
+func (th *ttlHeap[K]) Push(x interface{}) {
+	link := x.(*Link[K])
+	link.index = len(*th)
+	*th = append(*th, link)
 }
 
-// unlinkTTLLink unlinks the link from its ttl pointers in the
-// doubly link list
-func (temp *Link) unlinkTTLLink() {
-	nextLink := temp.ttlNext
-	prevLink := temp.ttlPrev
-	nextLink.ttlPrev = prevLink
-	prevLink.ttlNext = nextLink
+func (th *ttlHeap[K]) Pop() interface{} {
+	old := *th
+	last := len(old) - 1
+	link := old[last]
+	old[last] = nil
+	*th = old[:last]
+	link.index = -1
+	return link
 }
 
-// unlink removes a link in circular doubly link list.
-func (temp *Link) unlink() {
-	temp.unlinkLRULink()
-	temp.unlinkTTLLink()
+// peek returns the link expiring first, nil for an empty heap.
+func (th ttlHeap[K]) peek() *Link[K] {
+	if len(th) == 0 {
+		return nil
+	}
+	return th[0]
 }
 
-// add insert a new link in circular doubly link list
-func (l *Link) add(temp *Link) {
-	l.addLRULink(temp)
-	l.addTTLLink(temp)
+// sorted returns the links from the first to the last expiring one.
+func (th ttlHeap[K]) sorted() []*Link[K] {
+	links := make([]*Link[K], len(th))
+	copy(links, th)
+	sort.Slice(links, func(i, j int) bool { return links[i].ExpireTime.Before(links[j].ExpireTime) })
+	return links
 }
 
-// VolatileLRUCache is a cache wrapper on top of Cache.
+// VolatileLRU is a cache wrapper on top of TypedCache.
 // so still the maximum size of the cache is controlled
-// by Cache only. This wrapper just adds an algorithm for
+// by TypedCache only. This wrapper just adds an algorithm for
 // key eviction policy .
-// In case of memory unavailability VolatileLRUCache deletes
+// In case of memory unavailability VolatileLRU deletes
 // the keys in the following order :
 // 		*** keys which has been expired then the keys which are
-//		*** keys which are least recently used
-// VolatileLRUCache maintains a circular doubly link list in memory
-// to have the meta data of the keys ready. Also this structure is
+//		*** keys chosen by the eviction policy, least recently used
+//			ones by default
+// VolatileLRU maintains a min heap of links on their expire time in
+// memory to have the meta data of the keys ready. Also this structure is
 // thread safe; meaning several goroutine can operate concurrently.
-type VolatileLRUCache struct {
-	cache         *Cache
-	root          *Link
-	isMakingSpace bool
-	linkMap       map[string]*Link
-	globalTTL     time.Duration
-	sync.RWMutex  // to make double linked list thread safe
+type VolatileLRU[K comparable, V any] struct {
+	cache        *TypedCache[K, V]
+	ttlLinks     ttlHeap[K]
+	linkMap      map[K]*Link[K]
+	globalTTL    time.Duration
+	writer       *asyncWriter[K, V]
+	janitor      *janitor
+	loader       Loader[K, V]
+	loads        *loadGroup[K, V]
+	refreshAfter time.Duration
+	staleFor     time.Duration
+	codec        Codec[K, V]
+	snapshotFile string
+	snapshotter  *janitor
+	oplog        *opLog
+	invalidator  *invalidator
+	closed       int32 // set to 1 by Close, accessed atomically
+	sync.RWMutex       // to make ttl heap thread safe
 }
 
-// GetCurrentSize is a wrapper on top of Cache GetCurrentSize
-// which returns the current VolatileLRUCache size in bytes.
-func (vlruCache *VolatileLRUCache) VolatileLRUCacheCurrentSize() int {
+// CurrentSize is a wrapper on top of TypedCache GetCurrentSize
+// which returns the current VolatileLRU size in bytes.
+func (vlruCache *VolatileLRU[K, V]) CurrentSize() int {
 	vlruCache.RLocker().Lock()
 	defer vlruCache.RLocker().Unlock()
 	return vlruCache.cache.GetCurrentSize()
 }
 
-func (vlruCache *VolatileLRUCache) String() string {
+func (vlruCache *VolatileLRU[K, V]) String() string {
 	vlruCache.RLocker().Lock()
 	defer vlruCache.RLocker().Unlock()
 	var buffer bytes.Buffer
@@ -126,18 +138,18 @@ func (vlruCache *VolatileLRUCache) String() string {
 	return buffer.String()
 }
 
-// GetLRUInfo return the lru information of the keys in VolatileLRUCache.
-func (vlruCache *VolatileLRUCache) GetLRUInfo() string {
+// GetLRUInfo return the lru information of the keys in VolatileLRU,
+// in the eviction order of the policy. Policies which don't implement
+// OrderedPolicy report no key.
+func (vlruCache *VolatileLRU[K, V]) GetLRUInfo() string {
 	vlruCache.RLocker().Lock()
 	defer vlruCache.RLocker().Unlock()
-	rootLink := vlruCache.root
-	startingLink := rootLink.lruNext
-	var keyList []string
-	for startingLink != rootLink {
-		keyList = append(keyList, startingLink.key)
-		nextLink := startingLink.lruNext
-		startingLink = nextLink
+	var keyList []K
+	vlruCache.cache.policyLock.Lock()
+	if orderedPolicy, ok := vlruCache.cache.policy.(OrderedPolicy[K]); ok {
+		keyList = orderedPolicy.Keys()
 	}
+	vlruCache.cache.policyLock.Unlock()
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("key order in lru fashion with old first stratgy\n"))
 	for i, key := range keyList {
@@ -146,17 +158,14 @@ func (vlruCache *VolatileLRUCache) GetLRUInfo() string {
 	return buffer.String()
 }
 
-// GetLRUInfo return the ttl information of the keys in VolatileLRUCache.
-func (vlruCache *VolatileLRUCache) GetTTLInfo() string {
+// GetTTLInfo return the ttl information of the keys in VolatileLRU,
+// the key expiring first at first position.
+func (vlruCache *VolatileLRU[K, V]) GetTTLInfo() string {
 	vlruCache.RLocker().Lock()
 	defer vlruCache.RLocker().Unlock()
-	rootLink := vlruCache.root
-	startingLink := rootLink.ttlNext
-	var keyList []string
-	for startingLink != rootLink {
-		keyList = append(keyList, startingLink.key)
-		nextLink := startingLink.ttlNext
-		startingLink = nextLink
+	var keyList []K
+	for _, link := range vlruCache.ttlLinks.sorted() {
+		keyList = append(keyList, link.key)
 	}
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("key order in ttl fashion with old first stratgy\n"))
@@ -166,7 +175,9 @@ func (vlruCache *VolatileLRUCache) GetTTLInfo() string {
 	return buffer.String()
 }
 
-func (vlruCache *VolatileLRUCache) VolatileLRUCacheIterator(outputChannel chan CacheRow) {
+// Iterator returns the live key and value pairs one by one in the input
+// Row channel, starting with the key expiring first, see TypedCache Iterator.
+func (vlruCache *VolatileLRU[K, V]) Iterator(outputChannel chan Row[K, V]) {
 	go func() {
 		//panic handlling at goroutine level
 		defer func() {
@@ -177,182 +188,508 @@ func (vlruCache *VolatileLRUCache) VolatileLRUCacheIterator(outputChannel chan C
 
 		vlruCache.RLocker().Lock()
 		defer vlruCache.RLocker().Unlock()
-		rootLink := vlruCache.root
-		// taking ttl pointer to start with the oldest key
-		startingLink := rootLink.ttlNext
-		for startingLink != rootLink {
-			if !startingLink.isLinkTTLExpired() {
-				val, ok := vlruCache.cache.CacheGet(startingLink.key)
+		// starting with the key expiring first
+		for _, link := range vlruCache.ttlLinks.sorted() {
+			if !link.isLinkTTLExpired() {
+				val, ok := vlruCache.cache.peek(link.key)
 				if ok {
-					outputChannel <- CacheRow{Key: startingLink.key, Value: val}
+					outputChannel <- Row[K, V]{Key: link.key, Value: val}
 				}
 			}
-			nextLink := startingLink.ttlNext
-			startingLink = nextLink
 		}
 		close(outputChannel)
 		//fmt.Printf("spawaned go routine finishes")
 	}()
 }
 
-
-// VolatileLRUCacheGet returns the value corresponding to a key present in Cache.
-// This also modify internal doubly link list to maintain the updated ttl and lru info
-// of the keys preset in Cache.
+// Get returns the value corresponding to a key present in the cache.
+// The read is reported to the eviction policy of the cache to maintain the
+// usage info of the keys preset in the cache ; expired keys are not reported.
+// With WithRefreshAfter a read of a key older than the refresh duration
+// starts a background reload, and with WithStaleFor an expired key is still
+// returned during the grace window while it reloads.
 // return values :
-//		value: value corresponding to the key
+//		value: value corresponding to the key, the zero value on a miss
 //		ok: true if success else false
-func (vlruCache *VolatileLRUCache) VolatileLRUCacheGet(key string) (interface{}, bool) {
-	// lower level is thread safe so making write lock after this.
-	value, ok := vlruCache.cache.CacheGet(key)
-	// changing the link so grabbing write lock
-	vlruCache.Lock()
-	defer vlruCache.Unlock()
-
+func (vlruCache *VolatileLRU[K, V]) Get(key K) (V, bool) {
+	vlruCache.RLocker().Lock()
+	defer vlruCache.RLocker().Unlock()
+	var zero V
+	if vlruCache.isClosed() {
+		return zero, false
+	}
 	keyLink, linkOk := vlruCache.linkMap[key]
-	if !ok {
-		return nil, false
-	} else if linkOk {
-		if keyLink.isLinkTTLExpired() {
-			return nil, false
-		} else {
-			keyLink.unlinkLRULink()
-			keyLink.addLRULink(vlruCache.root)
+	if linkOk && keyLink.isLinkTTLExpired() {
+		if vlruCache.isLinkDead(keyLink) {
+			vlruCache.cache.stats.recordExpiredMiss()
+			return zero, false
 		}
+		vlruCache.refresh(key)
+	} else if linkOk && keyLink.needsRefresh() {
+		vlruCache.refresh(key)
 	}
-	return value, ok
-}
-
-func (vlruCache *VolatileLRUCache) VolatileLRUCacheSet(key string, value interface{}, size int, keyExpire time.Duration) (bool, error) {
-	// Check here to avoid race condition with makeSpace()
-	if vlruCache.isMakingSpace {
-		return false, LowSpaceError
-	}
-	go vlruCache.goVolatileLRUCacheSet(key, value, size, keyExpire)
-	return true, nil
+	// lower level is thread safe and reports the usage to the policy
+	return vlruCache.cache.Get(key)
 }
 
-// VolatileLRUCacheSet sets the value corresponding to a key in Cache.
+// Set sets the value corresponding to a key in the cache.
 // Setting operation also removes the keys which are already expired ; so as to
 // make the rem free as much as possible. In case of memory is not available
-// even after removing expired keys it removes the lru keys.
-// This also modify internal doubly link list to maintain the updated ttl and lru info
+// even after removing expired keys it removes the keys chosen by the eviction
+// policy, the lru keys by default, one by one until the value fits.
+// This also modify internal ttl heap to maintain the updated ttl info
 // of the keys preset in Cache.
+// The call blocks until the value is stored, use SetAsync for a fire and
+// forget write.
 //
 // input params :
-//				key: key to hold the value in cache (string type)
-//				value: struct having the data to cache.
-//				keyExpire: time duration for the current key expire.
+//				key: key to hold the value in cache.
+//				value: the data to cache.
+//				size: size of the value in bytes, AutoSize to estimate it.
+//				keyExpire: time duration for the current key expire, 0 for
+//						   the global ttl, NoExpiry to never expire, KeepTTL
+//						   to keep the expiry of a present key.
+//				opts: set options like WithCost or IfAbsent.
 // return values :
-//		ok: true if operation is successful else false
-//		error: error in case of occurred error else nil
-func (vlruCache *VolatileLRUCache) goVolatileLRUCacheSet(key string, value interface{}, size int, keyExpire time.Duration) (bool, error) {
+//		ok: true if operation is successful else false, also with a nil
+//			error when the key does not meet IfAbsent or IfPresent
+//		error: SizeLimitError if the value can never fit, LowSpaceError if
+//			   no more keys are left to evict, ErrClosed after Close else nil
+func (vlruCache *VolatileLRU[K, V]) Set(key K, value V, size int, keyExpire time.Duration, opts ...SetOption) (bool, error) {
+	success, err := vlruCache.localSet(key, value, size, keyExpire, opts...)
+	if success {
+		vlruCache.invalidate(key)
+	}
+	return success, err
+}
+
+// localSet is Set without the invalidation of the key for the other caches
+// of WithInvalidation, the loaded values are stored with it.
+func (vlruCache *VolatileLRU[K, V]) localSet(key K, value V, size int, keyExpire time.Duration, opts ...SetOption) (bool, error) {
+	vlruCache.Lock()
+	defer vlruCache.Unlock()
+	if vlruCache.isClosed() {
+		return false, ErrClosed
+	}
+	success, err := vlruCache.set(key, value, size, keyExpire, opts...)
+	vlruCache.cache.stats.recordSet(success, err)
+	return success, err
+}
+
+// set is Set for a caller already holding the write lock.
+func (vlruCache *VolatileLRU[K, V]) set(key K, value V, size int, keyExpire time.Duration, opts ...SetOption) (bool, error) {
 	//free memory from expired keys
+	vlruCache.RemoveVolatileKey()
+	if link, ok := vlruCache.linkMap[key]; ok && link.isLinkTTLExpired() && newSetConfig(opts).condition != setAlways {
+		// a key in its stale window is absent for a conditional set
+		vlruCache.cache.remove(key, Expired)
+		vlruCache.removeLink(key)
+	}
+	expireTime := vlruCache.expireTime(keyExpire)
+	if link, ok := vlruCache.linkMap[key]; ok && keyExpire == KeepTTL && !link.isLinkTTLExpired() {
+		expireTime = link.ExpireTime
+	}
+	size = vlruCache.cache.chargedSize(key, value, size)
+	return vlruCache.store(key, value, size, expireTime, opts...)
+}
+
+// Update atomically replaces the value of a key with one computed from its
+// current value, no other write of the cache can happen in between. The
+// update function runs under the lock of the cache so it must not use the
+// cache ; it gets the current value and false for a missing or expired key,
+// and returns the new value with its size, or false to leave the key as it
+// is. The read is not reported to the eviction policy nor the statistics.
+//
+// input params :
+//				key: key to update.
+//				keyExpire: time duration for the key expire like on Set,
+//						   KeepTTL to keep the expiry of a present key.
+//				update: computes the new value and its size from the
+//						current one.
+//				opts: set options like WithCost.
+// return values :
+//		ok: true if the new value is stored else false
+//		error: the error of the set like on Set, ErrClosed after Close
+func (vlruCache *VolatileLRU[K, V]) Update(key K, keyExpire time.Duration, update func(value V, ok bool) (V, int, bool), opts ...SetOption) (bool, error) {
+	success, err := vlruCache.update(key, keyExpire, update, opts...)
+	if success {
+		vlruCache.invalidate(key)
+	}
+	return success, err
+}
+
+// update is Update without the invalidation of the key.
+func (vlruCache *VolatileLRU[K, V]) update(key K, keyExpire time.Duration, update func(value V, ok bool) (V, int, bool), opts ...SetOption) (bool, error) {
 	vlruCache.Lock()
 	defer vlruCache.Unlock()
+	if vlruCache.isClosed() {
+		return false, ErrClosed
+	}
 	vlruCache.RemoveVolatileKey()
-	success, error := vlruCache.cache.SetData(key, value, size)
-	for error == LowSpaceError {
-		if !vlruCache.isMakingSpace {
-			vlruCache.isMakingSpace = true
-			vlruCache.makeSpace()
+	var current V
+	link, ok := vlruCache.linkMap[key]
+	if ok = ok && !link.isLinkTTLExpired(); ok {
+		current, ok = vlruCache.cache.peek(key)
+	}
+	value, size, apply := update(current, ok)
+	if !apply {
+		return false, nil
+	}
+	success, err := vlruCache.set(key, value, size, keyExpire, opts...)
+	vlruCache.cache.stats.recordSet(success, err)
+	return success, err
+}
+
+// expireTime returns the expiry of a key given keyExpire now.
+func (vlruCache *VolatileLRU[K, V]) expireTime(keyExpire time.Duration) time.Time {
+	if keyExpire == NoExpiry {
+		return neverExpire
+	} else if keyExpire.Seconds() <= 0 {
+		return time.Now().Add(vlruCache.globalTTL)
+	}
+	return time.Now().Add(keyExpire)
+}
+
+// store sets the value of the already charged size, expiring at the given
+// time, for a caller holding the write lock.
+func (vlruCache *VolatileLRU[K, V]) store(key K, value V, size int, expireTime time.Time, opts ...SetOption) (bool, error) {
+	success, error := vlruCache.cache.setData(key, value, size, opts...)
+	if error == LowSpaceError {
+		if _, error = vlruCache.makeSpace(key, size, opts...); error != nil {
+			return false, error
 		}
-		return false, error
-		// success, error = vlruCache.cache.SetData(key, value, size)
+		success, error = vlruCache.cache.setData(key, value, size, opts...)
 	}
 	if !success {
 		return success, error
 	}
 	link, ok := vlruCache.linkMap[key]
 	if !ok {
-		link = &Link{}
+		link = &Link[K]{key: key, index: -1}
 		vlruCache.linkMap[key] = link
-	} else {
-		link.unlink()
 	}
-	link.key = key
-	if keyExpire.Seconds() <= 0 {
-		link.ExpireTime = time.Now().Add(vlruCache.globalTTL)
-	} else {
-		link.ExpireTime = time.Now().Add(keyExpire)
+	link.ExpireTime = expireTime
+	if vlruCache.refreshAfter > 0 {
+		link.refreshTime = time.Now().Add(vlruCache.refreshAfter)
 	}
 	link.size = size
-	link.add(vlruCache.root)
+	if ok {
+		heap.Fix(&vlruCache.ttlLinks, link.index)
+	} else {
+		heap.Push(&vlruCache.ttlLinks, link)
+	}
+	vlruCache.logSet(key, value, size, expireTime, vlruCache.cache.Tags(key))
 	return true, nil
 }
 
-// RemoveVolatileKey removes the keys which are already expired in VolatileLRUCache.
-func (vlruCache *VolatileLRUCache) RemoveVolatileKey() {
-	rootLink := vlruCache.root
-	startingLink := rootLink.ttlNext
-	for startingLink != rootLink && startingLink.isLinkTTLExpired() {
-		vlruCache.cache.CacheDelete(startingLink.key)
-		delete(vlruCache.linkMap, startingLink.key)
-		nextLink := startingLink.ttlNext
-		startingLink.unlink()
-		startingLink = nextLink
+// removeLink forgets the ttl information of the key.
+func (vlruCache *VolatileLRU[K, V]) removeLink(key K) {
+	if link, ok := vlruCache.linkMap[key]; ok {
+		heap.Remove(&vlruCache.ttlLinks, link.index)
+		delete(vlruCache.linkMap, key)
+	}
+}
+
+// RemoveVolatileKey removes the keys which are already expired in VolatileLRU,
+// in their expiry order. The caller must hold the write lock.
+func (vlruCache *VolatileLRU[K, V]) RemoveVolatileKey() {
+	vlruCache.removeExpired(0)
+}
+
+// removeExpired removes at most limit expired keys, all of them for a limit
+// of 0, and returns the number of keys removed.
+func (vlruCache *VolatileLRU[K, V]) removeExpired(limit int) int {
+	removed := 0
+	for link := vlruCache.ttlLinks.peek(); link != nil && vlruCache.isLinkDead(link); link = vlruCache.ttlLinks.peek() {
+		if limit > 0 && removed >= limit {
+			break
+		}
+		heap.Pop(&vlruCache.ttlLinks)
+		vlruCache.cache.remove(link.key, Expired)
+		delete(vlruCache.linkMap, link.key)
+		removed++
 		// to free memory # golang garbage collector
 		//runtime.GC()
 	}
+	return removed
 }
 
-// VolatileLRUCacheDelete deletes a key present in VolatileLRUCache.
-func (vlruCache *VolatileLRUCache) VolatileLRUCacheDelete(key string) {
-	// lower level is thread safe so making write lock after this.
-	_, ok := vlruCache.cache.CacheGet(key)
-	if ok {
-		// changing the link so grabbing write lock
-		vlruCache.Lock()
-		defer vlruCache.Unlock()
-		vlruCache.RemoveVolatileKey()
-		vlruCache.cache.CacheDelete(key)
-		deletedLink := vlruCache.linkMap[key]
-		if deletedLink != nil {
-			deletedLink.unlink()
-			delete(vlruCache.linkMap, key)
+// Delete deletes a key present in VolatileLRU.
+func (vlruCache *VolatileLRU[K, V]) Delete(key K) {
+	vlruCache.Remove(key)
+}
+
+// Remove deletes a key present in VolatileLRU, like Delete, and tells if
+// the key was there.
+// return values :
+//		ok: true if the key was present and not expired else false
+func (vlruCache *VolatileLRU[K, V]) Remove(key K) bool {
+	vlruCache.Lock()
+	if vlruCache.isClosed() {
+		vlruCache.Unlock()
+		return false
+	}
+	ok := vlruCache.delete(key)
+	vlruCache.Unlock()
+	// the other caches may hold the key even when this one does not
+	vlruCache.invalidate(key)
+	return ok
+}
+
+// delete is Remove for a caller already holding the write lock.
+func (vlruCache *VolatileLRU[K, V]) delete(key K) bool {
+	vlruCache.RemoveVolatileKey()
+	if _, ok := vlruCache.linkMap[key]; !ok {
+		return false
+	}
+	vlruCache.cache.Delete(key)
+	vlruCache.removeLink(key)
+	vlruCache.logDelete(key)
+	return true
+}
+
+// Expire sets a new time to live for a key present in the cache, counted
+// from now ; a keyExpire of 0 gives the key the global ttl of the cache and
+// NoExpiry keeps it until it is evicted or deleted.
+// return values :
+//		ok: true if the key is present else false
+func (vlruCache *VolatileLRU[K, V]) Expire(key K, keyExpire time.Duration) bool {
+	vlruCache.Lock()
+	defer vlruCache.Unlock()
+	if vlruCache.isClosed() {
+		return false
+	}
+	return vlruCache.expire(key, vlruCache.expireTime(keyExpire))
+}
+
+// TTL returns the time left before the key expires, without reading it.
+// return values :
+//		ttl: the time left, NoExpiry for a key set with NoExpiry
+//		ok: true if the key is present and not expired else false
+func (vlruCache *VolatileLRU[K, V]) TTL(key K) (time.Duration, bool) {
+	vlruCache.RLocker().Lock()
+	defer vlruCache.RLocker().Unlock()
+	link, ok := vlruCache.linkMap[key]
+	if !ok || link.isLinkTTLExpired() {
+		return 0, false
+	}
+	if link.ExpireTime.Equal(neverExpire) {
+		return NoExpiry, true
+	}
+	return time.Until(link.ExpireTime), true
+}
+
+// ExpiringKeys returns the number of live keys with a time to live, the
+// keys set with NoExpiry left out. The keys are counted one by one.
+func (vlruCache *VolatileLRU[K, V]) ExpiringKeys() int {
+	vlruCache.RLocker().Lock()
+	defer vlruCache.RLocker().Unlock()
+	count := 0
+	for _, link := range vlruCache.linkMap {
+		if !link.isLinkTTLExpired() && !link.ExpireTime.Equal(neverExpire) {
+			count++
 		}
 	}
+	return count
 }
 
-func (vlruCache *VolatileLRUCache) VolatileLRUCachedKeys() (keySet []string) {
-	keySet = vlruCache.cache.CacheGetAllKeys()
-	return
+// expire moves the expiry of a live key for a caller holding the write lock.
+func (vlruCache *VolatileLRU[K, V]) expire(key K, expireTime time.Time) bool {
+	link, ok := vlruCache.linkMap[key]
+	if !ok || link.isLinkTTLExpired() {
+		return false
+	}
+	link.ExpireTime = expireTime
+	heap.Fix(&vlruCache.ttlLinks, link.index)
+	vlruCache.logExpire(key, expireTime)
+	return true
+}
+
+// EstimateFrequency returns how often the key has been used according to
+// the eviction policy, see TypedCache EstimateFrequency.
+func (vlruCache *VolatileLRU[K, V]) EstimateFrequency(key K) (int, bool) {
+	return vlruCache.cache.EstimateFrequency(key)
 }
 
-// makeSpace frees the space with least recently key.
+// Keys returns a list of all the keys in the cache.
+func (vlruCache *VolatileLRU[K, V]) Keys() []K {
+	return vlruCache.cache.Keys()
+}
+
+// makeSpace frees the space with the eviction policy of the cache until the
+// value of the given size fits for the key. The evicted keys are logged as
+// deletes, so a replay of the op log gets the same keys.
 // return values :
 //		ok: true if operation is successful else false
-//		error: error in case of occurred error else nil
-func (vlruCache *VolatileLRUCache) makeSpace() (bool, error) {
-	deleteCount := vlruCache.cache.MaxSize * 10 / 100
-	for deleteCount > 0 {
-
-		// linkTBE means link to be evicted with its data(key, value) in cache
-		linkTBE := vlruCache.root.lruNext
-		if linkTBE == vlruCache.root {
-			return false, errors.New("VolatileLRUCache is empty ... May be the memory is less")
-		}
-		key := linkTBE.key
-		vlruCache.cache.CacheDelete(key)
-		linkTBE.unlink()
-		delete(vlruCache.linkMap, key)
-		deleteCount = deleteCount - 1
+//		error: LowSpaceError if there is not any key left to evict else nil
+func (vlruCache *VolatileLRU[K, V]) makeSpace(key K, size int, opts ...SetOption) (bool, error) {
+	evicted, err := vlruCache.cache.makeSpace(key, size, opts...)
+	for _, evictedKey := range evicted {
+		vlruCache.removeLink(evictedKey)
+		vlruCache.logDelete(evictedKey)
+	}
+	if err != nil {
+		return false, err
 	}
-	vlruCache.isMakingSpace = false
 	return true, nil
 }
 
+// Clear clears all the keys in the cache.
+func (vlruCache *VolatileLRU[K, V]) Clear() {
+	vlruCache.Lock()
+	if vlruCache.isClosed() {
+		vlruCache.Unlock()
+		return
+	}
+	vlruCache.clear()
+	vlruCache.Unlock()
+	vlruCache.invalidateAll()
+}
+
+// clear is Clear for a caller already holding the write lock.
+func (vlruCache *VolatileLRU[K, V]) clear() {
+	vlruCache.cache.Clear()
+	vlruCache.ttlLinks = nil
+	vlruCache.linkMap = make(map[K]*Link[K])
+	vlruCache.logClear()
+}
+
+// NewVolatileLRU returns a VolatileLRU configured by the given options.
+// WithMaxSize and WithTTL are mandatory ; the partition count defaults to
+// DefaultPartitions, the hash function to hash/maphash and the eviction
+// policy to least recently used.
+// return values :
+//		cache: the configured cache
+//		error: a configuration error like InvalidSizeError for a bad input
+func NewVolatileLRU[K comparable, V any](opts ...Option) (*VolatileLRU[K, V], error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ttl <= 0 {
+		return nil, InvalidTTLError
+	}
+	return newVolatileLRU[K, V](cfg)
+}
+
+// newVolatileLRU builds a VolatileLRU from an already validated config.
+func newVolatileLRU[K comparable, V any](cfg *config) (*VolatileLRU[K, V], error) {
+	cache, err := newTypedCache[K, V](cfg, NewLRUPolicy[K])
+	if err != nil {
+		return nil, err
+	}
+	newVolatileCache := &VolatileLRU[K, V]{
+		cache:     cache,
+		linkMap:   make(map[K]*Link[K]),
+		globalTTL: cfg.ttl,
+		loads:     newLoadGroup[K, V](),
+
+		refreshAfter: cfg.refreshAfter,
+		staleFor:     cfg.staleFor,
+	}
+	if cfg.loader != nil {
+		loader, ok := cfg.loader.(Loader[K, V])
+		if !ok {
+			return nil, KeyTypeError
+		}
+		newVolatileCache.loader = loader
+	}
+	if (cfg.refreshAfter > 0 || cfg.staleFor > 0) && newVolatileCache.loader == nil {
+		return nil, NoLoaderError
+	}
+	newVolatileCache.codec = GobCodec[K, V]{}
+	if cfg.snapshotCodec != nil {
+		codec, ok := cfg.snapshotCodec.(Codec[K, V])
+		if !ok {
+			return nil, KeyTypeError
+		}
+		newVolatileCache.codec = codec
+	}
+	if cfg.opLogFile != "" {
+		oplog, err := openOpLog(cfg.opLogFile, cfg.opLogFsync, cfg.opLogRewriteSize, newVolatileCache.replay)
+		if err != nil {
+			return nil, err
+		}
+		// the replay is not counted in the statistics
+		newVolatileCache.cache.stats.reset()
+		newVolatileCache.oplog = oplog
+	}
+	newVolatileCache.writer = newAsyncWriter(newVolatileCache, cfg.queueDepth, cfg.queueFull)
+	if cfg.invalidation != nil {
+		newVolatileCache.invalidator = newInvalidator(cfg.invalidation)
+		newVolatileCache.invalidator.unsubscribe = cfg.invalidation.Subscribe(newVolatileCache.applyInvalidation)
+	}
+	if cfg.janitorInterval > 0 {
+		limit := cfg.janitorLimit
+		newVolatileCache.janitor = startJanitor(cfg.janitorInterval, func() {
+			newVolatileCache.Lock()
+			newVolatileCache.removeExpired(limit)
+			newVolatileCache.Unlock()
+		})
+	}
+	if cfg.snapshotFile != "" {
+		newVolatileCache.snapshotFile = cfg.snapshotFile
+		newVolatileCache.snapshotter = startJanitor(cfg.snapshotInterval, func() {
+			// a failed snapshot leaves the previous file, the next tick retries
+			newVolatileCache.SaveSnapshotFile(cfg.snapshotFile)
+		})
+	}
+	if oplog := newVolatileCache.oplog; oplog != nil {
+		oplog.ticker = startJanitor(time.Second, func() {
+			if oplog.tick() {
+				oplog.rewrites.Add(1)
+				go func() {
+					defer oplog.rewrites.Done()
+					newVolatileCache.CompactOpLog()
+				}()
+			}
+		})
+	}
+	return newVolatileCache, nil
+}
+
+// VolatileLRUCache is the string keyed VolatileLRU of the package, a thin
+// wrapper keeping the original method names.
+type VolatileLRUCache struct {
+	*VolatileLRU[string, interface{}]
+}
+
+// VolatileLRUCacheCurrentSize returns the current VolatileLRUCache size in bytes.
+func (vlruCache *VolatileLRUCache) VolatileLRUCacheCurrentSize() int {
+	return vlruCache.CurrentSize()
+}
+
+// VolatileLRUCacheIterator returns the live key and value pairs one by one
+// in the input CacheRow channel, see VolatileLRU Iterator.
+func (vlruCache *VolatileLRUCache) VolatileLRUCacheIterator(outputChannel chan CacheRow) {
+	vlruCache.Iterator(outputChannel)
+}
+
+// VolatileLRUCacheGet returns the value corresponding to a key present in
+// the cache, see VolatileLRU Get.
+func (vlruCache *VolatileLRUCache) VolatileLRUCacheGet(key string) (interface{}, bool) {
+	return vlruCache.Get(key)
+}
+
+// VolatileLRUCacheSet sets the value corresponding to a key in the cache,
+// see VolatileLRU Set.
+func (vlruCache *VolatileLRUCache) VolatileLRUCacheSet(key string, value interface{}, size int, keyExpire time.Duration, opts ...SetOption) (bool, error) {
+	return vlruCache.Set(key, value, size, keyExpire, opts...)
+}
+
+// VolatileLRUCacheDelete deletes a key present in VolatileLRUCache.
+func (vlruCache *VolatileLRUCache) VolatileLRUCacheDelete(key string) {
+	vlruCache.Delete(key)
+}
+
+func (vlruCache *VolatileLRUCache) VolatileLRUCachedKeys() (keySet []string) {
+	keySet = vlruCache.Keys()
+	return
+}
+
 // VolatileLRUCacheClear clears all the keys in the cache.
 func (vlruCache *VolatileLRUCache) VolatileLRUCacheClear() {
-	vlruCache.Lock()
-	defer vlruCache.Unlock()
-	vlruCache.cache.ClearCache()
-	vlruCache.root = &Link{}
-	vlruCache.linkMap = make(map[string]*Link)
-	vlruCache.root.lruNext = vlruCache.root
-	vlruCache.root.lruPrev = vlruCache.root
-	vlruCache.root.ttlNext = vlruCache.root
-	vlruCache.root.ttlPrev = vlruCache.root
+	vlruCache.Clear()
 }
 
 // GetVolatileLRUCache returns an instance of VolatileLRUCache with the specified
@@ -360,19 +697,28 @@ func (vlruCache *VolatileLRUCache) VolatileLRUCacheClear() {
 //			cacheSize: size of the cache in bytes
 //			cachePartitions: total number map participating in internal cache.
 //			ttl: a global time duration for each key expiration.
+// GetVolatileLRUCache does not validate its input, use New to get an error
+// back for a bad configuration.
 func GetVolatileLRUCache(cacheSize int, cachePartitions int, ttl time.Duration) *VolatileLRUCache {
-	newVolatileCache := &VolatileLRUCache{
-		cache:   GetDefaultCache(cacheSize, cachePartitions),
-		root:    &Link{},
-		linkMap: make(map[string]*Link),
-	}
+	cfg := defaultConfig()
+	cfg.maxSize = cacheSize
+	cfg.partitions = cachePartitions
 	//converting ttl to seconds for microseconds
-	ttl = ttl * time.Second
-	newVolatileCache.globalTTL = ttl
-	newVolatileCache.root.lruNext = newVolatileCache.root
-	newVolatileCache.root.lruPrev = newVolatileCache.root
-	newVolatileCache.root.ttlNext = newVolatileCache.root
-	newVolatileCache.root.ttlPrev = newVolatileCache.root
-	newVolatileCache.isMakingSpace = false
-	return newVolatileCache
+	cfg.ttl = ttl * time.Second
+	// the default config has no typed option, it can not fail
+	vlruCache, _ := newVolatileLRU[string, interface{}](cfg)
+	return &VolatileLRUCache{vlruCache}
+}
+
+// New returns a VolatileLRUCache configured by the given options, see
+// NewVolatileLRU for the defaults.
+// return values :
+//		cache: the configured cache
+//		error: a configuration error like InvalidSizeError for a bad input
+func New(opts ...Option) (*VolatileLRUCache, error) {
+	vlruCache, err := NewVolatileLRU[string, interface{}](opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &VolatileLRUCache{vlruCache}, nil
 }
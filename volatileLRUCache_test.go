@@ -1,8 +1,10 @@
 package spectre
 
 import (
+	"context"
 	"encoding/binary"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -122,6 +124,39 @@ func TestVolatileLRUCacheDelete(t *testing.T) {
 	}
 }
 
+func TestVolatileLRURemove(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+	cache.VolatileLRUCacheSet("short", "short", 5, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if !cache.Remove("vivek") || cache.VolatileLRUCacheCurrentSize() != 0 {
+		t.Fatalf("present key not removed")
+	}
+	for _, key := range []string{"vivek", "short", "missing"} {
+		if cache.Remove(key) {
+			t.Fatalf("expected %v reported missing", key)
+		}
+	}
+}
+
+func TestExpiringKeys(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	defer cache.Close(context.Background())
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, 0)
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, time.Minute)
+	cache.VolatileLRUCacheSet("forever", "forever", 7, NoExpiry)
+	cache.VolatileLRUCacheSet("short", "short", 5, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if count := cache.ExpiringKeys(); count != 2 {
+		t.Fatalf("expected 2 expiring keys got %d", count)
+	}
+	cache.Expire("vivek", NoExpiry)
+	if count := cache.ExpiringKeys(); count != 1 {
+		t.Fatalf("expected 1 expiring key got %d", count)
+	}
+}
+
 func BenchmarkVolatileLRUCacheDelete(b *testing.B) {
 	// run the Cache set  function b.N times
 	for n := 0; n < b.N; n++ {
@@ -170,3 +205,188 @@ func TestGetVolatileLRUCache(t *testing.T) {
 		t.Fatalf("type of the cache initiated is not spectre.Cache")
 	}
 }
+
+func TestVolatileLRUCacheSetSizeLimit(t *testing.T) {
+	cache := GetVolatileLRUCache(10, 4, time.Duration(3600))
+	success, err := cache.VolatileLRUCacheSet("vivek", "vivek", 11, time.Duration(0))
+	if success || err != SizeLimitError {
+		t.Fatalf("expected SizeLimitError got %v", err)
+	}
+}
+
+func TestVolatileLRUCacheSetEvictsLRU(t *testing.T) {
+	cache := GetVolatileLRUCache(10, 4, time.Duration(3600))
+	cache.VolatileLRUCacheSet("a", "a", 4, time.Duration(0))
+	cache.VolatileLRUCacheSet("b", "b", 4, time.Duration(0))
+	// touching a makes b the least recently used key
+	cache.VolatileLRUCacheGet("a")
+	success, err := cache.VolatileLRUCacheSet("c", "c", 6, time.Duration(0))
+	if !success {
+		t.Fatalf("set should evict until the value fits %v", err)
+	}
+	if _, ok := cache.VolatileLRUCacheGet("c"); !ok {
+		t.Fatalf("value is not readable right after set")
+	}
+	if _, ok := cache.VolatileLRUCacheGet("b"); ok {
+		t.Fatalf("least recently used key was not evicted")
+	}
+	if _, ok := cache.VolatileLRUCacheGet("a"); !ok {
+		t.Fatalf("recently used key got evicted")
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 10 {
+		t.Fatalf("cache size calculation is incorrect")
+	}
+}
+
+func TestVolatileLRUCacheSetReplace(t *testing.T) {
+	cache := GetVolatileLRUCache(10, 4, time.Duration(3600))
+	cache.VolatileLRUCacheSet("a", "a", 4, time.Duration(0))
+	success, err := cache.VolatileLRUCacheSet("a", "aa", 8, time.Duration(0))
+	if !success {
+		t.Fatalf("replacing a key with a bigger value failed %v", err)
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 8 {
+		t.Fatalf("replaced key is accounted twice")
+	}
+}
+
+func TestRemoveVolatileKeyExpiryOrder(t *testing.T) {
+	cache := GetVolatileLRUCache(100, 4, time.Duration(3600))
+	// the long lived key is set first, the short lived one behind it
+	cache.VolatileLRUCacheSet("long", "long", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("short", "short", 5, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	cache.VolatileLRUCacheSet("other", "other", 5, time.Duration(0))
+	if _, ok := cache.linkMap["short"]; ok {
+		t.Fatalf("expired key behind a long lived key was not reaped")
+	}
+	if _, ok := cache.VolatileLRUCacheGet("long"); !ok {
+		t.Fatalf("long lived key got reaped")
+	}
+	if cache.VolatileLRUCacheCurrentSize() != 10 {
+		t.Fatalf("cache size calculation is incorrect")
+	}
+}
+
+func TestGetTTLInfo(t *testing.T) {
+	cache := GetVolatileLRUCache(100, 4, time.Duration(3600))
+	cache.VolatileLRUCacheSet("long", "long", 5, time.Duration(0))
+	cache.VolatileLRUCacheSet("short", "short", 5, time.Minute)
+	expected := "key order in ttl fashion with old first stratgy\n{position:0, key:short}\t{position:1, key:long}\t"
+	if info := cache.GetTTLInfo(); info != expected {
+		t.Fatalf("unexpected ttl order %q", info)
+	}
+	cache.VolatileLRUCacheDelete("short")
+	if len(cache.ttlLinks) != 1 || cache.ttlLinks[0].key != "long" {
+		t.Fatalf("deleted key is still in the ttl heap")
+	}
+}
+
+func TestVolatileLRU(t *testing.T) {
+	cache, err := NewVolatileLRU[int, []byte](WithMaxSize(10), WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("typed cache creation failed %v", err)
+	}
+	cache.Set(1, []byte("vivek"), 5, time.Duration(0))
+	cache.Set(2, []byte("ibibo"), 5, time.Duration(0))
+	cache.Get(1)
+	cache.Set(3, []byte("spectr"), 5, time.Duration(0))
+	if _, ok := cache.Get(2); ok {
+		t.Fatalf("least recently used key was not evicted")
+	}
+	if val, ok := cache.Get(1); !ok || string(val) != "vivek" {
+		t.Fatalf("expected vivek got %v", val)
+	}
+	cache.Set(4, []byte("x"), 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if val, ok := cache.Get(4); ok || val != nil {
+		t.Fatalf("expired key returned %v", val)
+	}
+}
+
+func TestVolatileLRUCacheConditionalSet(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	if ok, err := cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Duration(0), IfPresent()); ok || err != nil {
+		t.Fatalf("IfPresent set a missing key %v %v", ok, err)
+	}
+	if ok, _ := cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Millisecond, IfAbsent()); !ok {
+		t.Fatalf("IfAbsent did not set a missing key")
+	}
+	if ok, _ := cache.VolatileLRUCacheSet("vivek", "vivek2", 5, time.Duration(0), IfAbsent()); ok {
+		t.Fatalf("IfAbsent replaced a present key")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if ok, _ := cache.VolatileLRUCacheSet("vivek", "vivek3", 5, time.Duration(0), IfAbsent()); !ok {
+		t.Fatalf("IfAbsent did not set an expired key")
+	}
+	if ok, _ := cache.VolatileLRUCacheSet("vivek", "vivek4", 5, time.Duration(0), IfPresent()); !ok {
+		t.Fatalf("IfPresent did not replace a present key")
+	}
+	if value, _ := cache.VolatileLRUCacheGet("vivek"); value != "vivek4" || cache.VolatileLRUCacheCurrentSize() != 5 {
+		t.Fatalf("expected vivek4 of size 5 got %v", value)
+	}
+}
+
+func TestVolatileLRUCacheTTL(t *testing.T) {
+	cache, _ := New(WithMaxSize(100), WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("vivek", "vivek", 5, time.Minute)
+	cache.VolatileLRUCacheSet("ibibo", "ibibo", 5, NoExpiry)
+	if ttl, ok := cache.TTL("vivek"); !ok || ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatalf("expected a minute got %v", ttl)
+	}
+	if ttl, ok := cache.TTL("ibibo"); !ok || ttl != NoExpiry {
+		t.Fatalf("expected NoExpiry got %v", ttl)
+	}
+	if _, ok := cache.TTL("missing"); ok {
+		t.Fatalf("missing key has a ttl")
+	}
+	if !cache.Expire("vivek", NoExpiry) || cache.Expire("missing", time.Minute) {
+		t.Fatalf("expire reported the wrong keys")
+	}
+	if ttl, _ := cache.TTL("vivek"); ttl != NoExpiry {
+		t.Fatalf("expected NoExpiry got %v", ttl)
+	}
+	cache.Expire("ibibo", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := cache.VolatileLRUCacheGet("ibibo"); ok {
+		t.Fatalf("key did not expire after Expire")
+	}
+}
+
+func TestVolatileLRUCacheUpdate(t *testing.T) {
+	cache, _ := New(WithMaxSize(10), WithTTL(time.Hour))
+	cache.VolatileLRUCacheSet("counter", 1, 1, time.Minute)
+	increment := func(value interface{}, ok bool) (interface{}, int, bool) {
+		if !ok {
+			return nil, 0, false
+		}
+		return value.(int) + 1, 1, true
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Update("counter", KeepTTL, increment)
+		}()
+	}
+	wg.Wait()
+	if value, _ := cache.VolatileLRUCacheGet("counter"); value != 101 {
+		t.Fatalf("expected 101 got %v", value)
+	}
+	if ttl, _ := cache.TTL("counter"); ttl > time.Minute {
+		t.Fatalf("KeepTTL did not keep the expiry, got %v", ttl)
+	}
+	if ok, err := cache.Update("missing", KeepTTL, increment); ok || err != nil {
+		t.Fatalf("update of a missing key applied %v %v", ok, err)
+	}
+	if ok, err := cache.Update("counter", 0, func(interface{}, bool) (interface{}, int, bool) {
+		return "too big", 20, true
+	}); ok || err != SizeLimitError {
+		t.Fatalf("expected SizeLimitError got %v %v", ok, err)
+	}
+	cache.VolatileLRUCacheSet("new", 1, 1, KeepTTL)
+	if ttl, _ := cache.TTL("new"); ttl <= time.Minute {
+		t.Fatalf("KeepTTL of a new key did not give the global ttl, got %v", ttl)
+	}
+}